@@ -0,0 +1,261 @@
+package staking
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/0xPolygon/polygon-edge/chain"
+	"github.com/0xPolygon/polygon-edge/types"
+)
+
+var (
+	ErrAlreadyValidator = errors.New("address is already a validator")
+	ErrNotValidator     = errors.New("address is not a validator")
+	ErrValidatorSetFull = errors.New("validator set has reached full capacity")
+	ErrValidatorSetMin  = errors.New("validators can't be less than the minimum required validator num")
+	ErrStakedTokens     = errors.New("validator has staked tokens")
+)
+
+// StakingGenesisBuilder edits the storage of an existing staking SC genesis account.
+// Every change mirrors what the staking SC itself does, so _validators,
+// the validator mappings, the staked amounts and the array length stay consistent
+type StakingGenesisBuilder struct {
+	account *chain.GenesisAccount
+	mode    StorageMode
+}
+
+// NewStakingGenesisBuilder creates a builder over the given staking SC account.
+// The account is modified in place. The storage mode should be the one the account
+// was predeployed with (PredeployParams.StorageMode), so the edited account has
+// the same storage the predeploy would generate for the resulting validator set
+func NewStakingGenesisBuilder(account *chain.GenesisAccount, mode StorageMode) (*StakingGenesisBuilder, error) {
+	mode, err := ParseStorageMode(string(mode))
+	if err != nil {
+		return nil, err
+	}
+
+	if account.Storage == nil {
+		account.Storage = make(map[types.Hash]types.Hash)
+	}
+
+	if account.Balance == nil {
+		account.Balance = big.NewInt(0)
+	}
+
+	return &StakingGenesisBuilder{
+		account: account,
+		mode:    mode,
+	}, nil
+}
+
+// Account returns the underlying staking SC account
+func (b *StakingGenesisBuilder) Account() *chain.GenesisAccount {
+	return b.account
+}
+
+// word reads the storage word at the given index
+func (b *StakingGenesisBuilder) word(index []byte) *big.Int {
+	value := b.account.Storage[types.BytesToHash(index)]
+
+	return new(big.Int).SetBytes(value.Bytes())
+}
+
+// setWord writes the storage word at the given index.
+// Zero words are only kept in the explicit storage mode
+func (b *StakingGenesisBuilder) setWord(index []byte, value *big.Int) {
+	if value.Sign() == 0 && b.mode == StorageModeSparse {
+		b.clearWord(index)
+
+		return
+	}
+
+	b.account.Storage[types.BytesToHash(index)] = types.BytesToHash(value.Bytes())
+}
+
+// clearWord removes the storage word at the given index. It's used for the slots
+// the predeploy wouldn't write at all, whatever the storage mode
+func (b *StakingGenesisBuilder) clearWord(index []byte) {
+	delete(b.account.Storage, types.BytesToHash(index))
+}
+
+// validatorCount returns the length of the _validators array
+func (b *StakingGenesisBuilder) validatorCount() uint64 {
+	return b.word(big.NewInt(validatorsSlot).Bytes()).Uint64()
+}
+
+// IsValidator checks if the address is in the validator set
+func (b *StakingGenesisBuilder) IsValidator(address types.Address) bool {
+	return b.word(getAddressMapping(address, addressToIsValidatorSlot)).Sign() != 0
+}
+
+// Validators returns the validator set, in _validators order
+func (b *StakingGenesisBuilder) Validators() []types.Address {
+	count := b.validatorCount()
+	validators := make([]types.Address, count)
+
+	for i := uint64(0); i < count; i++ {
+		indexes := getStorageIndexes(types.ZeroAddress, int64(i))
+		validators[i] = types.BytesToAddress(b.word(indexes.ValidatorsIndex).Bytes())
+	}
+
+	return validators
+}
+
+// StakeOf returns the staked amount of the address
+func (b *StakingGenesisBuilder) StakeOf(address types.Address) *big.Int {
+	return b.word(getAddressMapping(address, addressToStakedAmountSlot))
+}
+
+// Params returns the validator count bounds stored in the account
+func (b *StakingGenesisBuilder) Params() PredeployParams {
+	return PredeployParams{
+		MinValidatorCount: b.word(big.NewInt(minNumValidatorSlot).Bytes()).Uint64(),
+		MaxValidatorCount: b.word(big.NewInt(maxNumValidatorSlot).Bytes()).Uint64(),
+	}
+}
+
+// AddValidator appends the address to the validator set with the given stake,
+// the same way the staking SC does when an account becomes a validator
+func (b *StakingGenesisBuilder) AddValidator(address types.Address, stake *big.Int) error {
+	if stake == nil || stake.Sign() < 0 {
+		return fmt.Errorf("invalid stake for address %s", address)
+	}
+
+	if b.IsValidator(address) {
+		return fmt.Errorf("%w: %s", ErrAlreadyValidator, address)
+	}
+
+	count := b.validatorCount()
+	if count >= b.Params().MaxValidatorCount {
+		return ErrValidatorSetFull
+	}
+
+	indexes := getStorageIndexes(address, int64(count))
+
+	// Set the value for the validators array
+	b.setWord(indexes.ValidatorsIndex, new(big.Int).SetBytes(address.Bytes()))
+
+	// Set the value for the address -> is validator mapping
+	b.setWord(indexes.AddressToIsValidatorIndex, big.NewInt(1))
+
+	// Set the value for the address -> validator index mapping
+	b.setWord(indexes.AddressToValidatorIndexIndex, new(big.Int).SetUint64(count))
+
+	// Set the value for the size of the validators array
+	b.setWord(indexes.ValidatorsArraySizeIndex, new(big.Int).SetUint64(count+1))
+
+	return b.SetStake(address, stake)
+}
+
+// RemoveValidator removes the address from the validator set with the staking SC's
+// swap-and-pop semantics: the last validator is moved into the freed index.
+// The stake of the removed validator is withdrawn as well.
+// A validator with staked tokens can't be removed, as its tokens are owned by the staking SC
+// in the ERC721 SC storage, which the builder doesn't edit, and an ErrStakedTokens is returned
+func (b *StakingGenesisBuilder) RemoveValidator(address types.Address) error {
+	if !b.IsValidator(address) {
+		return fmt.Errorf("%w: %s", ErrNotValidator, address)
+	}
+
+	// Every staked token adds a weight of at least 1
+	if b.word(getAddressMapping(address, addressToWeightSlot)).Sign() != 0 {
+		return fmt.Errorf("%w: %s", ErrStakedTokens, address)
+	}
+
+	count := b.validatorCount()
+	if count <= b.Params().MinValidatorCount {
+		return ErrValidatorSetMin
+	}
+
+	indexes := getStorageIndexes(address, 0)
+	index := b.word(indexes.AddressToValidatorIndexIndex).Uint64()
+	lastIndex := count - 1
+
+	if index != lastIndex {
+		// Move the last validator into the freed index
+		lastValidator := types.BytesToAddress(
+			b.word(getStorageIndexes(types.ZeroAddress, int64(lastIndex)).ValidatorsIndex).Bytes(),
+		)
+
+		b.setWord(
+			getStorageIndexes(types.ZeroAddress, int64(index)).ValidatorsIndex,
+			new(big.Int).SetBytes(lastValidator.Bytes()),
+		)
+		b.setWord(
+			getAddressMapping(lastValidator, addressToValidatorIndexSlot),
+			new(big.Int).SetUint64(index),
+		)
+	}
+
+	// Pop the last element of the validators array
+	b.clearWord(getStorageIndexes(types.ZeroAddress, int64(lastIndex)).ValidatorsIndex)
+	b.setWord(indexes.ValidatorsArraySizeIndex, new(big.Int).SetUint64(lastIndex))
+
+	// Clear the address mappings
+	b.clearWord(indexes.AddressToIsValidatorIndex)
+	b.clearWord(indexes.AddressToValidatorIndexIndex)
+
+	if err := b.SetStake(address, big.NewInt(0)); err != nil {
+		return err
+	}
+
+	// The withdrawn stake isn't a zero stake, the slot is cleared
+	b.clearWord(indexes.AddressToStakedAmountIndex)
+
+	return nil
+}
+
+// SetStake changes the staked amount of the address, keeping the
+// total staked amount and the staking SC balance in sync
+func (b *StakingGenesisBuilder) SetStake(address types.Address, stake *big.Int) error {
+	if stake == nil || stake.Sign() < 0 {
+		return fmt.Errorf("invalid stake for address %s", address)
+	}
+
+	stakedAmountIndex := getAddressMapping(address, addressToStakedAmountSlot)
+	totalIndex := big.NewInt(stakedAmountSlot).Bytes()
+
+	delta := new(big.Int).Sub(stake, b.word(stakedAmountIndex))
+	total := new(big.Int).Add(b.word(totalIndex), delta)
+
+	// Set the value for the address -> staked amount mapping
+	b.setWord(stakedAmountIndex, stake)
+
+	// Set the value for the total staked amount
+	b.setWord(totalIndex, total)
+
+	b.account.Balance = new(big.Int).Add(b.account.Balance, delta)
+
+	return nil
+}
+
+// SetParams changes the validator count bounds.
+// The current validator set has to fit in the new bounds
+func (b *StakingGenesisBuilder) SetParams(params PredeployParams) error {
+	if params.MinValidatorCount > params.MaxValidatorCount {
+		return fmt.Errorf(
+			"minimum validator count %d is greater than the maximum %d",
+			params.MinValidatorCount,
+			params.MaxValidatorCount,
+		)
+	}
+
+	if count := b.validatorCount(); count < params.MinValidatorCount || count > params.MaxValidatorCount {
+		return fmt.Errorf(
+			"%w: %d validators, bounds [%d, %d]",
+			errValidatorCount,
+			count,
+			params.MinValidatorCount,
+			params.MaxValidatorCount,
+		)
+	}
+
+	// Set the value for the minimum number of validators
+	b.setWord(big.NewInt(minNumValidatorSlot).Bytes(), new(big.Int).SetUint64(params.MinValidatorCount))
+
+	// Set the value for the maximum number of validators
+	b.setWord(big.NewInt(maxNumValidatorSlot).Bytes(), new(big.Int).SetUint64(params.MaxValidatorCount))
+
+	return nil
+}
@@ -0,0 +1,182 @@
+package staking
+
+import (
+	"errors"
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/0xPolygon/polygon-edge/chain"
+	"github.com/0xPolygon/polygon-edge/types"
+)
+
+// assertSameAccount checks that the accounts have exactly the same storage words, zero words included,
+// and the same balance
+func assertSameAccount(t *testing.T, want, got *chain.GenesisAccount) {
+	t.Helper()
+
+	if !reflect.DeepEqual(want.Storage, got.Storage) {
+		for key, value := range want.Storage {
+			if gotValue, ok := got.Storage[key]; !ok || gotValue != value {
+				t.Errorf("slot %s: expected %s, got %s (present %v)", key, value, gotValue, ok)
+			}
+		}
+
+		for key, value := range got.Storage {
+			if _, ok := want.Storage[key]; !ok {
+				t.Errorf("slot %s: unexpected %s", key, value)
+			}
+		}
+	}
+
+	if want.Balance.Cmp(got.Balance) != 0 {
+		t.Errorf("expected balance %s, got %s", want.Balance, got.Balance)
+	}
+}
+
+func TestStakingGenesisBuilderMatchesPredeploy(t *testing.T) {
+	var (
+		validatorA = types.StringToAddress("0x1")
+		validatorB = types.StringToAddress("0x2")
+		validatorC = types.StringToAddress("0x3")
+	)
+
+	for _, mode := range []StorageMode{StorageModeExplicit, StorageModeSparse} {
+		mode := mode
+
+		params := PredeployParams{
+			MinValidatorCount: 0,
+			MaxValidatorCount: 3,
+			StorageMode:       mode,
+		}
+
+		predeploy := func(validators []types.Address, stakes map[types.Address]*big.Int) *chain.GenesisAccount {
+			t.Helper()
+
+			account, err := PredeployStakingSCWithStakes(validators, stakes, params)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			return account
+		}
+
+		t.Run(mode.String()+" add", func(t *testing.T) {
+			// The first validator gets the index 0, which is an explicit zero word in the explicit mode
+			account := predeploy(nil, nil)
+
+			builder, err := NewStakingGenesisBuilder(account, mode)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err := builder.AddValidator(validatorA, big.NewInt(0)); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := builder.AddValidator(validatorB, big.NewInt(7)); err != nil {
+				t.Fatal(err)
+			}
+
+			assertSameAccount(t, predeploy(
+				[]types.Address{validatorA, validatorB},
+				map[types.Address]*big.Int{validatorA: big.NewInt(0), validatorB: big.NewInt(7)},
+			), builder.Account())
+		})
+
+		t.Run(mode.String()+" remove", func(t *testing.T) {
+			stakes := map[types.Address]*big.Int{
+				validatorA: big.NewInt(1),
+				validatorB: big.NewInt(2),
+				validatorC: big.NewInt(3),
+			}
+
+			account := predeploy([]types.Address{validatorA, validatorB, validatorC}, stakes)
+
+			builder, err := NewStakingGenesisBuilder(account, mode)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// The last validator is swapped into the index of the removed one
+			if err := builder.RemoveValidator(validatorA); err != nil {
+				t.Fatal(err)
+			}
+
+			assertSameAccount(t, predeploy([]types.Address{validatorC, validatorB}, stakes), builder.Account())
+		})
+	}
+}
+
+func TestStakingGenesisBuilderBounds(t *testing.T) {
+	account, err := PredeployStakingSC([]types.Address{types.StringToAddress("0x1")}, PredeployParams{
+		MinValidatorCount: 1,
+		MaxValidatorCount: 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	builder, err := NewStakingGenesisBuilder(account, StorageModeExplicit)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := builder.AddValidator(types.StringToAddress("0x2"), big.NewInt(1)); !errors.Is(err, ErrValidatorSetFull) {
+		t.Fatalf("expected ErrValidatorSetFull, got %v", err)
+	}
+
+	if err := builder.RemoveValidator(types.StringToAddress("0x1")); !errors.Is(err, ErrValidatorSetMin) {
+		t.Fatalf("expected ErrValidatorSetMin, got %v", err)
+	}
+
+	if _, err := NewStakingGenesisBuilder(account, "dense"); err == nil {
+		t.Fatal("expected an unknown storage mode error")
+	}
+}
+
+func TestStakingGenesisBuilderRemoveTokenStaker(t *testing.T) {
+	var (
+		validatorA = types.StringToAddress("0x1")
+		validatorB = types.StringToAddress("0x2")
+	)
+
+	accounts, err := BuildStakingGenesis(StakingGenesisSpec{
+		Params: PredeployParams{
+			MinValidatorCount: 1,
+			MaxValidatorCount: 2,
+		},
+		Validators: []types.Address{validatorA, validatorB},
+		StakedTokens: map[types.Address][]*big.Int{
+			validatorA: {big.NewInt(1)},
+			validatorB: {big.NewInt(2), big.NewInt(3)},
+		},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	account := accounts[DefaultStakingSCAddress]
+
+	want := &chain.GenesisAccount{
+		Balance: new(big.Int).Set(account.Balance),
+		Storage: make(map[types.Hash]types.Hash, len(account.Storage)),
+	}
+
+	for key, value := range account.Storage {
+		want.Storage[key] = value
+	}
+
+	builder, err := NewStakingGenesisBuilder(account, StorageModeExplicit)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The weight and the token ID -> staker entries would be left behind,
+	// and the ERC721 SC would still record the staking SC as the token owner
+	if err := builder.RemoveValidator(validatorB); !errors.Is(err, ErrStakedTokens) {
+		t.Fatalf("expected ErrStakedTokens, got %v", err)
+	}
+
+	assertSameAccount(t, want, builder.Account())
+}
@@ -0,0 +1,461 @@
+package staking
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+
+	"github.com/0xPolygon/polygon-edge/helper/common"
+	"github.com/0xPolygon/polygon-edge/helper/hex"
+	"github.com/0xPolygon/polygon-edge/types"
+)
+
+// Selectors of the view functions of the staking SC.
+// The names of the NFT getters aren't part of the published ABI,
+// so they are named after the storage they read
+var (
+	selectorAddressToIsValidator    = []byte{0x06, 0x5a, 0xe1, 0x71} // _addressToIsValidator(address)
+	selectorAddressToStakedAmount   = []byte{0x7d, 0xce, 0xce, 0xb8} // _addressToStakedAmount(address)
+	selectorAddressToValidatorIndex = []byte{0x02, 0xb7, 0x51, 0x99} // _addressToValidatorIndex(address)
+	selectorMaximumNumValidatorsVar = []byte{0xaf, 0x6d, 0xa3, 0x6e} // _maximumNumValidators()
+	selectorMinimumNumValidatorsVar = []byte{0xc7, 0x95, 0xc0, 0x77} // _minimumNumValidators()
+	selectorStakedAmountVar         = []byte{0xe3, 0x87, 0xa7, 0xed} // _stakedAmount()
+	selectorValidatorAt             = []byte{0xf9, 0x0e, 0xca, 0xcc} // _validators(uint256)
+	selectorValidatorThreshold      = []byte{0x7a, 0x6e, 0xea, 0x37} // VALIDATOR_THRESHOLD()
+	selectorAccountStake            = []byte{0x23, 0x67, 0xf6, 0xb5} // accountStake(address)
+	selectorGetScore                = []byte{0x0e, 0x1a, 0xf5, 0x7b} // getScore(uint256)
+	selectorIsValidator             = []byte{0xfa, 0xcd, 0x74, 0x3b} // isValidator(address)
+	selectorMaximumNumValidators    = []byte{0xe8, 0x04, 0xfb, 0xf6} // maximumNumValidators()
+	selectorMinimumNumValidators    = []byte{0x71, 0x4f, 0xf4, 0x25} // minimumNumValidators()
+	selectorStakedAmount            = []byte{0x37, 0x3d, 0x61, 0x32} // stakedAmount()
+	selectorValidators              = []byte{0xca, 0x1e, 0x78, 0x19} // validators()
+	selectorNFTAddress              = []byte{0x65, 0x88, 0x10, 0x3b} // NFT contract (slot 7)
+	selectorTokenIDToOwner          = []byte{0x94, 0x06, 0x70, 0x45} // token ID => staker (slot 8)
+	selectorAddressToWeight         = []byte{0x88, 0x9d, 0x05, 0x25} // address => weight (slot 9)
+	selectorAccountWeight           = []byte{0xe8, 0x49, 0x26, 0x8d} // weight of the account (slot 9)
+)
+
+var errInvalidReturnData = errors.New("invalid return data")
+
+// ViewCall is a call to a view function of the staking SC,
+// with the destination of its decoded result
+type ViewCall struct {
+	name   string
+	input  []byte
+	decode func(output []byte) error
+}
+
+// encodeCall ABI encodes the call of a function with static arguments
+func encodeCall(selector []byte, args ...[]byte) []byte {
+	input := make([]byte, 0, len(selector)+32*len(args))
+	input = append(input, selector...)
+
+	for _, arg := range args {
+		input = append(input, common.PadLeftOrTrim(arg, 32)...)
+	}
+
+	return input
+}
+
+func decodeWord(output []byte, offset int) ([]byte, error) {
+	if offset < 0 || len(output) < offset+32 {
+		return nil, errInvalidReturnData
+	}
+
+	return output[offset : offset+32], nil
+}
+
+// decodeWordArray decodes the words of a dynamic array, the only value of the ABI encoded data
+func decodeWordArray(data []byte) ([][]byte, error) {
+	offsetWord, err := decodeWord(data, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	offset := new(big.Int).SetBytes(offsetWord)
+	if !offset.IsInt64() || offset.Int64() > int64(len(data)) {
+		return nil, errInvalidReturnData
+	}
+
+	lengthWord, err := decodeWord(data, int(offset.Int64()))
+	if err != nil {
+		return nil, err
+	}
+
+	length := new(big.Int).SetBytes(lengthWord)
+	if !length.IsInt64() || length.Int64() > int64(len(data)/32) {
+		return nil, errInvalidReturnData
+	}
+
+	words := make([][]byte, length.Int64())
+
+	for i := range words {
+		if words[i], err = decodeWord(data, int(offset.Int64())+32*(i+1)); err != nil {
+			return nil, err
+		}
+	}
+
+	return words, nil
+}
+
+func boolCall(name string, input []byte, result *bool) *ViewCall {
+	return &ViewCall{
+		name:  name,
+		input: input,
+		decode: func(output []byte) error {
+			word, err := decodeWord(output, 0)
+			if err != nil {
+				return err
+			}
+
+			*result = new(big.Int).SetBytes(word).Sign() != 0
+
+			return nil
+		},
+	}
+}
+
+func uintCall(name string, input []byte, result *big.Int) *ViewCall {
+	return &ViewCall{
+		name:  name,
+		input: input,
+		decode: func(output []byte) error {
+			word, err := decodeWord(output, 0)
+			if err != nil {
+				return err
+			}
+
+			result.SetBytes(word)
+
+			return nil
+		},
+	}
+}
+
+func addressCall(name string, input []byte, result *types.Address) *ViewCall {
+	return &ViewCall{
+		name:  name,
+		input: input,
+		decode: func(output []byte) error {
+			word, err := decodeWord(output, 0)
+			if err != nil {
+				return err
+			}
+
+			*result = types.BytesToAddress(word)
+
+			return nil
+		},
+	}
+}
+
+func addressesCall(name string, input []byte, result *[]types.Address) *ViewCall {
+	return &ViewCall{
+		name:  name,
+		input: input,
+		decode: func(output []byte) error {
+			words, err := decodeWordArray(output)
+			if err != nil {
+				return err
+			}
+
+			addresses := make([]types.Address, len(words))
+			for i, word := range words {
+				addresses[i] = types.BytesToAddress(word)
+			}
+
+			*result = addresses
+
+			return nil
+		},
+	}
+}
+
+// IsValidatorCall calls isValidator(address)
+func IsValidatorCall(address types.Address, result *bool) *ViewCall {
+	return boolCall("isValidator", encodeCall(selectorIsValidator, address.Bytes()), result)
+}
+
+// AddressToIsValidatorCall calls _addressToIsValidator(address)
+func AddressToIsValidatorCall(address types.Address, result *bool) *ViewCall {
+	return boolCall("_addressToIsValidator", encodeCall(selectorAddressToIsValidator, address.Bytes()), result)
+}
+
+// AccountStakeCall calls accountStake(address)
+func AccountStakeCall(address types.Address, result *big.Int) *ViewCall {
+	return uintCall("accountStake", encodeCall(selectorAccountStake, address.Bytes()), result)
+}
+
+// AddressToStakedAmountCall calls _addressToStakedAmount(address)
+func AddressToStakedAmountCall(address types.Address, result *big.Int) *ViewCall {
+	return uintCall("_addressToStakedAmount", encodeCall(selectorAddressToStakedAmount, address.Bytes()), result)
+}
+
+// AddressToValidatorIndexCall calls _addressToValidatorIndex(address)
+func AddressToValidatorIndexCall(address types.Address, result *big.Int) *ViewCall {
+	return uintCall("_addressToValidatorIndex", encodeCall(selectorAddressToValidatorIndex, address.Bytes()), result)
+}
+
+// StakedAmountCall calls stakedAmount()
+func StakedAmountCall(result *big.Int) *ViewCall {
+	return uintCall("stakedAmount", encodeCall(selectorStakedAmount), result)
+}
+
+// StakedAmountVarCall calls _stakedAmount()
+func StakedAmountVarCall(result *big.Int) *ViewCall {
+	return uintCall("_stakedAmount", encodeCall(selectorStakedAmountVar), result)
+}
+
+// MinimumNumValidatorsCall calls minimumNumValidators()
+func MinimumNumValidatorsCall(result *big.Int) *ViewCall {
+	return uintCall("minimumNumValidators", encodeCall(selectorMinimumNumValidators), result)
+}
+
+// MinimumNumValidatorsVarCall calls _minimumNumValidators()
+func MinimumNumValidatorsVarCall(result *big.Int) *ViewCall {
+	return uintCall("_minimumNumValidators", encodeCall(selectorMinimumNumValidatorsVar), result)
+}
+
+// MaximumNumValidatorsCall calls maximumNumValidators()
+func MaximumNumValidatorsCall(result *big.Int) *ViewCall {
+	return uintCall("maximumNumValidators", encodeCall(selectorMaximumNumValidators), result)
+}
+
+// MaximumNumValidatorsVarCall calls _maximumNumValidators()
+func MaximumNumValidatorsVarCall(result *big.Int) *ViewCall {
+	return uintCall("_maximumNumValidators", encodeCall(selectorMaximumNumValidatorsVar), result)
+}
+
+// ValidatorThresholdCall calls VALIDATOR_THRESHOLD()
+func ValidatorThresholdCall(result *big.Int) *ViewCall {
+	return uintCall("VALIDATOR_THRESHOLD", encodeCall(selectorValidatorThreshold), result)
+}
+
+// ValidatorsCall calls validators()
+func ValidatorsCall(result *[]types.Address) *ViewCall {
+	return addressesCall("validators", encodeCall(selectorValidators), result)
+}
+
+// ValidatorAtCall calls _validators(uint256)
+func ValidatorAtCall(index uint64, result *types.Address) *ViewCall {
+	return addressCall(
+		"_validators",
+		encodeCall(selectorValidatorAt, new(big.Int).SetUint64(index).Bytes()),
+		result,
+	)
+}
+
+// ScoreCall calls getScore(uint256), the weight of a single token
+func ScoreCall(tokenID *big.Int, result *big.Int) *ViewCall {
+	return uintCall("getScore", encodeCall(selectorGetScore, tokenID.Bytes()), result)
+}
+
+// NFTAddressCall reads the NFT contract address (slot 7)
+func NFTAddressCall(result *types.Address) *ViewCall {
+	return addressCall("nftAddress", encodeCall(selectorNFTAddress), result)
+}
+
+// TokenStakerCall reads the staker of a token (slot 8)
+func TokenStakerCall(tokenID *big.Int, result *types.Address) *ViewCall {
+	return addressCall("tokenStaker", encodeCall(selectorTokenIDToOwner, tokenID.Bytes()), result)
+}
+
+// AddressToWeightCall reads the weight mapping of an account (slot 9)
+func AddressToWeightCall(address types.Address, result *big.Int) *ViewCall {
+	return uintCall("addressToWeight", encodeCall(selectorAddressToWeight, address.Bytes()), result)
+}
+
+// AccountWeightCall calls the weight getter of an account (slot 9)
+func AccountWeightCall(address types.Address, result *big.Int) *ViewCall {
+	return uintCall("accountWeight", encodeCall(selectorAccountWeight, address.Bytes()), result)
+}
+
+// StakingClient calls the view functions of the staking SC through eth_call
+type StakingClient struct {
+	transport *jsonRPCTransport
+	address   types.Address
+	block     *uint64
+}
+
+// NewStakingClient creates a client for the staking SC at the given address.
+// A nil HTTP client uses a default one with a timeout
+func NewStakingClient(endpoint string, address types.Address, httpClient *http.Client) *StakingClient {
+	return &StakingClient{
+		transport: newJSONRPCTransport(endpoint, httpClient),
+		address:   address,
+	}
+}
+
+// At returns a copy of the client that calls the SC at the given block number
+func (c *StakingClient) At(block uint64) *StakingClient {
+	clientCopy := *c
+	clientCopy.block = &block
+
+	return &clientCopy
+}
+
+// callParams returns the eth_call parameters of the view call
+func (c *StakingClient) callParams(call *ViewCall) []interface{} {
+	return []interface{}{
+		map[string]interface{}{
+			"to":   c.address,
+			"data": hex.EncodeToHex(call.input),
+		},
+		blockParam(c.block),
+	}
+}
+
+// decodeResult decodes the hex encoded return data of the view call
+func decodeResult(call *ViewCall, result string) error {
+	output, err := hex.DecodeHex(result)
+	if err != nil {
+		return fmt.Errorf("%s: %w", call.name, err)
+	}
+
+	if err := call.decode(output); err != nil {
+		return fmt.Errorf("%s: %w", call.name, err)
+	}
+
+	return nil
+}
+
+// Call executes the view calls. A single call is sent as a regular eth_call request,
+// while several calls are sent in one JSON-RPC batch request
+func (c *StakingClient) Call(ctx context.Context, calls ...*ViewCall) error {
+	switch len(calls) {
+	case 0:
+		return nil
+	case 1:
+		var result string
+		if err := c.transport.call(ctx, "eth_call", c.callParams(calls[0]), &result); err != nil {
+			return fmt.Errorf("%s: %w", calls[0].name, err)
+		}
+
+		return decodeResult(calls[0], result)
+	}
+
+	results := make([]string, len(calls))
+	elems := make([]*jsonRPCBatchElem, len(calls))
+
+	for i, call := range calls {
+		elems[i] = &jsonRPCBatchElem{
+			method: "eth_call",
+			params: c.callParams(call),
+			result: &results[i],
+		}
+	}
+
+	if err := c.transport.batchCall(ctx, elems); err != nil {
+		return err
+	}
+
+	for i, call := range calls {
+		if elems[i].err != nil {
+			return fmt.Errorf("%s: %w", call.name, elems[i].err)
+		}
+
+		if err := decodeResult(call, results[i]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Validators returns the validator set
+func (c *StakingClient) Validators(ctx context.Context) ([]types.Address, error) {
+	var validators []types.Address
+
+	if err := c.Call(ctx, ValidatorsCall(&validators)); err != nil {
+		return nil, err
+	}
+
+	return validators, nil
+}
+
+// IsValidator checks if the address is in the validator set
+func (c *StakingClient) IsValidator(ctx context.Context, address types.Address) (bool, error) {
+	var isValidator bool
+
+	if err := c.Call(ctx, IsValidatorCall(address, &isValidator)); err != nil {
+		return false, err
+	}
+
+	return isValidator, nil
+}
+
+// AccountStake returns the staked amount of the address
+func (c *StakingClient) AccountStake(ctx context.Context, address types.Address) (*big.Int, error) {
+	stake := new(big.Int)
+
+	if err := c.Call(ctx, AccountStakeCall(address, stake)); err != nil {
+		return nil, err
+	}
+
+	return stake, nil
+}
+
+// StakedAmount returns the total staked amount
+func (c *StakingClient) StakedAmount(ctx context.Context) (*big.Int, error) {
+	stakedAmount := new(big.Int)
+
+	if err := c.Call(ctx, StakedAmountCall(stakedAmount)); err != nil {
+		return nil, err
+	}
+
+	return stakedAmount, nil
+}
+
+// ValidatorSet returns the validator set with the stakes and weights of the validators.
+// The stakes and weights are read in a single batch request
+func (c *StakingClient) ValidatorSet(ctx context.Context) (*ValidatorSet, error) {
+	validators, err := c.Validators(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	set := &ValidatorSet{
+		Validators: make([]*ValidatorEntry, len(validators)),
+	}
+	calls := make([]*ViewCall, 0, 2*len(validators))
+
+	for i, validator := range validators {
+		entry := &ValidatorEntry{
+			Address: validator,
+			Stake:   new(big.Int),
+			Weight:  new(big.Int),
+		}
+
+		set.Validators[i] = entry
+		calls = append(calls,
+			AccountStakeCall(validator, entry.Stake),
+			AddressToWeightCall(validator, entry.Weight),
+		)
+	}
+
+	if err := c.Call(ctx, calls...); err != nil {
+		return nil, err
+	}
+
+	return set, nil
+}
+
+// Bounds returns the validator count bounds, in a single batch request
+func (c *StakingClient) Bounds(ctx context.Context) (PredeployParams, error) {
+	minimum, maximum := new(big.Int), new(big.Int)
+
+	if err := c.Call(
+		ctx,
+		MinimumNumValidatorsCall(minimum),
+		MaximumNumValidatorsCall(maximum),
+	); err != nil {
+		return PredeployParams{}, err
+	}
+
+	return PredeployParams{
+		MinValidatorCount: minimum.Uint64(),
+		MaxValidatorCount: maximum.Uint64(),
+	}, nil
+}
@@ -0,0 +1,230 @@
+package staking
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/0xPolygon/polygon-edge/helper/hex"
+	"github.com/0xPolygon/polygon-edge/types"
+)
+
+// clientTestNode is a JSON-RPC endpoint answering eth_call requests by function selector.
+// Batch responses are returned in reverse order
+type clientTestNode struct {
+	t       *testing.T
+	results map[string]string
+	errors  map[string]*JSONRPCError
+	blocks  []string
+	batches int
+}
+
+func (n *clientTestNode) answer(request jsonRPCRequest) jsonRPCResponse {
+	response := jsonRPCResponse{ID: request.ID}
+
+	if request.Method != "eth_call" || len(request.Params) != 2 {
+		n.t.Errorf("unexpected request %s with %d params", request.Method, len(request.Params))
+
+		return response
+	}
+
+	block, _ := request.Params[1].(string)
+	n.blocks = append(n.blocks, block)
+
+	call, _ := request.Params[0].(map[string]interface{})
+	data, _ := call["data"].(string)
+	selector := strings.TrimPrefix(data, "0x")[:8]
+
+	if err, ok := n.errors[selector]; ok {
+		response.Error = err
+
+		return response
+	}
+
+	result, _ := json.Marshal(n.results[selector])
+	response.Result = result
+
+	return response
+}
+
+func (n *clientTestNode) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var body json.RawMessage
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		n.t.Errorf("unable to decode the request, %v", err)
+
+		return
+	}
+
+	var response interface{}
+
+	if strings.HasPrefix(strings.TrimSpace(string(body)), "[") {
+		var requests []jsonRPCRequest
+		if err := json.Unmarshal(body, &requests); err != nil {
+			n.t.Errorf("unable to decode the batch, %v", err)
+
+			return
+		}
+
+		n.batches++
+
+		responses := make([]jsonRPCResponse, 0, len(requests))
+		for i := len(requests) - 1; i >= 0; i-- {
+			responses = append(responses, n.answer(requests[i]))
+		}
+
+		response = responses
+	} else {
+		var request jsonRPCRequest
+		if err := json.Unmarshal(body, &request); err != nil {
+			n.t.Errorf("unable to decode the request, %v", err)
+
+			return
+		}
+
+		response = n.answer(request)
+	}
+
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		n.t.Errorf("unable to encode the response, %v", err)
+	}
+}
+
+func newClientTestNode(t *testing.T) (*clientTestNode, *StakingClient) {
+	t.Helper()
+
+	node := &clientTestNode{
+		t:       t,
+		results: make(map[string]string),
+		errors:  make(map[string]*JSONRPCError),
+	}
+
+	server := httptest.NewServer(node)
+	t.Cleanup(server.Close)
+
+	return node, NewStakingClient(server.URL, DefaultStakingSCAddress, nil)
+}
+
+func clientTestWord(value int64) []byte {
+	return types.BytesToHash(big.NewInt(value).Bytes()).Bytes()
+}
+
+func TestStakingClientCall(t *testing.T) {
+	var (
+		validatorA = types.StringToAddress("0x11")
+		validatorB = types.StringToAddress("0x22")
+	)
+
+	node, client := newClientTestNode(t)
+
+	validators := clientTestWord(32)
+	validators = append(validators, clientTestWord(2)...)
+	validators = append(validators, types.BytesToHash(validatorA.Bytes()).Bytes()...)
+	validators = append(validators, types.BytesToHash(validatorB.Bytes()).Bytes()...)
+
+	node.results[hex.EncodeToString(selectorValidators)] = hex.EncodeToHex(validators)
+	node.results[hex.EncodeToString(selectorIsValidator)] = hex.EncodeToHex(clientTestWord(1))
+	node.results[hex.EncodeToString(selectorMinimumNumValidators)] = hex.EncodeToHex(clientTestWord(1))
+	node.results[hex.EncodeToString(selectorMaximumNumValidators)] = hex.EncodeToHex(clientTestWord(7))
+	node.results[hex.EncodeToString(selectorAccountStake)] = hex.EncodeToHex(clientTestWord(99))
+
+	ctx := context.Background()
+
+	t.Run("single call", func(t *testing.T) {
+		got, err := client.Validators(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(got) != 2 || got[0] != validatorA || got[1] != validatorB {
+			t.Fatalf("expected validators %s and %s, got %v", validatorA, validatorB, got)
+		}
+
+		isValidator, err := client.At(16).IsValidator(ctx, validatorA)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !isValidator {
+			t.Fatalf("expected %s to be a validator", validatorA)
+		}
+
+		if want := []string{"latest", "0x10"}; strings.Join(node.blocks, ",") != strings.Join(want, ",") {
+			t.Fatalf("expected the block params %v, got %v", want, node.blocks)
+		}
+	})
+
+	t.Run("out of order batch", func(t *testing.T) {
+		var (
+			minimum = new(big.Int)
+			maximum = new(big.Int)
+			stake   = new(big.Int)
+		)
+
+		// The node answers the batch in reverse order, the responses are matched by id
+		if err := client.Call(
+			ctx,
+			MinimumNumValidatorsCall(minimum),
+			MaximumNumValidatorsCall(maximum),
+			AccountStakeCall(validatorA, stake),
+		); err != nil {
+			t.Fatal(err)
+		}
+
+		if minimum.Int64() != 1 || maximum.Int64() != 7 || stake.Int64() != 99 {
+			t.Fatalf("expected 1, 7 and 99, got %s, %s and %s", minimum, maximum, stake)
+		}
+
+		if node.batches != 1 {
+			t.Fatalf("expected a single batch, got %d", node.batches)
+		}
+	})
+}
+
+func TestStakingClientErrors(t *testing.T) {
+	node, client := newClientTestNode(t)
+
+	node.errors[hex.EncodeToString(selectorAccountStake)] = &JSONRPCError{
+		Code:    -32000,
+		Message: "execution reverted",
+	}
+	node.results[hex.EncodeToString(selectorMinimumNumValidators)] = hex.EncodeToHex(clientTestWord(1))
+	// A return word must be 32 bytes long
+	node.results[hex.EncodeToString(selectorMaximumNumValidators)] = "0x0102"
+
+	ctx := context.Background()
+	stake := new(big.Int)
+
+	t.Run("json-rpc error", func(t *testing.T) {
+		var rpcErr *JSONRPCError
+		if _, err := client.AccountStake(ctx, types.StringToAddress("0x1")); !errors.As(err, &rpcErr) {
+			t.Fatalf("expected a JSONRPCError, got %v", err)
+		}
+
+		if rpcErr.Code != -32000 {
+			t.Fatalf("expected the error code -32000, got %d", rpcErr.Code)
+		}
+
+		if err := client.Call(
+			ctx,
+			MinimumNumValidatorsCall(new(big.Int)),
+			AccountStakeCall(types.StringToAddress("0x1"), stake),
+		); !errors.As(err, &rpcErr) {
+			t.Fatalf("expected a JSONRPCError from the batch, got %v", err)
+		}
+	})
+
+	t.Run("malformed return word", func(t *testing.T) {
+		if _, err := client.Bounds(ctx); !errors.Is(err, errInvalidReturnData) {
+			t.Fatalf("expected errInvalidReturnData, got %v", err)
+		}
+
+		if err := client.Call(ctx, MaximumNumValidatorsCall(new(big.Int))); !errors.Is(err, errInvalidReturnData) {
+			t.Fatalf("expected errInvalidReturnData, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,255 @@
+package staking
+
+import (
+	"errors"
+	"math/big"
+
+	"github.com/0xPolygon/polygon-edge/helper/common"
+	"github.com/0xPolygon/polygon-edge/helper/keccak"
+	"github.com/0xPolygon/polygon-edge/types"
+)
+
+// Domain separation prefixes of the validator set Merkle tree
+const (
+	merkleLeafPrefix = byte(0x00)
+	merkleNodePrefix = byte(0x01)
+)
+
+var (
+	errValidatorIndexOutOfRange    = errors.New("validator index out of range")
+	errInvalidValidatorSetEncoding = errors.New("invalid validator set encoding")
+)
+
+// ValidatorEntry is a single validator of the staking SC, with its stake and weight
+type ValidatorEntry struct {
+	Address types.Address
+	Stake   *big.Int
+	Weight  *big.Int
+}
+
+// Encode returns the canonical encoding of the entry,
+// abi.encodePacked(address, uint256 stake, uint256 weight).
+// A nil stake or weight is encoded as zero, the value of a missing storage slot
+func (e *ValidatorEntry) Encode() []byte {
+	encoded := make([]byte, 0, types.AddressLength+64)
+	encoded = append(encoded, e.Address.Bytes()...)
+	encoded = append(encoded, encodeUint256(e.Stake)...)
+	encoded = append(encoded, encodeUint256(e.Weight)...)
+
+	return encoded
+}
+
+// encodeUint256 returns the 32 byte big-endian encoding of the value, zero if it's nil
+func encodeUint256(value *big.Int) []byte {
+	if value == nil {
+		return make([]byte, 32)
+	}
+
+	return common.PadLeftOrTrim(value.Bytes(), 32)
+}
+
+// leafHash returns the Merkle tree leaf of the entry
+func (e *ValidatorEntry) leafHash() types.Hash {
+	return types.BytesToHash(keccak.Keccak256(nil, append([]byte{merkleLeafPrefix}, e.Encode()...)))
+}
+
+// ValidatorSet is the validator set of the staking SC, in _validators order
+type ValidatorSet struct {
+	Validators []*ValidatorEntry
+}
+
+// ReadValidatorSet reads the validator set from the staking SC storage.
+// Addresses are read from _validators (slot 0), stakes from
+// _addressToStakedAmount (slot 2) and weights from _addressToWeight (slot 9)
+func ReadValidatorSet(reader StateReader) (*ValidatorSet, error) {
+	view := NewStakingView(reader)
+
+	validators, err := view.Validators()
+	if err != nil {
+		return nil, err
+	}
+
+	set := &ValidatorSet{
+		Validators: make([]*ValidatorEntry, len(validators)),
+	}
+
+	for i, validator := range validators {
+		entry := &ValidatorEntry{
+			Address: validator,
+		}
+
+		if entry.Stake, err = view.StakeOf(validator); err != nil {
+			return nil, err
+		}
+
+		if entry.Weight, err = view.WeightOf(validator); err != nil {
+			return nil, err
+		}
+
+		set.Validators[i] = entry
+	}
+
+	return set, nil
+}
+
+// Encode returns the canonical encoding of the validator set:
+// the validator count as uint256, followed by the encoded entries
+func (s *ValidatorSet) Encode() []byte {
+	encoded := common.PadLeftOrTrim(big.NewInt(int64(len(s.Validators))).Bytes(), 32)
+
+	for _, entry := range s.Validators {
+		encoded = append(encoded, entry.Encode()...)
+	}
+
+	return encoded
+}
+
+// DecodeValidatorSet decodes a validator set from its canonical encoding
+func DecodeValidatorSet(encoded []byte) (*ValidatorSet, error) {
+	const entrySize = types.AddressLength + 64
+
+	if len(encoded) < 32 {
+		return nil, errInvalidValidatorSetEncoding
+	}
+
+	count := new(big.Int).SetBytes(encoded[:32])
+	if !count.IsUint64() || count.Uint64()*entrySize != uint64(len(encoded)-32) {
+		return nil, errInvalidValidatorSetEncoding
+	}
+
+	set := &ValidatorSet{
+		Validators: make([]*ValidatorEntry, count.Uint64()),
+	}
+
+	for i := range set.Validators {
+		entry := encoded[32+i*entrySize : 32+(i+1)*entrySize]
+
+		set.Validators[i] = &ValidatorEntry{
+			Address: types.BytesToAddress(entry[:types.AddressLength]),
+			Stake:   new(big.Int).SetBytes(entry[types.AddressLength : types.AddressLength+32]),
+			Weight:  new(big.Int).SetBytes(entry[types.AddressLength+32:]),
+		}
+	}
+
+	return set, nil
+}
+
+// Hash returns the keccak hash of the canonical encoding of the validator set
+func (s *ValidatorSet) Hash() types.Hash {
+	return types.BytesToHash(keccak.Keccak256(nil, s.Encode()))
+}
+
+// hashMerkleNode hashes two sibling nodes of the Merkle tree
+func hashMerkleNode(left, right types.Hash) types.Hash {
+	buf := make([]byte, 0, 1+2*types.HashLength)
+	buf = append(buf, merkleNodePrefix)
+	buf = append(buf, left.Bytes()...)
+	buf = append(buf, right.Bytes()...)
+
+	return types.BytesToHash(keccak.Keccak256(nil, buf))
+}
+
+// merkleLevels builds every level of the Merkle tree, from the leaves up to the root.
+// A node without a sibling is carried up to the next level unchanged
+func (s *ValidatorSet) merkleLevels() [][]types.Hash {
+	level := make([]types.Hash, len(s.Validators))
+	for i, entry := range s.Validators {
+		level[i] = entry.leafHash()
+	}
+
+	levels := [][]types.Hash{level}
+
+	for len(level) > 1 {
+		next := make([]types.Hash, 0, (len(level)+1)/2)
+
+		for i := 0; i < len(level); i += 2 {
+			if i+1 == len(level) {
+				next = append(next, level[i])
+			} else {
+				next = append(next, hashMerkleNode(level[i], level[i+1]))
+			}
+		}
+
+		levels = append(levels, next)
+		level = next
+	}
+
+	return levels
+}
+
+// MerkleRoot returns the root of the Merkle tree over the validator entries.
+// The root of an empty set is the zero hash
+func (s *ValidatorSet) MerkleRoot() types.Hash {
+	if len(s.Validators) == 0 {
+		return types.ZeroHash
+	}
+
+	levels := s.merkleLevels()
+
+	return levels[len(levels)-1][0]
+}
+
+// ValidatorInclusionProof proves that a validator entry is part of a validator set Merkle root
+type ValidatorInclusionProof struct {
+	Index    uint64
+	Count    uint64
+	Siblings []types.Hash
+}
+
+// InclusionProof generates the Merkle proof of the validator at the given index
+func (s *ValidatorSet) InclusionProof(index int) (*ValidatorInclusionProof, error) {
+	if index < 0 || index >= len(s.Validators) {
+		return nil, errValidatorIndexOutOfRange
+	}
+
+	proof := &ValidatorInclusionProof{
+		Index: uint64(index),
+		Count: uint64(len(s.Validators)),
+	}
+
+	levels := s.merkleLevels()
+	position := index
+
+	for _, level := range levels[:len(levels)-1] {
+		sibling := position ^ 1
+		if sibling < len(level) {
+			proof.Siblings = append(proof.Siblings, level[sibling])
+		}
+
+		position /= 2
+	}
+
+	return proof, nil
+}
+
+// VerifyValidatorInclusion checks that the entry is part of the validator set with the given Merkle root
+func VerifyValidatorInclusion(root types.Hash, entry *ValidatorEntry, proof *ValidatorInclusionProof) bool {
+	if proof.Index >= proof.Count {
+		return false
+	}
+
+	hash := entry.leafHash()
+	position, width := proof.Index, proof.Count
+	siblings := proof.Siblings
+
+	for width > 1 {
+		if position^1 < width {
+			if len(siblings) == 0 {
+				return false
+			}
+
+			if position%2 == 0 {
+				hash = hashMerkleNode(hash, siblings[0])
+			} else {
+				hash = hashMerkleNode(siblings[0], hash)
+			}
+
+			siblings = siblings[1:]
+		}
+
+		position /= 2
+		width = (width + 1) / 2
+	}
+
+	return len(siblings) == 0 && hash == root
+}
@@ -0,0 +1,86 @@
+package staking
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/0xPolygon/polygon-edge/types"
+)
+
+func TestValidatorEntryEncodeNilValues(t *testing.T) {
+	address := types.StringToAddress("0x1")
+
+	zero := (&ValidatorEntry{Address: address, Stake: big.NewInt(0), Weight: big.NewInt(0)}).Encode()
+
+	for _, entry := range []*ValidatorEntry{
+		{Address: address},
+		{Address: address, Stake: big.NewInt(0)},
+		{Address: address, Weight: big.NewInt(0)},
+	} {
+		if encoded := entry.Encode(); !bytes.Equal(encoded, zero) {
+			t.Errorf("expected nil values to encode as zero, got %x", encoded)
+		}
+	}
+
+	set := &ValidatorSet{Validators: []*ValidatorEntry{{Address: address}}}
+	root := set.MerkleRoot()
+
+	proof, err := set.InclusionProof(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !VerifyValidatorInclusion(root, set.Validators[0], proof) {
+		t.Fatal("entry with nil values isn't included in the root")
+	}
+}
+
+func TestValidatorSetInclusionProof(t *testing.T) {
+	for count := 1; count <= 17; count++ {
+		account, err := PredeployStakingSC(generatorValidators(count), PredeployParams{
+			MinValidatorCount: 1,
+			MaxValidatorCount: 100,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		set, err := ReadValidatorSet(StorageMap(account.Storage))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(set.Validators) != count {
+			t.Fatalf("expected %d validators, got %d", count, len(set.Validators))
+		}
+
+		root := set.MerkleRoot()
+
+		for i, entry := range set.Validators {
+			proof, err := set.InclusionProof(i)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !VerifyValidatorInclusion(root, entry, proof) {
+				t.Fatalf("%d validators: entry %d isn't included", count, i)
+			}
+
+			tampered := *entry
+			tampered.Stake = new(big.Int).Add(entry.Stake, big.NewInt(1))
+
+			if VerifyValidatorInclusion(root, &tampered, proof) {
+				t.Fatalf("%d validators: tampered entry %d is included", count, i)
+			}
+
+			if count > 1 {
+				proof.Index = uint64((i + 1) % count)
+
+				if VerifyValidatorInclusion(root, entry, proof) {
+					t.Fatalf("%d validators: entry %d is included at index %d", count, i, proof.Index)
+				}
+			}
+		}
+	}
+}
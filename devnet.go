@@ -0,0 +1,446 @@
+package staking
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+
+	"github.com/0xPolygon/polygon-edge/chain"
+	"github.com/0xPolygon/polygon-edge/crypto"
+	"github.com/0xPolygon/polygon-edge/helper/keccak"
+	"github.com/0xPolygon/polygon-edge/secrets"
+	"github.com/0xPolygon/polygon-edge/types"
+	libp2pCrypto "github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// Defaults of the devnet generator
+const (
+	DefaultDevnetName          = "devnet"
+	DefaultDevnetChainID       = 100
+	DefaultDevnetGasLimit      = 5242880
+	DefaultDevnetDataDirPrefix = "test-chain-"
+	DefaultDevnetBalance       = "0x3635C9ADC5DEA00000" // 1000 ETH
+	DefaultDevnetLibp2pHost    = "127.0.0.1"
+	DefaultDevnetLibp2pPort    = 1478
+)
+
+// Purposes of the key material derived from the devnet seed
+const (
+	devnetECDSAPurpose   = "ecdsa"
+	devnetBLSPurpose     = "bls"
+	devnetNetworkPurpose = "libp2p"
+)
+
+// libp2pSecp256k1KeyPrefix is the protobuf header of a marshaled libp2p secp256k1
+// private key: field 1 (key type) set to 2 (secp256k1), field 2 (data) of 32 bytes
+var libp2pSecp256k1KeyPrefix = []byte{0x08, 0x02, 0x12, 0x20}
+
+// secp256k1N is the order of the secp256k1 curve
+var secp256k1N, _ = new(big.Int).SetString(
+	"fffffffffffffffffffffffffffffffebaaedce6af48a03bbfd25e8cd0364141",
+	16,
+)
+
+var (
+	errEmptyDevnetSeed    = errors.New("devnet seed is empty")
+	errDevnetValidatorNum = errors.New("devnet needs at least one validator")
+)
+
+// DevnetConfig describes a local PoS devnet
+type DevnetConfig struct {
+	// Seed is the seed every key is derived from.
+	// The same seed and config always produce the same keys and genesis
+	Seed string
+
+	// Count is the number of validators
+	Count int
+
+	// BLS generates BLS keys, and makes the validators BLS validators
+	BLS bool
+
+	// Name is the chain name. DefaultDevnetName is used if it's not set
+	Name string
+
+	// ChainID is the chain ID. DefaultDevnetChainID is used if it's not set
+	ChainID int
+
+	// Params are the validator count bounds and the storage mode of the staking SC.
+	// The bounds default to [1, Count]
+	Params PredeployParams
+
+	// TokensPerValidator is the number of mock ERC721 tokens staked by every validator.
+	// Validator i stakes the token IDs i*TokensPerValidator+1 up to (i+1)*TokensPerValidator.
+	// Every validator stakes a single token if it's not set, as the staking SC counts
+	// the stake in tokens and the mock ERC721 SC has to be deployed
+	TokensPerValidator int
+
+	// Libp2pHost is the IPv4 address the validators listen on for libp2p.
+	// DefaultDevnetLibp2pHost is used if it's not set
+	Libp2pHost string
+
+	// Libp2pPort is the libp2p port of the first validator, validator i listens on Libp2pPort+i.
+	// DefaultDevnetLibp2pPort is used if it's not set
+	Libp2pPort int
+
+	// Balance is the native balance of every validator account.
+	// DefaultDevnetBalance is used if it's not set
+	Balance *big.Int
+}
+
+// DevnetValidator is a validator of the devnet, with its derived keys
+type DevnetValidator struct {
+	Address types.Address
+
+	// ECDSAKey is the validator key, 32 bytes
+	ECDSAKey []byte
+
+	// BLSKey and BLSPublicKey are nil if the devnet has no BLS keys
+	BLSKey       []byte
+	BLSPublicKey []byte
+
+	// NetworkKey is the marshaled libp2p key, PeerID the libp2p peer ID derived from it
+	NetworkKey []byte
+	PeerID     peer.ID
+
+	// Multiaddr is the libp2p address of the validator, in the bootnode format
+	Multiaddr string
+
+	TokenIDs []*big.Int
+}
+
+// Devnet is a generated devnet, its validators and its chain config.
+// Every validator is a bootnode of the chain config
+type Devnet struct {
+	Validators  []*DevnetValidator
+	Chain       *chain.Chain
+	GenesisHash types.Hash
+}
+
+// deriveDevnetKey derives 32 bytes of key material for the validator at the given index.
+// The counter is bumped until the key is accepted
+func deriveDevnetKey(seed, purpose string, index int, accept func([]byte) bool) []byte {
+	var numbers [16]byte
+
+	binary.BigEndian.PutUint64(numbers[:8], uint64(index))
+
+	for counter := uint64(0); ; counter++ {
+		binary.BigEndian.PutUint64(numbers[8:], counter)
+
+		input := make([]byte, 0, len(seed)+len(purpose)+len(numbers)+2)
+		input = append(input, seed...)
+		input = append(input, 0)
+		input = append(input, purpose...)
+		input = append(input, 0)
+		input = append(input, numbers[:]...)
+
+		key := keccak.Keccak256(nil, input)
+		if accept(key) {
+			return key
+		}
+	}
+}
+
+// validECDSAKey checks that the key is a valid secp256k1 scalar
+func validECDSAKey(key []byte) bool {
+	scalar := new(big.Int).SetBytes(key)
+
+	return scalar.Sign() > 0 && scalar.Cmp(secp256k1N) < 0
+}
+
+// deriveBLSKey derives a BLS secret key. The top two bits of both the first and the last
+// byte are cleared, so the scalar is below the BLS12-381 group order in either byte order
+func deriveBLSKey(seed string, index int) []byte {
+	return deriveDevnetKey(seed, devnetBLSPurpose, index, func(key []byte) bool {
+		key[0] &= 0x3f
+		key[len(key)-1] &= 0x3f
+
+		return new(big.Int).SetBytes(key).Sign() > 0
+	})
+}
+
+// newDevnetValidator derives the keys of the validator at the given index
+func newDevnetValidator(config *DevnetConfig, index int) (*DevnetValidator, error) {
+	validator := &DevnetValidator{
+		ECDSAKey: deriveDevnetKey(config.Seed, devnetECDSAPurpose, index, validECDSAKey),
+		NetworkKey: append(
+			append([]byte{}, libp2pSecp256k1KeyPrefix...),
+			deriveDevnetKey(config.Seed, devnetNetworkPurpose, index, validECDSAKey)...,
+		),
+	}
+
+	key, err := crypto.BytesToECDSAPrivateKey([]byte(hex.EncodeToString(validator.ECDSAKey)))
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse the key of validator %d, %w", index, err)
+	}
+
+	validator.Address = crypto.PubKeyToAddress(&key.PublicKey)
+
+	networkKey, err := libp2pCrypto.UnmarshalPrivateKey(validator.NetworkKey)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse the network key of validator %d, %w", index, err)
+	}
+
+	if validator.PeerID, err = peer.IDFromPrivateKey(networkKey); err != nil {
+		return nil, fmt.Errorf("unable to derive the peer ID of validator %d, %w", index, err)
+	}
+
+	validator.Multiaddr = fmt.Sprintf(
+		"/ip4/%s/tcp/%d/p2p/%s",
+		config.Libp2pHost,
+		config.Libp2pPort+index,
+		validator.PeerID,
+	)
+
+	if config.BLS {
+		validator.BLSKey = deriveBLSKey(config.Seed, index)
+
+		blsKey, err := crypto.BytesToBLSSecretKey([]byte(hex.EncodeToString(validator.BLSKey)))
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse the BLS key of validator %d, %w", index, err)
+		}
+
+		if validator.BLSPublicKey, err = crypto.BLSSecretKeyToPubkeyBytes(blsKey); err != nil {
+			return nil, fmt.Errorf("unable to derive the BLS public key of validator %d, %w", index, err)
+		}
+	}
+
+	for i := 0; i < config.TokensPerValidator; i++ {
+		validator.TokenIDs = append(
+			validator.TokenIDs,
+			big.NewInt(int64(index*config.TokensPerValidator+i+1)),
+		)
+	}
+
+	return validator, nil
+}
+
+// GenerateDevnet derives the validator keys from the seed, and builds the devnet genesis:
+// the IBFT extra data of the validators, the staking SC with the validators staked in index order,
+// the mock ERC721 SC holding the staked tokens, the funded validator accounts and the bootnodes
+func GenerateDevnet(config DevnetConfig) (*Devnet, error) {
+	if config.Seed == "" {
+		return nil, errEmptyDevnetSeed
+	}
+
+	if config.Count < 1 {
+		return nil, errDevnetValidatorNum
+	}
+
+	if config.TokensPerValidator < 0 {
+		return nil, fmt.Errorf("invalid number of tokens per validator %d", config.TokensPerValidator)
+	}
+
+	if config.TokensPerValidator == 0 {
+		config.TokensPerValidator = 1
+	}
+
+	if config.Libp2pHost == "" {
+		config.Libp2pHost = DefaultDevnetLibp2pHost
+	}
+
+	if ip := net.ParseIP(config.Libp2pHost); ip == nil || ip.To4() == nil {
+		return nil, fmt.Errorf("invalid libp2p host %q, expected an IPv4 address", config.Libp2pHost)
+	}
+
+	if config.Libp2pPort == 0 {
+		config.Libp2pPort = DefaultDevnetLibp2pPort
+	}
+
+	if config.Libp2pPort < 0 || config.Libp2pPort+config.Count-1 > 65535 {
+		return nil, fmt.Errorf("invalid libp2p port %d for %d validators", config.Libp2pPort, config.Count)
+	}
+
+	if config.Name == "" {
+		config.Name = DefaultDevnetName
+	}
+
+	if config.ChainID == 0 {
+		config.ChainID = DefaultDevnetChainID
+	}
+
+	if config.Params.MinValidatorCount == 0 {
+		config.Params.MinValidatorCount = 1
+	}
+
+	if config.Params.MaxValidatorCount == 0 {
+		config.Params.MaxValidatorCount = uint64(config.Count)
+	}
+
+	if config.Balance == nil {
+		val := DefaultDevnetBalance
+
+		balance, err := types.ParseUint256orHex(&val)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse DefaultDevnetBalance, %w", err)
+		}
+
+		config.Balance = balance
+	}
+
+	devnet := &Devnet{
+		Validators: make([]*DevnetValidator, config.Count),
+	}
+
+	spec := StakingGenesisSpec{
+		Params:       config.Params,
+		Validators:   make([]types.Address, config.Count),
+		Balances:     make(map[types.Address]*big.Int, config.Count),
+		StakedTokens: make(map[types.Address][]*big.Int, config.Count),
+	}
+
+	// The validators stay in index order, the order of the extra data
+	spec.Params.Ordering = OrderByInput
+
+	var blsPublicKeys [][]byte
+	if config.BLS {
+		blsPublicKeys = make([][]byte, config.Count)
+	}
+
+	bootnodes := make([]string, config.Count)
+
+	for i := range devnet.Validators {
+		validator, err := newDevnetValidator(&config, i)
+		if err != nil {
+			return nil, err
+		}
+
+		devnet.Validators[i] = validator
+		spec.Validators[i] = validator.Address
+		spec.Balances[validator.Address] = new(big.Int).Set(config.Balance)
+		spec.StakedTokens[validator.Address] = validator.TokenIDs
+		bootnodes[i] = validator.Multiaddr
+
+		if config.BLS {
+			blsPublicKeys[i] = validator.BLSPublicKey
+		}
+	}
+
+	extraData, err := EncodeIBFTExtra(spec.Validators, blsPublicKeys)
+	if err != nil {
+		return nil, err
+	}
+
+	validatorType := "ecdsa"
+	if config.BLS {
+		validatorType = "bls"
+	}
+
+	params := &chain.Params{
+		Forks:   chain.AllForksEnabled,
+		ChainID: config.ChainID,
+		Engine: map[string]interface{}{
+			IBFTEngineName: map[string]interface{}{
+				engineTypeKey:              enginePoSType,
+				engineValidatorTypeKey:     validatorType,
+				engineMinValidatorCountKey: config.Params.MinValidatorCount,
+				engineMaxValidatorCountKey: config.Params.MaxValidatorCount,
+				ValidatorOrderingKey:       spec.Params.Ordering.String(),
+			},
+		},
+	}
+
+	genesis := &chain.Genesis{
+		Config:     params,
+		ExtraData:  extraData,
+		GasLimit:   DefaultDevnetGasLimit,
+		Difficulty: 1,
+		Mixhash:    IBFTMixDigest,
+		Alloc:      make(map[types.Address]*chain.GenesisAccount),
+	}
+
+	if err := ApplyStakingGenesis(genesis, spec); err != nil {
+		return nil, err
+	}
+
+	report, err := CheckGenesisExtraData(genesis, spec.stakingAddress())
+	if err != nil {
+		return nil, err
+	}
+
+	if !report.Consistent() {
+		return nil, fmt.Errorf("devnet extra data doesn't match the staking SC:\n%s", report)
+	}
+
+	if _, devnet.GenesisHash, err = GenesisRoots(genesis); err != nil {
+		return nil, err
+	}
+
+	devnet.Chain = &chain.Chain{
+		Name:      config.Name,
+		Genesis:   genesis,
+		Params:    params,
+		Bootnodes: bootnodes,
+	}
+
+	return devnet, nil
+}
+
+// writeSecret writes a hex encoded key, the way polygon-edge's local secrets manager stores it
+func writeSecret(dir, name string, key []byte) error {
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return fmt.Errorf("unable to create %s, %w", dir, err)
+	}
+
+	path := filepath.Join(dir, name)
+
+	if err := os.WriteFile(path, []byte(hex.EncodeToString(key)), 0600); err != nil {
+		return fmt.Errorf("unable to write %s, %w", path, err)
+	}
+
+	return nil
+}
+
+// WriteSecrets writes the secrets directory of every validator under the given directory,
+// in polygon-edge's local secrets layout. The data directories are named
+// DefaultDevnetDataDirPrefix followed by the 1-based validator index, and are returned in index order
+func (d *Devnet) WriteSecrets(dir string) ([]string, error) {
+	dataDirs := make([]string, len(d.Validators))
+
+	for i, validator := range d.Validators {
+		dataDir := filepath.Join(dir, fmt.Sprintf("%s%d", DefaultDevnetDataDirPrefix, i+1))
+		consensusDir := filepath.Join(dataDir, secrets.ConsensusFolderLocal)
+
+		if err := writeSecret(consensusDir, secrets.ValidatorKeyLocal, validator.ECDSAKey); err != nil {
+			return nil, err
+		}
+
+		if validator.BLSKey != nil {
+			if err := writeSecret(consensusDir, secrets.ValidatorBLSKeyLocal, validator.BLSKey); err != nil {
+				return nil, err
+			}
+		}
+
+		if err := writeSecret(
+			filepath.Join(dataDir, secrets.NetworkFolderLocal),
+			secrets.NetworkKeyLocal,
+			validator.NetworkKey,
+		); err != nil {
+			return nil, err
+		}
+
+		dataDirs[i] = dataDir
+	}
+
+	return dataDirs, nil
+}
+
+// WriteGenesis writes the chain config to the given path, in the genesis.json format of polygon-edge
+func (d *Devnet) WriteGenesis(path string) error {
+	encoded, err := json.MarshalIndent(d.Chain, "", "    ")
+	if err != nil {
+		return fmt.Errorf("unable to encode the genesis, %w", err)
+	}
+
+	if err := os.WriteFile(path, encoded, 0600); err != nil {
+		return fmt.Errorf("unable to write %s, %w", path, err)
+	}
+
+	return nil
+}
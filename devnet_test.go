@@ -0,0 +1,197 @@
+package staking
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/0xPolygon/polygon-edge/types"
+)
+
+func TestGenerateDevnetDeterministic(t *testing.T) {
+	config := DevnetConfig{
+		Seed:  "devnet-test",
+		Count: 4,
+		BLS:   true,
+	}
+
+	first, err := GenerateDevnet(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	second, err := GenerateDevnet(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if first.GenesisHash != second.GenesisHash {
+		t.Fatalf("expected the same genesis hash, got %s and %s", first.GenesisHash, second.GenesisHash)
+	}
+
+	if len(first.Chain.Bootnodes) != config.Count {
+		t.Fatalf("expected %d bootnodes, got %d", config.Count, len(first.Chain.Bootnodes))
+	}
+
+	for i, validator := range first.Validators {
+		if validator.Address != second.Validators[i].Address || validator.PeerID != second.Validators[i].PeerID {
+			t.Fatalf("validator %d has different keys for the same seed", i)
+		}
+
+		if !strings.HasSuffix(first.Chain.Bootnodes[i], "/p2p/"+validator.PeerID.String()) {
+			t.Errorf("bootnode %s doesn't end with the peer ID %s", first.Chain.Bootnodes[i], validator.PeerID)
+		}
+	}
+}
+
+func TestGenerateDevnetDefaultTokens(t *testing.T) {
+	devnet, err := GenerateDevnet(DevnetConfig{
+		Seed:  "devnet-test",
+		Count: 2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Every validator stakes a single token when TokensPerValidator isn't set
+	nft, ok := devnet.Chain.Genesis.Alloc[DefaultNFTSCAddress]
+	if !ok || len(nft.Code) == 0 {
+		t.Fatal("expected the mock ERC721 SC to be deployed")
+	}
+
+	view := NewStakingView(NewGenesisAccountReader(devnet.Chain.Genesis.Alloc[DefaultStakingSCAddress]))
+
+	for i, validator := range devnet.Validators {
+		if len(validator.TokenIDs) != 1 || validator.TokenIDs[0].Int64() != int64(i+1) {
+			t.Fatalf("expected validator %d to stake token %d, got %v", i, i+1, validator.TokenIDs)
+		}
+
+		stake, err := view.StakeOf(validator.Address)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if stake.Int64() != 1 {
+			t.Errorf("expected a stake of 1 token for validator %d, got %s", i, stake)
+		}
+	}
+}
+
+// The expected devnet values are derived independently of polygon-edge: the keys with keccak256
+// over the seed, the addresses and the peer IDs with secp256k1, and the state root with
+// a Merkle Patricia trie over the genesis alloc
+func TestGenerateDevnetKnownValues(t *testing.T) {
+	devnet, err := GenerateDevnet(DevnetConfig{
+		Seed:  "devnet-test",
+		Count: 2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []struct {
+		ecdsaKey string
+		address  types.Address
+		peerID   string
+	}{
+		{
+			ecdsaKey: "f50bd46f42a52ea1ab471c1a3c0fad754d87ac73265e290d69fd8358e5ac18ae",
+			address:  types.StringToAddress("0xca7fb1b2404e0a792620ad5c5ee5465bbc2fd5b5"),
+			peerID:   "16Uiu2HAmSczEDQtYDBVGqNALBj6Jmqu44NYqgzXffggTGqgTn2dF",
+		},
+		{
+			ecdsaKey: "bac2684952223b7a0026b948d25abdab06f2d397eca9bf370fb79756b562031d",
+			address:  types.StringToAddress("0x90e8b2db6b07ec85e410e787ff776b7d492b637f"),
+			peerID:   "16Uiu2HAmA5aAcSkhdDVjTNAbHGRGsn7d2mH9komXJZM2w7XH5uVt",
+		},
+	}
+
+	for i, validator := range devnet.Validators {
+		if got := hex.EncodeToString(validator.ECDSAKey); got != expected[i].ecdsaKey {
+			t.Fatalf("validator %d: expected the key %s, got %s", i, expected[i].ecdsaKey, got)
+		}
+
+		if validator.Address != expected[i].address {
+			t.Fatalf("validator %d: expected the address %s, got %s", i, expected[i].address, validator.Address)
+		}
+
+		if validator.PeerID.String() != expected[i].peerID {
+			t.Fatalf("validator %d: expected the peer ID %s, got %s", i, expected[i].peerID, validator.PeerID)
+		}
+	}
+
+	stateRoot, genesisHash, err := GenesisRoots(devnet.Chain.Genesis)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedRoot := types.StringToHash("0xadabd422de1106e7a9a232fb8cd3f9d9b553e61b5665dc210200f1f3374f73ca")
+	if stateRoot != expectedRoot {
+		t.Fatalf("expected the state root %s, got %s", expectedRoot, stateRoot)
+	}
+
+	if devnet.GenesisHash != genesisHash {
+		t.Fatalf("expected the genesis hash %s, got %s", genesisHash, devnet.GenesisHash)
+	}
+}
+
+func TestDevnetWriteSecrets(t *testing.T) {
+	devnet, err := GenerateDevnet(DevnetConfig{
+		Seed:  "devnet-test",
+		Count: 3,
+		BLS:   true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+
+	dataDirs, err := devnet.WriteSecrets(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(dataDirs) != len(devnet.Validators) {
+		t.Fatalf("expected %d data directories, got %d", len(devnet.Validators), len(dataDirs))
+	}
+
+	for i, dataDir := range dataDirs {
+		if expected := filepath.Join(dir, fmt.Sprintf("%s%d", DefaultDevnetDataDirPrefix, i+1)); dataDir != expected {
+			t.Fatalf("expected the data directory %s, got %s", expected, dataDir)
+		}
+	}
+
+	validators, err := ReadSecretsValidators(dataDirs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(validators) != len(devnet.Validators) {
+		t.Fatalf("expected %d validators, got %d", len(devnet.Validators), len(validators))
+	}
+
+	byAddress := make(map[types.Address]*SecretsValidator, len(validators))
+	for _, validator := range validators {
+		byAddress[validator.Address] = validator
+	}
+
+	// The secrets are read back as the same validators, from the directory of their index
+	for i, expected := range devnet.Validators {
+		validator, ok := byAddress[expected.Address]
+		if !ok {
+			t.Fatalf("validator %d (%s) isn't read back", i, expected.Address)
+		}
+
+		if validator.DataDir != dataDirs[i] {
+			t.Fatalf("validator %d: expected the data directory %s, got %s", i, dataDirs[i], validator.DataDir)
+		}
+
+		if !bytes.Equal(validator.BLSPublicKey, expected.BLSPublicKey) {
+			t.Fatalf("validator %d: expected the BLS public key %x, got %x", i, expected.BLSPublicKey, validator.BLSPublicKey)
+		}
+	}
+}
@@ -0,0 +1,332 @@
+package staking
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/0xPolygon/polygon-edge/chain"
+	"github.com/0xPolygon/polygon-edge/helper/common"
+	"github.com/0xPolygon/polygon-edge/types"
+)
+
+// IBFTEngineName is the name of the IBFT consensus engine in the chain config
+const IBFTEngineName = "ibft"
+
+// Keys of the IBFT engine config
+const (
+	engineTypeKey              = "type"
+	engineTypesKey             = "types"
+	engineFromKey              = "from"
+	engineToKey                = "to"
+	engineDeploymentKey        = "deployment"
+	engineMinValidatorCountKey = "minValidatorCount"
+	engineMaxValidatorCountKey = "maxValidatorCount"
+	engineValidatorTypeKey     = "validator_type"
+	enginePoSType              = "PoS"
+)
+
+var (
+	ErrNotPoSEngine             = errors.New("consensus engine isn't configured for PoS")
+	ErrMissingEngineValue       = errors.New("missing value in the consensus engine config")
+	ErrContradictoryEngineValue = errors.New("contradictory values in the consensus engine config")
+)
+
+// StakingChainConfig is the staking setup of a PoS fork, read from the consensus engine config
+type StakingChainConfig struct {
+	// From and To are the first and last blocks of the fork.
+	// To is nil if the fork doesn't end
+	From uint64
+	To   *uint64
+
+	// Deployment is the block the staking SC is deployed at, if the fork switches to PoS
+	// after the genesis. The staking SC is predeployed at DefaultStakingSCAddress otherwise
+	Deployment *uint64
+
+	// Params holds the validated validator count bounds and the ordering policy
+	Params PredeployParams
+}
+
+// engineUint64 parses a number of the engine config. Numbers decoded from
+// the genesis file are float64, while hex strings are used for large values
+func engineUint64(key string, value interface{}) (uint64, error) {
+	switch v := value.(type) {
+	case float64:
+		if v < 0 || v != math.Trunc(v) || v > float64(common.MaxSafeJSInt) {
+			return 0, fmt.Errorf("invalid %s %v", key, v)
+		}
+
+		return uint64(v), nil
+	case json.Number:
+		return engineUint64(key, v.String())
+	case string:
+		parsed, err := types.ParseUint64orHex(&v)
+		if err != nil {
+			return 0, fmt.Errorf("invalid %s %q", key, v)
+		}
+
+		return parsed, nil
+	case uint64:
+		return v, nil
+	case int:
+		if v < 0 {
+			return 0, fmt.Errorf("invalid %s %d", key, v)
+		}
+
+		return uint64(v), nil
+	default:
+		return 0, fmt.Errorf("invalid %s of type %T", key, value)
+	}
+}
+
+// engineFork is one fork of the IBFT engine config. The values set next to the fork list
+// apply to every fork, unless the fork sets its own
+type engineFork struct {
+	name   string
+	from   uint64
+	to     *uint64
+	values map[string]interface{}
+}
+
+// isPoS checks if the fork uses the PoS mechanism
+func (f *engineFork) isPoS() bool {
+	return f.values[engineTypeKey] == enginePoSType
+}
+
+// active checks if the fork is active at the given block
+func (f *engineFork) active(block uint64) bool {
+	return f.from <= block && (f.to == nil || block <= *f.to)
+}
+
+// engineForks returns the forks of the IBFT engine config, ordered by their first block.
+// The flat layout ({"type": "PoS", "minValidatorCount": ...}) is a single fork active from the genesis,
+// while the fork layout ({"types": [{"type": "PoS", "from": "0x0", "minValidatorCount": ...}]})
+// lists every fork with its block range
+func engineForks(params *chain.Params) ([]*engineFork, error) {
+	config, err := engineConfig(params, IBFTEngineName)
+	if err != nil {
+		return nil, err
+	}
+
+	rawForks, ok := config[engineTypesKey]
+	if !ok {
+		return []*engineFork{
+			{
+				name:   IBFTEngineName,
+				values: config,
+			},
+		}, nil
+	}
+
+	list, ok := rawForks.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid %s in the %s engine config", engineTypesKey, IBFTEngineName)
+	}
+
+	forks := make([]*engineFork, len(list))
+
+	for i, rawFork := range list {
+		values, ok := rawFork.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("invalid %s[%d] in the %s engine config", engineTypesKey, i, IBFTEngineName)
+		}
+
+		fork := &engineFork{
+			name:   fmt.Sprintf("%s[%d]", engineTypesKey, i),
+			values: make(map[string]interface{}, len(config)+len(values)),
+		}
+
+		for key, value := range config {
+			if key != engineTypesKey {
+				fork.values[key] = value
+			}
+		}
+
+		for key, value := range values {
+			fork.values[key] = value
+		}
+
+		if from, ok := values[engineFromKey]; ok {
+			if fork.from, err = engineUint64(fork.name+"."+engineFromKey, from); err != nil {
+				return nil, err
+			}
+		}
+
+		if to, ok := values[engineToKey]; ok && to != nil {
+			number, err := engineUint64(fork.name+"."+engineToKey, to)
+			if err != nil {
+				return nil, err
+			}
+
+			fork.to = &number
+		}
+
+		forks[i] = fork
+	}
+
+	sort.SliceStable(forks, func(i, j int) bool {
+		return forks[i].from < forks[j].from
+	})
+
+	return forks, nil
+}
+
+// stakingConfig reads the staking setup of a PoS fork.
+// The validator count bounds are required, as the package level defaults are rarely the intended ones
+func (f *engineFork) stakingConfig() (*StakingChainConfig, error) {
+	stakingConfig := &StakingChainConfig{
+		From: f.from,
+		To:   f.to,
+		Params: PredeployParams{
+			MinValidatorCount: MinValidatorCount,
+			MaxValidatorCount: MaxValidatorCount,
+		},
+	}
+
+	bounds := []struct {
+		key    string
+		target *uint64
+	}{
+		{engineMinValidatorCountKey, &stakingConfig.Params.MinValidatorCount},
+		{engineMaxValidatorCountKey, &stakingConfig.Params.MaxValidatorCount},
+	}
+
+	for _, bound := range bounds {
+		value := f.values[bound.key]
+		if value == nil {
+			return nil, fmt.Errorf("%w: %s of %s", ErrMissingEngineValue, bound.key, f.name)
+		}
+
+		var err error
+		if *bound.target, err = engineUint64(bound.key, value); err != nil {
+			return nil, fmt.Errorf("%s, %w", f.name, err)
+		}
+	}
+
+	var err error
+	if stakingConfig.Params.Ordering, err = parseEngineOrdering(f.values[ValidatorOrderingKey]); err != nil {
+		return nil, fmt.Errorf("%s, %w", f.name, err)
+	}
+
+	if deployment := f.values[engineDeploymentKey]; deployment != nil {
+		number, err := engineUint64(engineDeploymentKey, deployment)
+		if err != nil {
+			return nil, fmt.Errorf("%s, %w", f.name, err)
+		}
+
+		stakingConfig.Deployment = &number
+	}
+
+	if err := validatePredeployBounds(stakingConfig.Params); err != nil {
+		return nil, fmt.Errorf("%s, %w", f.name, err)
+	}
+
+	return stakingConfig, nil
+}
+
+// StakingConfigsFromChain reads the staking setup of every PoS fork of the IBFT engine config,
+// ordered by their first block. Each fork can set its own validator count bounds
+func StakingConfigsFromChain(params *chain.Params) ([]*StakingChainConfig, error) {
+	forks, err := engineForks(params)
+	if err != nil {
+		return nil, err
+	}
+
+	configs := make([]*StakingChainConfig, 0, len(forks))
+
+	for _, fork := range forks {
+		if !fork.isPoS() {
+			continue
+		}
+
+		config, err := fork.stakingConfig()
+		if err != nil {
+			return nil, err
+		}
+
+		configs = append(configs, config)
+	}
+
+	if len(configs) == 0 {
+		return nil, ErrNotPoSEngine
+	}
+
+	return configs, nil
+}
+
+// StakingConfigFromChain reads the staking setup of the first PoS fork of the IBFT engine config,
+// the one the staking SC is predeployed or deployed for
+func StakingConfigFromChain(params *chain.Params) (*StakingChainConfig, error) {
+	configs, err := StakingConfigsFromChain(params)
+	if err != nil {
+		return nil, err
+	}
+
+	return configs[0], nil
+}
+
+// StakingConfigAt reads the staking setup of the fork active at the given block.
+// It returns ErrNotPoSEngine if that fork doesn't use PoS
+func StakingConfigAt(params *chain.Params, block uint64) (*StakingChainConfig, error) {
+	forks, err := engineForks(params)
+	if err != nil {
+		return nil, err
+	}
+
+	// The latest fork started at or before the block is the active one
+	for i := len(forks) - 1; i >= 0; i-- {
+		fork := forks[i]
+		if !fork.active(block) {
+			continue
+		}
+
+		if !fork.isPoS() {
+			return nil, fmt.Errorf("%w: type is %v at block %d", ErrNotPoSEngine, fork.values[engineTypeKey], block)
+		}
+
+		return fork.stakingConfig()
+	}
+
+	return nil, fmt.Errorf("%w: no fork is active at block %d", ErrNotPoSEngine, block)
+}
+
+// validatePredeployBounds checks the validator count bounds on their own
+func validatePredeployBounds(params PredeployParams) error {
+	if params.MinValidatorCount < 1 {
+		return fmt.Errorf("%w: %s must be at least 1", ErrContradictoryEngineValue, engineMinValidatorCountKey)
+	}
+
+	if params.MaxValidatorCount > common.MaxSafeJSInt {
+		return fmt.Errorf(
+			"%w: %s must be at most %d",
+			ErrContradictoryEngineValue,
+			engineMaxValidatorCountKey,
+			uint64(common.MaxSafeJSInt),
+		)
+	}
+
+	if params.MinValidatorCount > params.MaxValidatorCount {
+		return fmt.Errorf(
+			"%w: %s %d is greater than %s %d",
+			ErrContradictoryEngineValue,
+			engineMinValidatorCountKey,
+			params.MinValidatorCount,
+			engineMaxValidatorCountKey,
+			params.MaxValidatorCount,
+		)
+	}
+
+	return nil
+}
+
+// PredeployParamsFromChain returns the validated PredeployParams of the IBFT engine config
+func PredeployParamsFromChain(params *chain.Params) (PredeployParams, error) {
+	stakingConfig, err := StakingConfigFromChain(params)
+	if err != nil {
+		return PredeployParams{}, err
+	}
+
+	return stakingConfig.Params, nil
+}
@@ -0,0 +1,139 @@
+package staking
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/0xPolygon/polygon-edge/chain"
+)
+
+func TestStakingConfigPerFork(t *testing.T) {
+	params := &chain.Params{
+		Engine: map[string]interface{}{
+			IBFTEngineName: map[string]interface{}{
+				// Values next to the fork list are the defaults of every fork
+				engineMaxValidatorCountKey: float64(10),
+				ValidatorOrderingKey:       string(OrderByStake),
+				engineTypesKey: []interface{}{
+					map[string]interface{}{
+						engineTypeKey: "PoA",
+						engineFromKey: "0x0",
+						engineToKey:   "0x63",
+					},
+					map[string]interface{}{
+						engineTypeKey:              enginePoSType,
+						engineFromKey:              "0x64",
+						engineToKey:                "0xc7",
+						engineDeploymentKey:        "0x64",
+						engineMinValidatorCountKey: "0x1",
+					},
+					map[string]interface{}{
+						engineTypeKey:              enginePoSType,
+						engineFromKey:              "0xc8",
+						engineMinValidatorCountKey: float64(4),
+						engineMaxValidatorCountKey: float64(20),
+					},
+				},
+			},
+		},
+	}
+
+	cases := []struct {
+		block    uint64
+		from     uint64
+		min, max uint64
+	}{
+		{100, 100, 1, 10},
+		{199, 100, 1, 10},
+		{200, 200, 4, 20},
+		{1 << 40, 200, 4, 20},
+	}
+
+	for _, c := range cases {
+		config, err := StakingConfigAt(params, c.block)
+		if err != nil {
+			t.Fatalf("block %d, %v", c.block, err)
+		}
+
+		if config.From != c.from ||
+			config.Params.MinValidatorCount != c.min ||
+			config.Params.MaxValidatorCount != c.max ||
+			config.Params.Ordering != OrderByStake {
+			t.Errorf("block %d: expected the fork from %d with bounds [%d, %d], got %+v", c.block, c.from, c.min, c.max, config)
+		}
+	}
+
+	if _, err := StakingConfigAt(params, 99); !errors.Is(err, ErrNotPoSEngine) {
+		t.Fatalf("expected ErrNotPoSEngine in the PoA fork, got %v", err)
+	}
+
+	// The first PoS fork is the one the staking SC is deployed for
+	first, err := StakingConfigFromChain(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if first.From != 100 || first.Deployment == nil || *first.Deployment != 100 {
+		t.Fatalf("expected the fork deployed at block 100, got %+v", first)
+	}
+
+	configs, err := StakingConfigsFromChain(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(configs) != 2 {
+		t.Fatalf("expected 2 PoS forks, got %d", len(configs))
+	}
+}
+
+func TestStakingConfigFromChainErrors(t *testing.T) {
+	cases := []struct {
+		name   string
+		engine map[string]interface{}
+		err    error
+	}{
+		{
+			name: "missing maximum",
+			engine: map[string]interface{}{
+				engineTypeKey:              enginePoSType,
+				engineMinValidatorCountKey: float64(4),
+			},
+			err: ErrMissingEngineValue,
+		},
+		{
+			name: "inverted bounds",
+			engine: map[string]interface{}{
+				engineTypeKey:              enginePoSType,
+				engineMinValidatorCountKey: float64(4),
+				engineMaxValidatorCountKey: float64(3),
+			},
+			err: ErrContradictoryEngineValue,
+		},
+		{
+			name: "no PoS fork",
+			engine: map[string]interface{}{
+				engineTypesKey: []interface{}{
+					map[string]interface{}{engineTypeKey: "PoA", engineFromKey: "0x0"},
+				},
+			},
+			err: ErrNotPoSEngine,
+		},
+	}
+
+	for _, c := range cases {
+		c := c
+
+		t.Run(c.name, func(t *testing.T) {
+			params := &chain.Params{
+				Engine: map[string]interface{}{
+					IBFTEngineName: c.engine,
+				},
+			}
+
+			if _, err := StakingConfigFromChain(params); !errors.Is(err, c.err) {
+				t.Fatalf("expected %v, got %v", c.err, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,239 @@
+package staking
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+
+	"github.com/0xPolygon/polygon-edge/chain"
+	"github.com/0xPolygon/polygon-edge/helper/hex"
+	"github.com/0xPolygon/polygon-edge/types"
+)
+
+// Slot definitions for the mock ERC721 SC storage.
+// The layout follows OpenZeppelin's ERC721 (token approvals are in slot 4,
+// operator approvals in slot 5), so the predeployed state stays readable
+// if the mock is later replaced with a full implementation
+var (
+	erc721OwnersSlot   = int64(2) // Slot 2
+	erc721BalancesSlot = int64(3) // Slot 3
+)
+
+var (
+	errInvalidTokenID   = errors.New("token ID must be a non-negative integer")
+	errZeroAddressOwner = errors.New("token owner cannot be the zero address")
+)
+
+const (
+	// ERC721SCBytecode is the runtime code of a minimal, non-payable ERC721 contract.
+	// It implements balanceOf, ownerOf, approve, getApproved, setApprovalForAll,
+	// isApprovedForAll and transferFrom, which is everything the staking SC
+	// needs for stake / unstake. There is no minting, tokens only exist in genesis.
+	// It's hand assembled, the source listing is erc721Source in erc721_test.go,
+	// and TestERC721SCBytecodeSource rebuilds it from the listing
+	//nolint: lll
+	ERC721SCBytecode = "0x3461006057600436106100605760003560e01c806370a08231146100655780636352211e1461009b578063081812fc146100bb578063e985e9c5146100e9578063095ea7b31461013d578063a22cb465146101d257806323b872dd1461023b575b600080fd5b60043573ffffffffffffffffffffffffffffffffffffffff16801561006057600052600360205260406000205460005260206000f35b600435600052600260205260406000205480156100605760005260206000f35b6004358060005260026020526040600020541561006057600052600460205260406000205460005260206000f35b60243573ffffffffffffffffffffffffffffffffffffffff1660043573ffffffffffffffffffffffffffffffffffffffff166000526005602052604060002060205260005260406000205460005260206000f35b602435600052600260205260406000205480156100605780331461017c5733816000526005602052604060002060205260005260406000205415610060575b60043573ffffffffffffffffffffffffffffffffffffffff16806024356000526004602052604060002055602435917f8c5be1e5ebec7d5bd14f71427d1e84f3dd0314c0f7b2291e5b200ac8c7c3b925600080a4005b602435151560043573ffffffffffffffffffffffffffffffffffffffff1633600052600560205260406000208190602052600052604060002082905590600052337f17307eab39ab6107e8899845ad3d59bd9653f200f220920489ca2b5937696c3160206000a3005b60243573ffffffffffffffffffffffffffffffffffffffff1680156100605760043573ffffffffffffffffffffffffffffffffffffffff168015610060576044356000526002602052604060002054811415610060578033146102d057604435600052600460205260406000205433146102d05733816000526005602052604060002060205260005260406000205415610060575b6000604435600052600460205260406000205580600052600360205260406000206001815403905581600052600360205260406000206001815401905581604435600052600260205260406000205560443591907fddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef600080a400"
+)
+
+// ERC721PredeployParams contains the values used to predeploy the mock ERC721 contract
+type ERC721PredeployParams struct {
+	// StakingAddress is the address of the staking SC.
+	// Tokens in StakedTokens are owned by it
+	StakingAddress types.Address
+
+	// Holders maps an account to the token IDs it holds in its own wallet
+	Holders map[types.Address][]*big.Int
+
+	// StakedTokens maps a validator to the token IDs it has staked
+	StakedTokens map[types.Address][]*big.Int
+}
+
+// PredeployERC721 is a helper method for setting up the mock ERC721 contract account,
+// with token owners and balances pre-populated.
+// Staked tokens are assigned to the staking SC, as they would be after a stake call
+func PredeployERC721(params ERC721PredeployParams) (*chain.GenesisAccount, error) {
+	scHex, _ := hex.DecodeHex(ERC721SCBytecode)
+	nftAccount := &chain.GenesisAccount{
+		Code:    scHex,
+		Balance: big.NewInt(0),
+	}
+
+	owners := make(map[string]types.Address)
+	balances := make(map[types.Address]*big.Int)
+
+	assignTokens := func(owner types.Address, tokenIDs []*big.Int) error {
+		if owner == types.ZeroAddress {
+			return errZeroAddressOwner
+		}
+
+		for _, tokenID := range tokenIDs {
+			if tokenID == nil || tokenID.Sign() < 0 {
+				return errInvalidTokenID
+			}
+
+			if prevOwner, ok := owners[tokenID.String()]; ok {
+				return fmt.Errorf(
+					"token %s is assigned to both %s and %s",
+					tokenID,
+					prevOwner,
+					owner,
+				)
+			}
+
+			owners[tokenID.String()] = owner
+
+			if _, ok := balances[owner]; !ok {
+				balances[owner] = big.NewInt(0)
+			}
+
+			balances[owner].Add(balances[owner], big.NewInt(1))
+		}
+
+		return nil
+	}
+
+	// The maps are walked in address order, so a token assigned twice
+	// is always reported with the same pair of owners
+	for _, holder := range sortedTokenOwners(params.Holders) {
+		if err := assignTokens(holder, params.Holders[holder]); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, staker := range sortedTokenOwners(params.StakedTokens) {
+		if err := assignTokens(params.StakingAddress, params.StakedTokens[staker]); err != nil {
+			return nil, err
+		}
+	}
+
+	// Generate the account storage map
+	storageMap := make(map[types.Hash]types.Hash)
+
+	for tokenIDStr, owner := range owners {
+		tokenID, _ := new(big.Int).SetString(tokenIDStr, 10)
+
+		// Set the value for the token ID -> owner mapping
+		storageMap[types.BytesToHash(getUint256Mapping(tokenID, erc721OwnersSlot))] =
+			types.BytesToHash(owner.Bytes())
+	}
+
+	for owner, balance := range balances {
+		// Set the value for the owner -> balance mapping
+		storageMap[types.BytesToHash(getAddressMapping(owner, erc721BalancesSlot))] =
+			types.BytesToHash(balance.Bytes())
+	}
+
+	nftAccount.Storage = storageMap
+
+	return nftAccount, nil
+}
+
+// sortedTokenOwners returns the owners of the token map in ascending address order
+func sortedTokenOwners(tokens map[types.Address][]*big.Int) []types.Address {
+	owners := make([]types.Address, 0, len(tokens))
+	for owner := range tokens {
+		owners = append(owners, owner)
+	}
+
+	sort.Slice(owners, func(i, j int) bool {
+		return bytes.Compare(owners[i].Bytes(), owners[j].Bytes()) < 0
+	})
+
+	return owners
+}
+
+// getNFTWeight mirrors the staking SC's weight calculation for a single token
+func getNFTWeight(tokenID *big.Int) *big.Int {
+	weight := new(big.Int).Mod(tokenID, big.NewInt(3))
+	if weight.Sign() == 0 {
+		weight.SetInt64(3)
+	}
+
+	return weight
+}
+
+// SetStakedNFTs records the staked tokens in the storage of an already
+// generated staking SC account, so that validators can unstake them later.
+// It sets the NFT contract address, the token ID -> staker mapping and
+// the staker weights, and increases the staked amounts by one per token,
+// the same way the stake method does.
+// A token can only be staked once, either across the stakers or on top of
+// a token already recorded in the account
+func SetStakedNFTs(
+	stakingAccount *chain.GenesisAccount,
+	nftAddress types.Address,
+	stakedTokens map[types.Address][]*big.Int,
+) error {
+	if stakingAccount.Storage == nil {
+		stakingAccount.Storage = make(map[types.Hash]types.Hash)
+	}
+
+	storageMap := stakingAccount.Storage
+	readWord := func(index []byte) *big.Int {
+		value := storageMap[types.BytesToHash(index)]
+
+		return new(big.Int).SetBytes(value.Bytes())
+	}
+
+	stakedAmountIndex := big.NewInt(stakedAmountSlot).Bytes()
+	stakedAmount := readWord(stakedAmountIndex)
+
+	for _, staker := range sortedTokenOwners(stakedTokens) {
+		tokenIDs := stakedTokens[staker]
+
+		if staker == types.ZeroAddress {
+			return errZeroAddressOwner
+		}
+
+		stakedAmountMappingIndex := getAddressMapping(staker, addressToStakedAmountSlot)
+		weightIndex := getAddressMapping(staker, addressToWeightSlot)
+
+		stakerAmount := readWord(stakedAmountMappingIndex)
+		weight := readWord(weightIndex)
+
+		for _, tokenID := range tokenIDs {
+			if tokenID == nil || tokenID.Sign() < 0 {
+				return errInvalidTokenID
+			}
+
+			tokenIndex := getUint256Mapping(tokenID, tokenIDToOwnerSlot)
+
+			if prevStaker := readWord(tokenIndex); prevStaker.Sign() != 0 {
+				return fmt.Errorf(
+					"token %s is staked by both %s and %s",
+					tokenID,
+					types.BytesToAddress(prevStaker.Bytes()),
+					staker,
+				)
+			}
+
+			// Set the value for the token ID -> staker mapping
+			storageMap[types.BytesToHash(tokenIndex)] =
+				types.BytesToHash(staker.Bytes())
+
+			stakerAmount.Add(stakerAmount, big.NewInt(1))
+			stakedAmount.Add(stakedAmount, big.NewInt(1))
+			weight.Add(weight, getNFTWeight(tokenID))
+		}
+
+		// Set the value for the address -> staked amount mapping
+		storageMap[types.BytesToHash(stakedAmountMappingIndex)] =
+			types.BytesToHash(stakerAmount.Bytes())
+
+		// Set the value for the address -> weight mapping
+		storageMap[types.BytesToHash(weightIndex)] =
+			types.BytesToHash(weight.Bytes())
+	}
+
+	// Set the value for the total staked amount
+	storageMap[types.BytesToHash(stakedAmountIndex)] =
+		types.BytesToHash(stakedAmount.Bytes())
+
+	// Set the value for the NFT contract address
+	storageMap[types.BytesToHash(big.NewInt(nftAddressSlot).Bytes())] =
+		types.BytesToHash(nftAddress.Bytes())
+
+	return nil
+}
@@ -0,0 +1,436 @@
+package staking
+
+import (
+	"encoding/hex"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/0xPolygon/polygon-edge/helper/keccak"
+	"github.com/0xPolygon/polygon-edge/types"
+)
+
+// The mock ERC721 SC is hand assembled, there is no compiler and no optimizer involved.
+// erc721Source is its source: a listing of opcodes, pushes and jump labels.
+// Pushes take the fewest bytes fitting the value (PUSH1 for zero, no PUSH0),
+// except the selectors, the event topics and the address mask, which take their full size.
+// Jump targets are always PUSH2. TestERC721SCBytecodeSource assembles the listing
+// and checks it against ERC721SCBytecode, so a change to either has to change both
+
+// asmItem is a single item of an assembly listing
+type asmItem struct {
+	op    byte
+	push  []byte
+	label string
+	ref   string
+}
+
+var asmOpcodes = map[string]byte{
+	"STOP": 0x00, "ADD": 0x01, "SUB": 0x03, "LT": 0x10, "EQ": 0x14, "ISZERO": 0x15, "AND": 0x16,
+	"SHR": 0x1c, "SHA3": 0x20, "CALLER": 0x33, "CALLVALUE": 0x34, "CALLDATALOAD": 0x35,
+	"CALLDATASIZE": 0x36, "CALLDATACOPY": 0x37, "RETURNDATASIZE": 0x3d, "RETURNDATACOPY": 0x3e,
+	"MSTORE": 0x52, "SLOAD": 0x54, "SSTORE": 0x55, "JUMPI": 0x57, "GAS": 0x5a,
+	"LOG1": 0xa1, "LOG2": 0xa2, "LOG3": 0xa3, "LOG4": 0xa4, "RETURN": 0xf3, "DELEGATECALL": 0xf4, "REVERT": 0xfd,
+	"DUP1": 0x80, "DUP2": 0x81, "DUP3": 0x82, "SWAP1": 0x90, "SWAP2": 0x91,
+}
+
+// asmProgram builds an assembly listing
+type asmProgram []asmItem
+
+func (p *asmProgram) ops(t *testing.T, names ...string) {
+	t.Helper()
+
+	for _, name := range names {
+		op, ok := asmOpcodes[name]
+		if !ok {
+			t.Fatalf("unknown opcode %s", name)
+		}
+
+		*p = append(*p, asmItem{op: op})
+	}
+}
+
+func (p *asmProgram) push(value *big.Int) {
+	encoded := value.Bytes()
+	if len(encoded) == 0 {
+		encoded = []byte{0}
+	}
+
+	*p = append(*p, asmItem{push: encoded})
+}
+
+// pushFixed pushes the value padded to the given byte size
+func (p *asmProgram) pushFixed(value *big.Int, size int) {
+	encoded := make([]byte, size)
+	value.FillBytes(encoded)
+
+	*p = append(*p, asmItem{push: encoded})
+}
+
+func (p *asmProgram) pushUint(value uint64) {
+	p.push(new(big.Int).SetUint64(value))
+}
+
+func (p *asmProgram) label(name string) {
+	*p = append(*p, asmItem{label: name})
+}
+
+func (p *asmProgram) ref(name string) {
+	*p = append(*p, asmItem{ref: name})
+}
+
+// assemble resolves the labels and returns the bytecode
+func (p asmProgram) assemble(t *testing.T) []byte {
+	t.Helper()
+
+	// Every item has a fixed size, so the labels are known after a single pass
+	labels := make(map[string]int)
+	offset := 0
+
+	for _, item := range p {
+		switch {
+		case item.label != "":
+			labels[item.label] = offset
+			offset++
+		case item.ref != "":
+			offset += 3
+		case item.push != nil:
+			offset += 1 + len(item.push)
+		default:
+			offset++
+		}
+	}
+
+	code := make([]byte, 0, offset)
+
+	for _, item := range p {
+		switch {
+		case item.label != "":
+			// JUMPDEST
+			code = append(code, 0x5b)
+		case item.ref != "":
+			target, ok := labels[item.ref]
+			if !ok {
+				t.Fatalf("unknown label %s", item.ref)
+			}
+
+			code = append(code, 0x61, byte(target>>8), byte(target))
+		case item.push != nil:
+			code = append(code, 0x5f+byte(len(item.push)))
+			code = append(code, item.push...)
+		default:
+			code = append(code, item.op)
+		}
+	}
+
+	return code
+}
+
+// erc721Source returns the listing of the mock ERC721 SC
+func erc721Source(t *testing.T) asmProgram {
+	t.Helper()
+
+	const (
+		owners    = 2
+		balances  = 3
+		approvals = 4
+		operators = 5
+	)
+
+	addressMask := new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 160), big.NewInt(1))
+	hashOf := func(text string) *big.Int {
+		return new(big.Int).SetBytes(keccak.Keccak256(nil, []byte(text)))
+	}
+
+	p := asmProgram{}
+
+	// arg loads the i-th word argument
+	arg := func(i uint64) {
+		p.pushUint(4 + 32*i)
+		p.ops(t, "CALLDATALOAD")
+	}
+	// address loads the i-th argument as an address
+	address := func(i uint64) {
+		arg(i)
+		p.pushFixed(addressMask, 20)
+		p.ops(t, "AND")
+	}
+	// mapping replaces the key on the stack with its mapping index in the slot
+	mapping := func(slot uint64) {
+		p.pushUint(0)
+		p.ops(t, "MSTORE")
+		p.pushUint(slot)
+		p.pushUint(0x20)
+		p.ops(t, "MSTORE")
+		p.pushUint(0x40)
+		p.pushUint(0)
+		p.ops(t, "SHA3")
+	}
+	// nestedMapping replaces the key and the outer index on the stack with the nested mapping index
+	nestedMapping := func() {
+		p.pushUint(0x20)
+		p.ops(t, "MSTORE")
+		p.pushUint(0)
+		p.ops(t, "MSTORE")
+		p.pushUint(0x40)
+		p.pushUint(0)
+		p.ops(t, "SHA3")
+	}
+	revertIf := func() {
+		p.ref("revert")
+		p.ops(t, "JUMPI")
+	}
+	returnWord := func() {
+		p.pushUint(0)
+		p.ops(t, "MSTORE")
+		p.pushUint(0x20)
+		p.pushUint(0)
+		p.ops(t, "RETURN")
+	}
+	emit := func(event string) {
+		p.pushFixed(hashOf(event), 32)
+	}
+
+	functions := []struct {
+		signature string
+		label     string
+	}{
+		{"balanceOf(address)", "balanceOf"},
+		{"ownerOf(uint256)", "ownerOf"},
+		{"getApproved(uint256)", "getApproved"},
+		{"isApprovedForAll(address,address)", "isApprovedForAll"},
+		{"approve(address,uint256)", "approve"},
+		{"setApprovalForAll(address,bool)", "setApprovalForAll"},
+		{"transferFrom(address,address,uint256)", "transferFrom"},
+	}
+
+	// Dispatcher, non-payable
+	p.ops(t, "CALLVALUE")
+	revertIf()
+	p.pushUint(4)
+	p.ops(t, "CALLDATASIZE", "LT")
+	revertIf()
+	p.pushUint(0)
+	p.ops(t, "CALLDATALOAD")
+	p.pushUint(0xe0)
+	p.ops(t, "SHR")
+
+	for _, function := range functions {
+		p.ops(t, "DUP1")
+		p.pushFixed(new(big.Int).Rsh(hashOf(function.signature), 224), 4)
+		p.ops(t, "EQ")
+		p.ref(function.label)
+		p.ops(t, "JUMPI")
+	}
+
+	p.label("revert")
+	p.pushUint(0)
+	p.ops(t, "DUP1", "REVERT")
+
+	// balanceOf(owner)
+	p.label("balanceOf")
+	address(0)
+	p.ops(t, "DUP1", "ISZERO")
+	revertIf()
+	mapping(balances)
+	p.ops(t, "SLOAD")
+	returnWord()
+
+	// ownerOf(tokenId)
+	p.label("ownerOf")
+	arg(0)
+	mapping(owners)
+	p.ops(t, "SLOAD", "DUP1", "ISZERO")
+	revertIf()
+	returnWord()
+
+	// getApproved(tokenId)
+	p.label("getApproved")
+	arg(0)
+	p.ops(t, "DUP1")
+	mapping(owners)
+	p.ops(t, "SLOAD", "ISZERO")
+	revertIf()
+	mapping(approvals)
+	p.ops(t, "SLOAD")
+	returnWord()
+
+	// isApprovedForAll(owner, operator)
+	p.label("isApprovedForAll")
+	address(1)
+	address(0)
+	mapping(operators)
+	nestedMapping()
+	p.ops(t, "SLOAD")
+	returnWord()
+
+	// approve(to, tokenId), by the owner or one of its operators
+	p.label("approve")
+	arg(1)
+	mapping(owners)
+	p.ops(t, "SLOAD", "DUP1", "ISZERO")
+	revertIf()
+	p.ops(t, "DUP1", "CALLER", "EQ")
+	p.ref("approveAuthorized")
+	p.ops(t, "JUMPI", "CALLER", "DUP2")
+	mapping(operators)
+	nestedMapping()
+	p.ops(t, "SLOAD", "ISZERO")
+	revertIf()
+	p.label("approveAuthorized")
+	address(0)
+	p.ops(t, "DUP1")
+	arg(1)
+	mapping(approvals)
+	p.ops(t, "SSTORE")
+	arg(1)
+	p.ops(t, "SWAP2")
+	emit("Approval(address,address,uint256)")
+	p.pushUint(0)
+	p.ops(t, "DUP1", "LOG4", "STOP")
+
+	// setApprovalForAll(operator, approved)
+	p.label("setApprovalForAll")
+	arg(1)
+	p.ops(t, "ISZERO", "ISZERO")
+	address(0)
+	p.ops(t, "CALLER")
+	mapping(operators)
+	p.ops(t, "DUP2", "SWAP1")
+	nestedMapping()
+	p.ops(t, "DUP3", "SWAP1", "SSTORE", "SWAP1")
+	p.pushUint(0)
+	p.ops(t, "MSTORE", "CALLER")
+	emit("ApprovalForAll(address,address,bool)")
+	p.pushUint(0x20)
+	p.pushUint(0)
+	p.ops(t, "LOG3", "STOP")
+
+	// transferFrom(from, to, tokenId), by the owner, the approved account or an operator
+	p.label("transferFrom")
+	address(1)
+	p.ops(t, "DUP1", "ISZERO")
+	revertIf()
+	address(0)
+	p.ops(t, "DUP1", "ISZERO")
+	revertIf()
+	arg(2)
+	mapping(owners)
+	p.ops(t, "SLOAD", "DUP2", "EQ", "ISZERO")
+	revertIf()
+	p.ops(t, "DUP1", "CALLER", "EQ")
+	p.ref("transferAuthorized")
+	p.ops(t, "JUMPI")
+	arg(2)
+	mapping(approvals)
+	p.ops(t, "SLOAD", "CALLER", "EQ")
+	p.ref("transferAuthorized")
+	p.ops(t, "JUMPI", "CALLER", "DUP2")
+	mapping(operators)
+	nestedMapping()
+	p.ops(t, "SLOAD", "ISZERO")
+	revertIf()
+	p.label("transferAuthorized")
+	p.pushUint(0)
+	arg(2)
+	mapping(approvals)
+	p.ops(t, "SSTORE", "DUP1")
+	mapping(balances)
+	p.pushUint(1)
+	p.ops(t, "DUP2", "SLOAD", "SUB", "SWAP1", "SSTORE", "DUP2")
+	mapping(balances)
+	p.pushUint(1)
+	p.ops(t, "DUP2", "SLOAD", "ADD", "SWAP1", "SSTORE", "DUP2")
+	arg(2)
+	mapping(owners)
+	p.ops(t, "SSTORE")
+	arg(2)
+	p.ops(t, "SWAP2", "SWAP1")
+	emit("Transfer(address,address,uint256)")
+	p.pushUint(0)
+	p.ops(t, "DUP1", "LOG4", "STOP")
+
+	return p
+}
+
+func TestERC721SCBytecodeSource(t *testing.T) {
+	code := erc721Source(t).assemble(t)
+
+	if got := "0x" + hex.EncodeToString(code); got != ERC721SCBytecode {
+		t.Fatalf("assembled mock ERC721 SC doesn't match ERC721SCBytecode:\n%s", got)
+	}
+}
+
+func TestPredeployERC721DuplicateToken(t *testing.T) {
+	var (
+		holderA = types.StringToAddress("0x1")
+		holderB = types.StringToAddress("0x2")
+		holderC = types.StringToAddress("0x3")
+	)
+
+	params := ERC721PredeployParams{
+		StakingAddress: types.StringToAddress("0x1001"),
+		Holders: map[types.Address][]*big.Int{
+			holderC: {big.NewInt(7)},
+			holderB: {big.NewInt(7)},
+			holderA: {big.NewInt(7)},
+		},
+	}
+
+	want := "token 7 is assigned to both " + holderA.String() + " and " + holderB.String()
+
+	// The map order changes between runs, the error must not
+	for i := 0; i < 20; i++ {
+		_, err := PredeployERC721(params)
+		if err == nil || err.Error() != want {
+			t.Fatalf("expected %q, got %v", want, err)
+		}
+	}
+}
+
+func TestSetStakedNFTsDuplicateToken(t *testing.T) {
+	var (
+		stakerA = types.StringToAddress("0x1")
+		stakerB = types.StringToAddress("0x2")
+	)
+
+	t.Run("across stakers", func(t *testing.T) {
+		account, err := PredeployStakingSC([]types.Address{stakerA, stakerB}, PredeployParams{
+			MinValidatorCount: 1,
+			MaxValidatorCount: 2,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = SetStakedNFTs(account, DefaultNFTSCAddress, map[types.Address][]*big.Int{
+			stakerB: {big.NewInt(4)},
+			stakerA: {big.NewInt(3), big.NewInt(4)},
+		})
+
+		want := "token 4 is staked by both " + stakerA.String() + " and " + stakerB.String()
+		if err == nil || err.Error() != want {
+			t.Fatalf("expected %q, got %v", want, err)
+		}
+	})
+
+	t.Run("already staked", func(t *testing.T) {
+		account, err := PredeployStakingSC([]types.Address{stakerA, stakerB}, PredeployParams{
+			MinValidatorCount: 1,
+			MaxValidatorCount: 2,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		staked := map[types.Address][]*big.Int{stakerA: {big.NewInt(5)}}
+		if err := SetStakedNFTs(account, DefaultNFTSCAddress, staked); err != nil {
+			t.Fatal(err)
+		}
+
+		err = SetStakedNFTs(account, DefaultNFTSCAddress, map[types.Address][]*big.Int{stakerB: {big.NewInt(5)}})
+		if err == nil || !strings.Contains(err.Error(), "token 5 is staked by both") {
+			t.Fatalf("expected a duplicate token error, got %v", err)
+		}
+	})
+}
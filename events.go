@@ -0,0 +1,234 @@
+package staking
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/0xPolygon/polygon-edge/helper/hex"
+	"github.com/0xPolygon/polygon-edge/helper/keccak"
+	"github.com/0xPolygon/polygon-edge/types"
+)
+
+var (
+	// StakedEventTopic is the topic of the Staked(address,uint256[]) event
+	StakedEventTopic = types.BytesToHash(keccak.Keccak256(nil, []byte("Staked(address,uint256[])")))
+
+	// UnstakedEventTopic is the topic of the Unstaked(address,uint256[]) event
+	UnstakedEventTopic = types.BytesToHash(keccak.Keccak256(nil, []byte("Unstaked(address,uint256[])")))
+)
+
+var errInvalidStakingLog = errors.New("invalid staking log")
+
+// StakingEventType is the type of a staking SC event
+type StakingEventType string
+
+const (
+	// EventStaked is the type of the Staked event, emitted when tokens are staked
+	EventStaked StakingEventType = "staked"
+
+	// EventUnstaked is the type of the Unstaked event, emitted when tokens are unstaked
+	EventUnstaked StakingEventType = "unstaked"
+)
+
+// StakingEvent is a decoded Staked or Unstaked event of the staking SC
+type StakingEvent struct {
+	Type        StakingEventType `json:"type"`
+	Account     types.Address    `json:"account"`
+	TokenIDs    []*big.Int       `json:"tokenIds"`
+	BlockNumber uint64           `json:"blockNumber"`
+	BlockHash   types.Hash       `json:"blockHash"`
+	TxHash      types.Hash       `json:"transactionHash"`
+	LogIndex    uint64           `json:"logIndex"`
+}
+
+// rpcLog is a log entry, as returned by eth_getLogs
+type rpcLog struct {
+	Address     types.Address `json:"address"`
+	Topics      []types.Hash  `json:"topics"`
+	Data        string        `json:"data"`
+	BlockNumber string        `json:"blockNumber"`
+	BlockHash   types.Hash    `json:"blockHash"`
+	TxHash      types.Hash    `json:"transactionHash"`
+	LogIndex    string        `json:"logIndex"`
+	Removed     bool          `json:"removed"`
+}
+
+// blockNumber returns the block number of the log
+func (l *rpcLog) blockNumber() (uint64, error) {
+	return types.ParseUint64orHex(&l.BlockNumber)
+}
+
+// rpcBlockHeader is the part of a block returned by eth_getBlockByNumber used here
+type rpcBlockHeader struct {
+	Number string     `json:"number"`
+	Hash   types.Hash `json:"hash"`
+}
+
+// getBlockNumber returns the number of the latest block
+func (t *jsonRPCTransport) getBlockNumber(ctx context.Context) (uint64, error) {
+	var number string
+	if err := t.call(ctx, "eth_blockNumber", []interface{}{}, &number); err != nil {
+		return 0, err
+	}
+
+	return types.ParseUint64orHex(&number)
+}
+
+// getBlockHeader returns the header of the block with the given number,
+// or nil if the block doesn't exist
+func (t *jsonRPCTransport) getBlockHeader(ctx context.Context, number uint64) (*rpcBlockHeader, error) {
+	var header *rpcBlockHeader
+	if err := t.call(
+		ctx,
+		"eth_getBlockByNumber",
+		[]interface{}{hex.EncodeUint64(number), false},
+		&header,
+	); err != nil {
+		return nil, err
+	}
+
+	return header, nil
+}
+
+// requireBlockHeader returns the header of the block with the given number, which has to exist
+func (t *jsonRPCTransport) requireBlockHeader(ctx context.Context, number uint64) (*rpcBlockHeader, error) {
+	header, err := t.getBlockHeader(ctx, number)
+	if err != nil {
+		return nil, err
+	}
+
+	if header == nil {
+		return nil, fmt.Errorf("block %d doesn't exist", number)
+	}
+
+	return header, nil
+}
+
+// getBlockHeaders returns the headers of the blocks with the given numbers, in a single batch request.
+// The header of a block that doesn't exist is nil
+func (t *jsonRPCTransport) getBlockHeaders(ctx context.Context, numbers []uint64) ([]*rpcBlockHeader, error) {
+	headers := make([]*rpcBlockHeader, len(numbers))
+	if len(numbers) == 0 {
+		return headers, nil
+	}
+
+	elems := make([]*jsonRPCBatchElem, len(numbers))
+
+	for n, number := range numbers {
+		elems[n] = &jsonRPCBatchElem{
+			method: "eth_getBlockByNumber",
+			params: []interface{}{hex.EncodeUint64(number), false},
+			result: &headers[n],
+		}
+	}
+
+	if err := t.batchCall(ctx, elems); err != nil {
+		return nil, err
+	}
+
+	for n, elem := range elems {
+		if elem.err != nil {
+			return nil, fmt.Errorf("unable to get the header of block %d, %w", numbers[n], elem.err)
+		}
+	}
+
+	return headers, nil
+}
+
+// getStakingLogs returns the Staked and Unstaked logs of the staking SC
+// in the given block range, both ends included
+func (t *jsonRPCTransport) getStakingLogs(
+	ctx context.Context,
+	address types.Address,
+	from, to uint64,
+) ([]*rpcLog, error) {
+	filter := map[string]interface{}{
+		"address":   address,
+		"fromBlock": hex.EncodeUint64(from),
+		"toBlock":   hex.EncodeUint64(to),
+		"topics":    [][]types.Hash{{StakedEventTopic, UnstakedEventTopic}},
+	}
+
+	var logs []*rpcLog
+	if err := t.call(ctx, "eth_getLogs", []interface{}{filter}, &logs); err != nil {
+		return nil, fmt.Errorf("unable to get the staking logs of blocks %d-%d, %w", from, to, err)
+	}
+
+	return logs, nil
+}
+
+// decodeStakingLog decodes a Staked or Unstaked log.
+// The staker is the indexed topic, and the data holds the ABI encoded token IDs
+func decodeStakingLog(log *rpcLog) (*StakingEvent, error) {
+	if len(log.Topics) != 2 {
+		return nil, fmt.Errorf("%w: expected 2 topics, got %d", errInvalidStakingLog, len(log.Topics))
+	}
+
+	event := &StakingEvent{
+		Account:   types.BytesToAddress(log.Topics[1].Bytes()),
+		BlockHash: log.BlockHash,
+		TxHash:    log.TxHash,
+	}
+
+	switch log.Topics[0] {
+	case StakedEventTopic:
+		event.Type = EventStaked
+	case UnstakedEventTopic:
+		event.Type = EventUnstaked
+	default:
+		return nil, fmt.Errorf("%w: unknown topic %s", errInvalidStakingLog, log.Topics[0])
+	}
+
+	var err error
+
+	if event.BlockNumber, err = log.blockNumber(); err != nil {
+		return nil, fmt.Errorf("%w: invalid block number, %v", errInvalidStakingLog, err)
+	}
+
+	if event.LogIndex, err = types.ParseUint64orHex(&log.LogIndex); err != nil {
+		return nil, fmt.Errorf("%w: invalid log index, %v", errInvalidStakingLog, err)
+	}
+
+	data, err := hex.DecodeHex(log.Data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid data, %v", errInvalidStakingLog, err)
+	}
+
+	words, err := decodeWordArray(data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid token IDs, %v", errInvalidStakingLog, err)
+	}
+
+	event.TokenIDs = make([]*big.Int, len(words))
+	for i, word := range words {
+		event.TokenIDs[i] = new(big.Int).SetBytes(word)
+	}
+
+	return event, nil
+}
+
+// StakedTokenIDs returns the distinct token IDs of the Staked events, in order of appearance.
+// They are the candidate staked tokens of ExportRegenesis
+func StakedTokenIDs(events []*StakingEvent) []*big.Int {
+	seen := make(map[string]bool)
+	tokenIDs := make([]*big.Int, 0)
+
+	for _, event := range events {
+		if event.Type != EventStaked {
+			continue
+		}
+
+		for _, tokenID := range event.TokenIDs {
+			if seen[tokenID.String()] {
+				continue
+			}
+
+			seen[tokenID.String()] = true
+			tokenIDs = append(tokenIDs, tokenID)
+		}
+	}
+
+	return tokenIDs
+}
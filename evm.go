@@ -0,0 +1,116 @@
+package staking
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/0xPolygon/polygon-edge/chain"
+	"github.com/0xPolygon/polygon-edge/state"
+	itrie "github.com/0xPolygon/polygon-edge/state/immutable-trie"
+	"github.com/0xPolygon/polygon-edge/types"
+	"github.com/hashicorp/go-hclog"
+)
+
+// genesisEVMGasLimit is the gas limit of the block the GenesisEVM calls are executed in
+const genesisEVMGasLimit = uint64(100_000_000)
+
+var errEVMCallFailed = errors.New("evm call failed")
+
+// GenesisEVM executes calls against the genesis state in an in-memory EVM,
+// without a running node. State changes of a call are visible to the later ones
+type GenesisEVM struct {
+	transition *state.Transition
+}
+
+// NewGenesisEVM writes the genesis alloc to an in-memory state,
+// and opens a transition on top of it at block 1
+func NewGenesisEVM(genesis *chain.Genesis) (*GenesisEVM, error) {
+	config := genesis.Config
+	if config == nil {
+		config = &chain.Params{
+			Forks: chain.AllForksEnabled,
+		}
+	}
+
+	executor := state.NewExecutor(config, itrie.NewState(itrie.NewMemoryStorage()), hclog.NewNullLogger())
+	executor.GetHash = func(*types.Header) state.GetHashByNumber {
+		return func(uint64) types.Hash {
+			return types.ZeroHash
+		}
+	}
+
+	stateRoot := executor.WriteGenesis(genesis.Alloc)
+
+	transition, err := executor.BeginTxn(stateRoot, &types.Header{
+		Number:    1,
+		GasLimit:  genesisEVMGasLimit,
+		Timestamp: genesis.Timestamp,
+	}, types.ZeroAddress)
+	if err != nil {
+		return nil, fmt.Errorf("unable to begin the genesis transition, %w", err)
+	}
+
+	return &GenesisEVM{
+		transition: transition,
+	}, nil
+}
+
+// Call executes a zero-value, zero-gas-price transaction and returns its return data.
+// A reverted or failed call returns an error
+func (e *GenesisEVM) Call(from, to types.Address, input []byte) ([]byte, error) {
+	result, err := e.transition.Apply(&types.Transaction{
+		Nonce:    e.transition.GetNonce(from),
+		From:     from,
+		To:       &to,
+		Input:    input,
+		Gas:      genesisEVMGasLimit,
+		GasPrice: big.NewInt(0),
+		Value:    big.NewInt(0),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errEVMCallFailed, err)
+	}
+
+	if result.Failed() {
+		return result.ReturnValue, fmt.Errorf("%w: %v", errEVMCallFailed, result.Err)
+	}
+
+	return result.ReturnValue, nil
+}
+
+// View executes the view calls of the staking SC at the given address, from the given caller
+func (e *GenesisEVM) View(from, stakingAddress types.Address, calls ...*ViewCall) error {
+	for _, call := range calls {
+		output, err := e.Call(from, stakingAddress, call.input)
+		if err != nil {
+			return fmt.Errorf("%s: %w", call.name, err)
+		}
+
+		if err := call.decode(output); err != nil {
+			return fmt.Errorf("%s: %w", call.name, err)
+		}
+	}
+
+	return nil
+}
+
+// evmStorageReader is a StateReader over the account storage of the GenesisEVM
+type evmStorageReader struct {
+	transition *state.Transition
+	address    types.Address
+}
+
+// GetStorage implements the StateReader interface
+func (r *evmStorageReader) GetStorage(slot types.Hash) (types.Hash, error) {
+	return r.transition.GetStorage(r.address, slot), nil
+}
+
+// Storage returns a StateReader over the account storage,
+// which includes the changes of the calls executed so far
+func (e *GenesisEVM) Storage(address types.Address) StateReader {
+	return &evmStorageReader{
+		transition: e.transition,
+		address:    address,
+	}
+}
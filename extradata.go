@@ -0,0 +1,331 @@
+package staking
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+
+	"github.com/0xPolygon/polygon-edge/chain"
+	"github.com/0xPolygon/polygon-edge/types"
+	"github.com/umbracle/fastrlp"
+)
+
+// IBFTExtraVanity is the size of the vanity prefix of the IBFT extra data
+const IBFTExtraVanity = 32
+
+// IBFTMixDigest is the mix hash of IBFT blocks
+var IBFTMixDigest = types.StringToHash("0x63746963616c2062797a616e74696e65206661756c7420746f6c6572616e6365")
+
+var errInvalidIBFTExtra = errors.New("invalid IBFT extra data")
+
+// DecodeIBFTExtraValidators decodes the validator list of the IBFT extra data,
+// 32 bytes of vanity followed by the RLP encoded IstanbulExtra.
+// Both ECDSA validators (an address) and BLS validators (an [address, public key] list) are supported
+func DecodeIBFTExtraValidators(extraData []byte) ([]types.Address, error) {
+	if len(extraData) < IBFTExtraVanity {
+		return nil, fmt.Errorf("%w: %d bytes is shorter than the vanity", errInvalidIBFTExtra, len(extraData))
+	}
+
+	parser := &fastrlp.Parser{}
+
+	extra, err := parser.Parse(extraData[IBFTExtraVanity:])
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errInvalidIBFTExtra, err)
+	}
+
+	fields, err := extra.GetElems()
+	if err != nil || len(fields) == 0 {
+		return nil, fmt.Errorf("%w: expected a validator list", errInvalidIBFTExtra)
+	}
+
+	elems, err := fields[0].GetElems()
+	if err != nil {
+		return nil, fmt.Errorf("%w: expected a validator list", errInvalidIBFTExtra)
+	}
+
+	validators := make([]types.Address, 0, len(elems))
+
+	for i, validator := range elems {
+		if validator.Type() == fastrlp.TypeArray {
+			// BLS validators are [address, public key]
+			if validator.Elems() == 0 {
+				return nil, fmt.Errorf("%w: empty validator %d", errInvalidIBFTExtra, i)
+			}
+
+			validator = validator.Get(0)
+		}
+
+		address, err := validator.GetBytes(nil, types.AddressLength)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid address of validator %d", errInvalidIBFTExtra, i)
+		}
+
+		validators = append(validators, types.BytesToAddress(address))
+	}
+
+	return validators, nil
+}
+
+// encodeIBFTSerializedSeals encodes the committed seals of ECDSA validators,
+// the list of the seals, as polygon-edge's SerializedSeal
+func encodeIBFTSerializedSeals(ar *fastrlp.Arena, seals [][]byte) *fastrlp.Value {
+	if len(seals) == 0 {
+		return ar.NewNullArray()
+	}
+
+	encoded := ar.NewArray()
+	for _, seal := range seals {
+		encoded.Set(ar.NewCopyBytes(seal))
+	}
+
+	return encoded
+}
+
+// encodeIBFTAggregatedSeal encodes the committed seals of BLS validators, as polygon-edge's AggregatedSeal:
+// the bitmap of the signers and the aggregated signature, or an empty list without a signature
+func encodeIBFTAggregatedSeal(ar *fastrlp.Arena, bitmap *big.Int, signature []byte) *fastrlp.Value {
+	if signature == nil {
+		return ar.NewNullArray()
+	}
+
+	if bitmap == nil {
+		bitmap = big.NewInt(0)
+	}
+
+	encoded := ar.NewArray()
+	encoded.Set(ar.NewBigInt(bitmap))
+	encoded.Set(ar.NewCopyBytes(signature))
+
+	return encoded
+}
+
+// EncodeIBFTExtra encodes the genesis IBFT extra data of the validators.
+// The validators are BLS validators if the public keys are given, one per validator.
+// The IstanbulExtra has polygon-edge's genesis layout: the validators, an empty proposer seal,
+// the empty committed seals and parent committed seals, in the serialized form for ECDSA validators
+// and the aggregated form for BLS validators, and an empty round number
+func EncodeIBFTExtra(validators []types.Address, blsPublicKeys [][]byte) ([]byte, error) {
+	if blsPublicKeys != nil && len(blsPublicKeys) != len(validators) {
+		return nil, fmt.Errorf("%d BLS public keys for %d validators", len(blsPublicKeys), len(validators))
+	}
+
+	ar := &fastrlp.Arena{}
+
+	encodedValidators := ar.NewArray()
+
+	for i, validator := range validators {
+		if blsPublicKeys == nil {
+			encodedValidators.Set(ar.NewCopyBytes(validator.Bytes()))
+
+			continue
+		}
+
+		encodedValidator := ar.NewArray()
+		encodedValidator.Set(ar.NewCopyBytes(validator.Bytes()))
+		encodedValidator.Set(ar.NewCopyBytes(blsPublicKeys[i]))
+
+		encodedValidators.Set(encodedValidator)
+	}
+
+	seals := func() *fastrlp.Value {
+		if blsPublicKeys != nil {
+			return encodeIBFTAggregatedSeal(ar, nil, nil)
+		}
+
+		return encodeIBFTSerializedSeals(ar, nil)
+	}
+
+	extra := ar.NewArray()
+	extra.Set(encodedValidators)
+	extra.Set(ar.NewNull()) // proposer seal
+	extra.Set(seals())      // committed seals
+	extra.Set(seals())      // parent committed seals
+	extra.Set(ar.NewNull()) // round number
+
+	return extra.MarshalTo(make([]byte, IBFTExtraVanity)), nil
+}
+
+// ValidatorPositionMismatch is a validator found at different positions
+// in the IBFT extra data and in the staking SC
+type ValidatorPositionMismatch struct {
+	Address        types.Address
+	ExtraDataIndex int
+	StakingIndex   int
+}
+
+// ExtraDataCheckReport is the comparison of the IBFT extra data validators
+// with the validators of the staking SC
+type ExtraDataCheckReport struct {
+	ExtraDataValidators []types.Address
+	StakingValidators   []types.Address
+
+	// MissingFromStaking are the extra data validators that aren't staked
+	MissingFromStaking []types.Address
+
+	// MissingFromExtraData are the staked validators that aren't in the extra data
+	MissingFromExtraData []types.Address
+
+	// Misordered are the validators present on both sides, but in a different order.
+	// Only the displaced validators are listed: the ones outside the longest run
+	// of validators both sides list in the same order, so a single validator moved
+	// elsewhere is reported alone, and a swapped pair is reported as one move
+	Misordered []ValidatorPositionMismatch
+}
+
+// Consistent returns true if both sides list the same validators in the same order
+func (r *ExtraDataCheckReport) Consistent() bool {
+	return len(r.MissingFromStaking) == 0 && len(r.MissingFromExtraData) == 0 && len(r.Misordered) == 0
+}
+
+// String returns a human readable report
+func (r *ExtraDataCheckReport) String() string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "extra data validators: %d\n", len(r.ExtraDataValidators))
+	fmt.Fprintf(&b, "staking validators: %d\n", len(r.StakingValidators))
+
+	if r.Consistent() {
+		b.WriteString("validator lists match\n")
+
+		return b.String()
+	}
+
+	for _, validator := range r.MissingFromStaking {
+		fmt.Fprintf(&b, "  - %s: in the extra data, not staked\n", validator)
+	}
+
+	for _, validator := range r.MissingFromExtraData {
+		fmt.Fprintf(&b, "  - %s: staked, not in the extra data\n", validator)
+	}
+
+	for _, mismatch := range r.Misordered {
+		fmt.Fprintf(
+			&b,
+			"  - %s: at index %d of the extra data, index %d of the staking SC\n",
+			mismatch.Address,
+			mismatch.ExtraDataIndex,
+			mismatch.StakingIndex,
+		)
+	}
+
+	return b.String()
+}
+
+// CompareValidatorLists compares the IBFT extra data validators with the staking SC validators.
+// The order is only compared for the validators present on both sides
+func CompareValidatorLists(extraDataValidators, stakingValidators []types.Address) *ExtraDataCheckReport {
+	report := &ExtraDataCheckReport{
+		ExtraDataValidators: extraDataValidators,
+		StakingValidators:   stakingValidators,
+	}
+
+	extraDataIndex := make(map[types.Address]int, len(extraDataValidators))
+	for i, validator := range extraDataValidators {
+		extraDataIndex[validator] = i
+	}
+
+	stakingIndex := make(map[types.Address]int, len(stakingValidators))
+	for i, validator := range stakingValidators {
+		stakingIndex[validator] = i
+	}
+
+	for _, validator := range extraDataValidators {
+		if _, ok := stakingIndex[validator]; !ok {
+			report.MissingFromStaking = append(report.MissingFromStaking, validator)
+		}
+	}
+
+	// The extra data indexes of the shared validators, in staking order
+	shared := make([]types.Address, 0, len(stakingValidators))
+	sharedIndexes := make([]int, 0, len(stakingValidators))
+
+	for _, validator := range stakingValidators {
+		index, ok := extraDataIndex[validator]
+		if !ok {
+			report.MissingFromExtraData = append(report.MissingFromExtraData, validator)
+
+			continue
+		}
+
+		shared = append(shared, validator)
+		sharedIndexes = append(sharedIndexes, index)
+	}
+
+	inOrder := longestIncreasingRun(sharedIndexes)
+
+	for i, validator := range shared {
+		if !inOrder[i] {
+			report.Misordered = append(report.Misordered, ValidatorPositionMismatch{
+				Address:        validator,
+				ExtraDataIndex: extraDataIndex[validator],
+				StakingIndex:   stakingIndex[validator],
+			})
+		}
+	}
+
+	return report
+}
+
+// longestIncreasingRun marks the elements of the longest increasing subsequence of the values.
+// Among the subsequences of the same length, the one ending with the lowest values is kept
+func longestIncreasingRun(values []int) []bool {
+	// tails[k] is the position of the lowest value ending an increasing subsequence of length k + 1
+	tails := make([]int, 0, len(values))
+	previous := make([]int, len(values))
+
+	for i, value := range values {
+		k := sort.Search(len(tails), func(j int) bool {
+			return values[tails[j]] >= value
+		})
+
+		previous[i] = -1
+		if k > 0 {
+			previous[i] = tails[k-1]
+		}
+
+		if k == len(tails) {
+			tails = append(tails, i)
+		} else {
+			tails[k] = i
+		}
+	}
+
+	marked := make([]bool, len(values))
+	if len(tails) == 0 {
+		return marked
+	}
+
+	for i := tails[len(tails)-1]; i >= 0; i = previous[i] {
+		marked[i] = true
+	}
+
+	return marked
+}
+
+// CheckGenesisExtraData compares the validators of the IBFT genesis extra data
+// with the validators of the staking SC in the genesis alloc.
+// Both lists have to match, or the chain stalls at the first PoS epoch
+func CheckGenesisExtraData(genesis *chain.Genesis, stakingAddress types.Address) (*ExtraDataCheckReport, error) {
+	if genesis == nil {
+		return nil, fmt.Errorf("genesis is not set")
+	}
+
+	extraDataValidators, err := DecodeIBFTExtraValidators(genesis.ExtraData)
+	if err != nil {
+		return nil, err
+	}
+
+	account, ok := genesis.Alloc[stakingAddress]
+	if !ok {
+		return nil, fmt.Errorf("staking SC %s is not in the genesis alloc", stakingAddress)
+	}
+
+	stakingValidators, err := NewStakingView(NewGenesisAccountReader(account)).Validators()
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the staking SC validators, %w", err)
+	}
+
+	return CompareValidatorLists(extraDataValidators, stakingValidators), nil
+}
@@ -0,0 +1,274 @@
+package staking
+
+import (
+	"bytes"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/0xPolygon/polygon-edge/chain"
+	"github.com/0xPolygon/polygon-edge/types"
+	"github.com/umbracle/fastrlp"
+)
+
+func TestEncodeIBFTExtraRoundTrip(t *testing.T) {
+	validators := generatorValidators(4)
+
+	blsPublicKeys := make([][]byte, len(validators))
+	for i := range blsPublicKeys {
+		blsPublicKeys[i] = bytes.Repeat([]byte{byte(i + 1)}, 48)
+	}
+
+	cases := []struct {
+		name          string
+		blsPublicKeys [][]byte
+	}{
+		{"ecdsa", nil},
+		{"bls", blsPublicKeys},
+	}
+
+	for _, c := range cases {
+		c := c
+
+		t.Run(c.name, func(t *testing.T) {
+			extraData, err := EncodeIBFTExtra(validators, c.blsPublicKeys)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			decoded, err := DecodeIBFTExtraValidators(extraData)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(decoded) != len(validators) {
+				t.Fatalf("expected %d validators, got %d", len(validators), len(decoded))
+			}
+
+			for i := range validators {
+				if decoded[i] != validators[i] {
+					t.Errorf("validator %d: expected %s, got %s", i, validators[i], decoded[i])
+				}
+			}
+
+			extra, err := (&fastrlp.Parser{}).Parse(extraData[IBFTExtraVanity:])
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// validators, proposer seal, committed seals, parent committed seals and round number
+			if extra.Elems() != 5 {
+				t.Fatalf("expected 5 IstanbulExtra fields, got %d", extra.Elems())
+			}
+
+			for i, isList := range []bool{true, false, true, true, false} {
+				field := extra.Get(i)
+				if (field.Type() == fastrlp.TypeArray) != isList || (i > 0 && len(field.Raw())+field.Elems() != 0) {
+					t.Errorf("unexpected IstanbulExtra field %d", i)
+				}
+			}
+		})
+	}
+
+	if _, err := EncodeIBFTExtra(validators, blsPublicKeys[:1]); err == nil {
+		t.Fatal("expected an error for a missing BLS public key")
+	}
+}
+
+func TestEncodeIBFTAggregatedSeal(t *testing.T) {
+	signature := bytes.Repeat([]byte{0xab}, 96)
+
+	// The bitmap has a bit per validator that signed, the first and the third here
+	ar := &fastrlp.Arena{}
+	encoded := encodeIBFTAggregatedSeal(ar, big.NewInt(5), signature).MarshalTo(nil)
+
+	seal, err := (&fastrlp.Parser{}).Parse(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if seal.Type() != fastrlp.TypeArray || seal.Elems() != 2 ||
+		!bytes.Equal(seal.Get(0).Raw(), []byte{0x5}) || !bytes.Equal(seal.Get(1).Raw(), signature) {
+		t.Fatal("expected a [bitmap, signature] aggregated seal")
+	}
+
+	// Without a signature, the seal is an empty list
+	if empty := encodeIBFTAggregatedSeal(ar, nil, nil).MarshalTo(nil); !bytes.Equal(empty, []byte{0xc0}) {
+		t.Fatalf("expected an empty list, got %x", empty)
+	}
+}
+
+func TestCompareValidatorLists(t *testing.T) {
+	v := generatorValidators(5)
+	outsider := types.StringToAddress("0xdead")
+
+	cases := []struct {
+		name                 string
+		extraData            []types.Address
+		staking              []types.Address
+		missingFromStaking   []types.Address
+		missingFromExtraData []types.Address
+		misordered           []ValidatorPositionMismatch
+	}{
+		{
+			name:      "consistent",
+			extraData: v,
+			staking:   v,
+		},
+		{
+			name:               "missing from staking",
+			extraData:          []types.Address{v[0], outsider, v[1], v[2]},
+			staking:            []types.Address{v[0], v[1], v[2]},
+			missingFromStaking: []types.Address{outsider},
+		},
+		{
+			name:                 "missing from extra data",
+			extraData:            []types.Address{v[0], v[1], v[2]},
+			staking:              []types.Address{v[0], v[1], outsider, v[2]},
+			missingFromExtraData: []types.Address{outsider},
+		},
+		{
+			name:      "swapped pair",
+			extraData: []types.Address{v[0], v[1], v[2], v[3]},
+			staking:   []types.Address{v[0], v[2], v[1], v[3]},
+			misordered: []ValidatorPositionMismatch{
+				{Address: v[2], ExtraDataIndex: 2, StakingIndex: 1},
+			},
+		},
+		{
+			name:      "single displaced validator",
+			extraData: []types.Address{v[0], v[1], v[2], v[3], v[4]},
+			staking:   []types.Address{v[1], v[2], v[3], v[4], v[0]},
+			misordered: []ValidatorPositionMismatch{
+				{Address: v[0], ExtraDataIndex: 0, StakingIndex: 4},
+			},
+		},
+		{
+			name:      "reversed",
+			extraData: []types.Address{v[0], v[1], v[2]},
+			staking:   []types.Address{v[2], v[1], v[0]},
+			misordered: []ValidatorPositionMismatch{
+				{Address: v[2], ExtraDataIndex: 2, StakingIndex: 0},
+				{Address: v[1], ExtraDataIndex: 1, StakingIndex: 1},
+			},
+		},
+		{
+			name:                 "missing and displaced",
+			extraData:            []types.Address{v[0], v[1], v[2], outsider},
+			staking:              []types.Address{v[2], v[0], v[3], v[1]},
+			missingFromStaking:   []types.Address{outsider},
+			missingFromExtraData: []types.Address{v[3]},
+			misordered: []ValidatorPositionMismatch{
+				{Address: v[2], ExtraDataIndex: 2, StakingIndex: 0},
+			},
+		},
+	}
+
+	equalAddresses := func(a, b []types.Address) bool {
+		if len(a) != len(b) {
+			return false
+		}
+
+		for i := range a {
+			if a[i] != b[i] {
+				return false
+			}
+		}
+
+		return true
+	}
+
+	for _, c := range cases {
+		c := c
+
+		t.Run(c.name, func(t *testing.T) {
+			report := CompareValidatorLists(c.extraData, c.staking)
+
+			if !equalAddresses(report.MissingFromStaking, c.missingFromStaking) {
+				t.Fatalf("expected missing from staking %v, got %v", c.missingFromStaking, report.MissingFromStaking)
+			}
+
+			if !equalAddresses(report.MissingFromExtraData, c.missingFromExtraData) {
+				t.Fatalf(
+					"expected missing from extra data %v, got %v",
+					c.missingFromExtraData,
+					report.MissingFromExtraData,
+				)
+			}
+
+			if len(report.Misordered) != len(c.misordered) {
+				t.Fatalf("expected misordered %v, got %v", c.misordered, report.Misordered)
+			}
+
+			for i, mismatch := range c.misordered {
+				if report.Misordered[i] != mismatch {
+					t.Fatalf("expected misordered %v, got %v", c.misordered, report.Misordered)
+				}
+			}
+
+			consistent := len(c.missingFromStaking)+len(c.missingFromExtraData)+len(c.misordered) == 0
+			if report.Consistent() != consistent {
+				t.Fatalf("expected consistent to be %v, got %s", consistent, report)
+			}
+		})
+	}
+}
+
+func TestCheckGenesisExtraData(t *testing.T) {
+	validators := generatorValidators(3)
+
+	stakingAccount, err := PredeployStakingSC(validators, PredeployParams{
+		MinValidatorCount: 1,
+		MaxValidatorCount: 3,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	genesisWith := func(t *testing.T, extraDataValidators []types.Address) *chain.Genesis {
+		t.Helper()
+
+		extraData, err := EncodeIBFTExtra(extraDataValidators, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return &chain.Genesis{
+			ExtraData: extraData,
+			Alloc: map[types.Address]*chain.GenesisAccount{
+				DefaultStakingSCAddress: stakingAccount,
+			},
+		}
+	}
+
+	report, err := CheckGenesisExtraData(genesisWith(t, validators), DefaultStakingSCAddress)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !report.Consistent() {
+		t.Fatalf("expected the lists to match, got %s", report)
+	}
+
+	// The extra data lists the validators in another order, and misses one of them
+	report, err = CheckGenesisExtraData(
+		genesisWith(t, []types.Address{validators[1], validators[0]}),
+		DefaultStakingSCAddress,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if report.Consistent() || len(report.Misordered) != 1 ||
+		len(report.MissingFromExtraData) != 1 || report.MissingFromExtraData[0] != validators[2] {
+		t.Fatalf("expected a displaced and a missing validator, got %s", report)
+	}
+
+	if !strings.Contains(report.String(), validators[2].String()+": staked, not in the extra data") {
+		t.Fatalf("expected the missing validator in the report, got %s", report)
+	}
+
+	if _, err := CheckGenesisExtraData(genesisWith(t, validators), types.StringToAddress("0x99")); err == nil {
+		t.Fatal("expected an error for a missing staking SC")
+	}
+}
@@ -0,0 +1,384 @@
+package staking
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"strconv"
+
+	"github.com/0xPolygon/polygon-edge/chain"
+	"github.com/0xPolygon/polygon-edge/helper/common"
+	"github.com/0xPolygon/polygon-edge/helper/hex"
+	"github.com/0xPolygon/polygon-edge/types"
+)
+
+// ValidatorCountForksKey is the key of the validator count schedule in the consensus engine config
+const ValidatorCountForksKey = "validatorCountForks"
+
+// Keys of a fork in the validator count schedule
+const (
+	forkBlockKey             = "block"
+	forkMinValidatorCountKey = "minValidatorCount"
+	forkMaxValidatorCountKey = "maxValidatorCount"
+)
+
+var (
+	errEmptyValidatorCountFork     = errors.New("fork doesn't change any validator count bound")
+	errUnorderedValidatorCountFork = errors.New("forks must be ordered by strictly increasing block")
+	errInvalidBounds               = errors.New("minimum validator count is greater than the maximum")
+)
+
+// ValidatorCountFork sets the validator count bounds of the staking SC at a given block.
+// A nil bound is left unchanged
+type ValidatorCountFork struct {
+	Block             uint64  `json:"block"`
+	MinValidatorCount *uint64 `json:"minValidatorCount,omitempty"`
+	MaxValidatorCount *uint64 `json:"maxValidatorCount,omitempty"`
+}
+
+// apply returns the bounds after the fork
+func (f *ValidatorCountFork) apply(params PredeployParams) PredeployParams {
+	if f.MinValidatorCount != nil {
+		params.MinValidatorCount = *f.MinValidatorCount
+	}
+
+	if f.MaxValidatorCount != nil {
+		params.MaxValidatorCount = *f.MaxValidatorCount
+	}
+
+	return params
+}
+
+// checkBounds checks that the bounds are consistent and contain the validator count
+func checkBounds(params PredeployParams, validatorCount uint64) error {
+	if params.MinValidatorCount > params.MaxValidatorCount {
+		return fmt.Errorf(
+			"%w: [%d, %d]",
+			errInvalidBounds,
+			params.MinValidatorCount,
+			params.MaxValidatorCount,
+		)
+	}
+
+	if validatorCount < params.MinValidatorCount || validatorCount > params.MaxValidatorCount {
+		return fmt.Errorf(
+			"%w: %d validators, bounds [%d, %d]",
+			errValidatorCount,
+			validatorCount,
+			params.MinValidatorCount,
+			params.MaxValidatorCount,
+		)
+	}
+
+	return nil
+}
+
+// StorageOverrides returns the storage words the fork writes to the staking SC,
+// slot 5 for the minimum and slot 6 for the maximum validator count
+func (f *ValidatorCountFork) StorageOverrides() map[types.Hash]types.Hash {
+	overrides := make(map[types.Hash]types.Hash, 2)
+
+	if f.MinValidatorCount != nil {
+		// Set the value for the minimum number of validators
+		overrides[types.BytesToHash(big.NewInt(minNumValidatorSlot).Bytes())] =
+			types.BytesToHash(new(big.Int).SetUint64(*f.MinValidatorCount).Bytes())
+	}
+
+	if f.MaxValidatorCount != nil {
+		// Set the value for the maximum number of validators
+		overrides[types.BytesToHash(big.NewInt(maxNumValidatorSlot).Bytes())] =
+			types.BytesToHash(new(big.Int).SetUint64(*f.MaxValidatorCount).Bytes())
+	}
+
+	return overrides
+}
+
+// ValidatorCountSchedule is the list of validator count forks, ordered by block
+type ValidatorCountSchedule []*ValidatorCountFork
+
+// checkOrder checks that every fork changes a bound, and that the forks are ordered by block
+func (s ValidatorCountSchedule) checkOrder() error {
+	for i, fork := range s {
+		if fork == nil || (fork.MinValidatorCount == nil && fork.MaxValidatorCount == nil) {
+			return fmt.Errorf("fork %d, %w", i, errEmptyValidatorCountFork)
+		}
+
+		if i > 0 && fork.Block <= s[i-1].Block {
+			return fmt.Errorf("fork %d at block %d, %w", i, fork.Block, errUnorderedValidatorCountFork)
+		}
+	}
+
+	return nil
+}
+
+// Validate checks the schedule against the genesis bounds and validator count.
+// The validator count is the genesis one until block 1 is executed, and afterwards
+// it can be anything the staking SC allows within the bounds in effect.
+// So a fork is rejected when its bounds can't contain any of the validator counts
+// possible before it. Whether the actual count fits is checked by Overrides
+func (s ValidatorCountSchedule) Validate(params PredeployParams, validatorCount uint64) error {
+	if err := s.checkOrder(); err != nil {
+		return err
+	}
+
+	lowest, highest := validatorCount, validatorCount
+
+	for _, fork := range s {
+		if fork.Block > 1 {
+			lowest, highest = params.MinValidatorCount, params.MaxValidatorCount
+		}
+
+		params = fork.apply(params)
+
+		if params.MinValidatorCount > params.MaxValidatorCount {
+			return fmt.Errorf(
+				"fork at block %d, %w: [%d, %d]",
+				fork.Block,
+				errInvalidBounds,
+				params.MinValidatorCount,
+				params.MaxValidatorCount,
+			)
+		}
+
+		if params.MinValidatorCount > highest || params.MaxValidatorCount < lowest {
+			return fmt.Errorf(
+				"fork at block %d, %w: %d to %d validators before the fork, bounds [%d, %d]",
+				fork.Block,
+				errValidatorCount,
+				lowest,
+				highest,
+				params.MinValidatorCount,
+				params.MaxValidatorCount,
+			)
+		}
+	}
+
+	return nil
+}
+
+// ParamsAt returns the bounds in effect at the given block,
+// starting from the genesis bounds
+func (s ValidatorCountSchedule) ParamsAt(params PredeployParams, block uint64) PredeployParams {
+	for _, fork := range s {
+		if fork.Block > block {
+			break
+		}
+
+		params = fork.apply(params)
+	}
+
+	return params
+}
+
+// ForkAt returns the fork scheduled at the given block, or nil if there is none
+func (s ValidatorCountSchedule) ForkAt(block uint64) *ValidatorCountFork {
+	indx := sort.Search(len(s), func(i int) bool {
+		return s[i].Block >= block
+	})
+
+	if indx < len(s) && s[indx].Block == block {
+		return s[indx]
+	}
+
+	return nil
+}
+
+// Overrides returns the storage overrides of the fork at the given block,
+// after validating them against the staking SC state before the block.
+// It returns nil if no fork is scheduled at the block
+func (s ValidatorCountSchedule) Overrides(reader StateReader, block uint64) (map[types.Hash]types.Hash, error) {
+	fork := s.ForkAt(block)
+	if fork == nil {
+		return nil, nil
+	}
+
+	view := NewStakingView(reader)
+
+	params, err := view.Bounds()
+	if err != nil {
+		return nil, err
+	}
+
+	validators, err := view.Validators()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkBounds(fork.apply(params), uint64(len(validators))); err != nil {
+		return nil, fmt.Errorf("fork at block %d, %w", block, err)
+	}
+
+	return fork.StorageOverrides(), nil
+}
+
+// Apply writes the overrides of the fork at the given block to the staking SC.
+// It's meant to be called from the state override hook at the start of every block
+func (s ValidatorCountSchedule) Apply(
+	reader StateReader,
+	writer StateWriter,
+	stakingAddress types.Address,
+	block uint64,
+) error {
+	overrides, err := s.Overrides(reader, block)
+	if err != nil {
+		return err
+	}
+
+	keys := make([]types.Hash, 0, len(overrides))
+	for key := range overrides {
+		keys = append(keys, key)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].String() < keys[j].String()
+	})
+
+	for _, key := range keys {
+		writer.SetState(stakingAddress, key, overrides[key])
+	}
+
+	return nil
+}
+
+// engineConfig returns the config of the consensus engine
+func engineConfig(params *chain.Params, engine string) (map[string]interface{}, error) {
+	if params == nil || params.Engine == nil {
+		return nil, fmt.Errorf("chain config has no consensus engine")
+	}
+
+	raw, ok := params.Engine[engine]
+	if !ok {
+		return nil, fmt.Errorf("chain config has no %q consensus engine", engine)
+	}
+
+	if raw == nil {
+		return make(map[string]interface{}), nil
+	}
+
+	config, ok := raw.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid %q consensus engine config", engine)
+	}
+
+	return config, nil
+}
+
+// engineNumber returns the engine config form of the number. The numbers of the genesis file
+// are decoded as float64, so the ones above 2^53 are stored as hex strings to keep their precision
+func engineNumber(value uint64) interface{} {
+	if value > common.MaxSafeJSInt {
+		return hex.EncodeUint64(value)
+	}
+
+	return json.Number(strconv.FormatUint(value, 10))
+}
+
+// SetValidatorCountSchedule stores the schedule in the config of the consensus engine,
+// so it's part of the chain config every node starts from
+func SetValidatorCountSchedule(params *chain.Params, engine string, schedule ValidatorCountSchedule) error {
+	config, err := engineConfig(params, engine)
+	if err != nil {
+		return err
+	}
+
+	if err := schedule.checkOrder(); err != nil {
+		return err
+	}
+
+	// The schedule is stored in its decoded JSON form, the same way it's read from the genesis file
+	forks := make([]interface{}, len(schedule))
+
+	for i, fork := range schedule {
+		value := map[string]interface{}{
+			forkBlockKey: engineNumber(fork.Block),
+		}
+
+		if fork.MinValidatorCount != nil {
+			value[forkMinValidatorCountKey] = engineNumber(*fork.MinValidatorCount)
+		}
+
+		if fork.MaxValidatorCount != nil {
+			value[forkMaxValidatorCountKey] = engineNumber(*fork.MaxValidatorCount)
+		}
+
+		forks[i] = value
+	}
+
+	config[ValidatorCountForksKey] = forks
+	params.Engine[engine] = config
+
+	return nil
+}
+
+// parseValidatorCountFork parses a fork of the engine config.
+// The numbers go through engineUint64, which rejects float64 values that lost their precision
+func parseValidatorCountFork(value interface{}) (*ValidatorCountFork, error) {
+	fields, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid fork of type %T", value)
+	}
+
+	if _, ok := fields[forkBlockKey]; !ok {
+		return nil, fmt.Errorf("fork has no %s", forkBlockKey)
+	}
+
+	fork := &ValidatorCountFork{}
+
+	for key, field := range fields {
+		number, err := engineUint64(key, field)
+		if err != nil {
+			return nil, err
+		}
+
+		switch key {
+		case forkBlockKey:
+			fork.Block = number
+		case forkMinValidatorCountKey:
+			fork.MinValidatorCount = &number
+		case forkMaxValidatorCountKey:
+			fork.MaxValidatorCount = &number
+		default:
+			return nil, fmt.Errorf("unknown fork field %q", key)
+		}
+	}
+
+	return fork, nil
+}
+
+// GetValidatorCountSchedule reads the schedule from the config of the consensus engine.
+// It returns an empty schedule if the config doesn't have one
+func GetValidatorCountSchedule(params *chain.Params, engine string) (ValidatorCountSchedule, error) {
+	config, err := engineConfig(params, engine)
+	if err != nil {
+		return nil, err
+	}
+
+	value, ok := config[ValidatorCountForksKey]
+	if !ok || value == nil {
+		return ValidatorCountSchedule{}, nil
+	}
+
+	forks, ok := value.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid %s of type %T", ValidatorCountForksKey, value)
+	}
+
+	schedule := make(ValidatorCountSchedule, len(forks))
+
+	for i, raw := range forks {
+		fork, err := parseValidatorCountFork(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s, fork %d, %w", ValidatorCountForksKey, i, err)
+		}
+
+		schedule[i] = fork
+	}
+
+	if err := schedule.checkOrder(); err != nil {
+		return nil, fmt.Errorf("invalid %s, %w", ValidatorCountForksKey, err)
+	}
+
+	return schedule, nil
+}
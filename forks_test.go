@@ -0,0 +1,109 @@
+package staking
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/0xPolygon/polygon-edge/chain"
+)
+
+func forkBound(value uint64) *uint64 {
+	return &value
+}
+
+func TestValidatorCountScheduleEngineConfig(t *testing.T) {
+	params := &chain.Params{
+		Engine: map[string]interface{}{
+			IBFTEngineName: map[string]interface{}{},
+		},
+	}
+
+	// Bounds above 2^53 can't be represented by the float64 numbers of a decoded genesis file
+	schedule := ValidatorCountSchedule{
+		{Block: 10, MaxValidatorCount: forkBound(1<<60 + 1)},
+		{Block: 1<<62 + 3, MinValidatorCount: forkBound(4)},
+	}
+
+	if err := SetValidatorCountSchedule(params, IBFTEngineName, schedule); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := GetValidatorCountSchedule(params, IBFTEngineName)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(got) != 2 ||
+		got[0].Block != 10 || got[0].MinValidatorCount != nil || *got[0].MaxValidatorCount != 1<<60+1 ||
+		got[1].Block != 1<<62+3 || *got[1].MinValidatorCount != 4 || got[1].MaxValidatorCount != nil {
+		t.Fatalf("schedule didn't round trip, got %+v and %+v", got[0], got[1])
+	}
+
+	// A float64 that lost its precision is rejected instead of being rounded
+	params.Engine[IBFTEngineName] = map[string]interface{}{
+		ValidatorCountForksKey: []interface{}{
+			map[string]interface{}{"block": float64(1<<60 + 1)},
+		},
+	}
+
+	if _, err := GetValidatorCountSchedule(params, IBFTEngineName); err == nil {
+		t.Fatal("expected an invalid block error")
+	}
+}
+
+func TestValidatorCountScheduleValidate(t *testing.T) {
+	genesis := PredeployParams{
+		MinValidatorCount: 1,
+		MaxValidatorCount: 10,
+	}
+
+	cases := []struct {
+		name     string
+		schedule ValidatorCountSchedule
+		err      error
+	}{
+		{
+			// The validator count can grow to 10 before the fork
+			name:     "minimum above the genesis validator count",
+			schedule: ValidatorCountSchedule{{Block: 100, MinValidatorCount: forkBound(6)}},
+		},
+		{
+			name:     "minimum above the genesis validator count at block 1",
+			schedule: ValidatorCountSchedule{{Block: 1, MinValidatorCount: forkBound(6)}},
+			err:      errValidatorCount,
+		},
+		{
+			name:     "minimum above the previous maximum",
+			schedule: ValidatorCountSchedule{{Block: 100, MinValidatorCount: forkBound(11), MaxValidatorCount: forkBound(20)}},
+			err:      errValidatorCount,
+		},
+		{
+			name: "maximum below the minimum of the previous fork",
+			schedule: ValidatorCountSchedule{
+				{Block: 100, MinValidatorCount: forkBound(8)},
+				{Block: 200, MaxValidatorCount: forkBound(7), MinValidatorCount: forkBound(1)},
+			},
+			err: errValidatorCount,
+		},
+		{
+			name:     "inverted bounds",
+			schedule: ValidatorCountSchedule{{Block: 100, MaxValidatorCount: forkBound(0)}},
+			err:      errInvalidBounds,
+		},
+	}
+
+	for _, c := range cases {
+		c := c
+
+		t.Run(c.name, func(t *testing.T) {
+			err := c.schedule.Validate(genesis, 3)
+			if c.err == nil && err != nil {
+				t.Fatal(err)
+			}
+
+			if c.err != nil && !errors.Is(err, c.err) {
+				t.Fatalf("expected %v, got %v", c.err, err)
+			}
+		})
+	}
+}
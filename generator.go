@@ -0,0 +1,344 @@
+package staking
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math/big"
+	"runtime"
+
+	"github.com/0xPolygon/polygon-edge/chain"
+	"github.com/0xPolygon/polygon-edge/helper/hex"
+	"github.com/0xPolygon/polygon-edge/helper/keccak"
+	"github.com/0xPolygon/polygon-edge/types"
+)
+
+// generatorBatchSize is the number of validators a single worker processes at a time
+const generatorBatchSize = 1024
+
+// StorageEntry is a single storage word of a genesis account
+type StorageEntry struct {
+	Key   types.Hash
+	Value types.Hash
+}
+
+// StorageGenerator generates the staking SC storage for very large validator sets.
+// Unlike PredeployStakingSC, it writes every slot exactly once, reuses the
+// hashing buffers, derives the mapping keys on several goroutines and can emit
+// the entries one by one instead of building the whole storage map.
+// The generated storage is identical to the one of PredeployStakingSCWithStakes
+type StorageGenerator struct {
+	Validators []types.Address
+	Stakes     map[types.Address]*big.Int
+	Params     PredeployParams
+
+	// Workers is the number of goroutines deriving the mapping keys.
+	// runtime.GOMAXPROCS is used if it's not set
+	Workers int
+}
+
+// generatorBatch is the chunk of validators processed by a single worker
+type generatorBatch struct {
+	entries []StorageEntry
+	staked  *big.Int
+	err     error
+}
+
+// slotKeyHasher derives mapping keys with reusable buffers
+type slotKeyHasher struct {
+	hasher *keccak.Keccak
+	buf    [64]byte
+}
+
+func newSlotKeyHasher() *slotKeyHasher {
+	return &slotKeyHasher{
+		hasher: keccak.NewKeccak256(),
+	}
+}
+
+// addressMapping is the allocation free equivalent of getAddressMapping
+func (h *slotKeyHasher) addressMapping(address types.Address, slot int64) types.Hash {
+	var key types.Hash
+
+	for i := 0; i < 12; i++ {
+		h.buf[i] = 0
+	}
+
+	copy(h.buf[12:32], address.Bytes())
+
+	for i := 32; i < 64; i++ {
+		h.buf[i] = 0
+	}
+
+	big.NewInt(slot).FillBytes(h.buf[32:64])
+
+	h.hasher.Reset()
+	_, _ = h.hasher.Write(h.buf[:])
+	h.hasher.Sum(key[:0])
+
+	return key
+}
+
+// addToHash adds a small offset to a 256-bit big-endian value, wrapping around like the EVM
+func addToHash(base types.Hash, offset uint64) types.Hash {
+	result := base
+	carry := offset
+
+	for i := len(result) - 1; i >= 0 && carry > 0; i-- {
+		sum := uint64(result[i]) + (carry & 0xff)
+		result[i] = byte(sum)
+		carry = (carry >> 8) + (sum >> 8)
+	}
+
+	return result
+}
+
+// Generate emits every storage entry of the staking SC in a deterministic order,
+// and returns the total staked amount
+func (g *StorageGenerator) Generate(emit func(StorageEntry) error) (*big.Int, error) {
+	switch g.Params.StorageMode {
+	case "", StorageModeExplicit:
+	case StorageModeSparse:
+		emitAll := emit
+		emit = func(entry StorageEntry) error {
+			if entry.Value == types.ZeroHash {
+				return nil
+			}
+
+			return emitAll(entry)
+		}
+	default:
+		return nil, fmt.Errorf("unknown storage mode %q", string(g.Params.StorageMode))
+	}
+
+	val := DefaultStakedBalance
+
+	defaultStake, err := types.ParseUint256orHex(&val)
+	if err != nil {
+		return nil, fmt.Errorf("unable to generate DefaultStatkedBalance, %w", err)
+	}
+
+	validators, err := orderValidators(g.Validators, g.Stakes, defaultStake, g.Params.Ordering)
+	if err != nil {
+		return nil, err
+	}
+
+	workers := g.Workers
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+
+	// The base index of the _validators array is the same for every validator
+	validatorsBase := types.BytesToHash(
+		keccak.Keccak256(nil, types.BytesToHash(big.NewInt(validatorsSlot).Bytes()).Bytes()),
+	)
+	trueValue := types.BytesToHash(big.NewInt(1).Bytes())
+	defaultStakeValue := types.BytesToHash(defaultStake.Bytes())
+
+	processBatch := func(hasher *slotKeyHasher, start int) generatorBatch {
+		end := start + generatorBatchSize
+		if end > len(validators) {
+			end = len(validators)
+		}
+
+		batch := generatorBatch{
+			entries: make([]StorageEntry, 0, 4*(end-start)),
+			staked:  big.NewInt(0),
+		}
+
+		for indx := start; indx < end; indx++ {
+			validator := validators[indx]
+
+			stakeValue := defaultStakeValue
+			stake := defaultStake
+
+			if customStake, ok := g.Stakes[validator]; ok {
+				if customStake == nil || customStake.Sign() < 0 {
+					batch.err = fmt.Errorf("invalid stake for validator %s", validator)
+
+					return batch
+				}
+
+				stake = customStake
+				stakeValue = types.BytesToHash(customStake.Bytes())
+			}
+
+			batch.staked.Add(batch.staked, stake)
+
+			batch.entries = append(batch.entries,
+				StorageEntry{
+					Key:   addToHash(validatorsBase, uint64(indx)),
+					Value: types.BytesToHash(validator.Bytes()),
+				},
+				StorageEntry{
+					Key:   hasher.addressMapping(validator, addressToIsValidatorSlot),
+					Value: trueValue,
+				},
+				StorageEntry{
+					Key:   hasher.addressMapping(validator, addressToStakedAmountSlot),
+					Value: stakeValue,
+				},
+				StorageEntry{
+					Key:   hasher.addressMapping(validator, addressToValidatorIndexSlot),
+					Value: types.BytesToHash(new(big.Int).SetUint64(uint64(indx)).Bytes()),
+				},
+			)
+		}
+
+		return batch
+	}
+
+	// Workers pick up batches in order, and the results are queued
+	// in the same order, so the output doesn't depend on scheduling
+	type job struct {
+		start  int
+		result chan generatorBatch
+	}
+
+	jobs := make(chan job)
+	results := make(chan chan generatorBatch, workers)
+	done := make(chan struct{})
+
+	defer close(done)
+
+	for i := 0; i < workers; i++ {
+		go func() {
+			hasher := newSlotKeyHasher()
+
+			for j := range jobs {
+				j.result <- processBatch(hasher, j.start)
+			}
+		}()
+	}
+
+	go func() {
+		defer close(jobs)
+		defer close(results)
+
+		for start := 0; start < len(validators); start += generatorBatchSize {
+			result := make(chan generatorBatch, 1)
+
+			select {
+			case results <- result:
+			case <-done:
+				return
+			}
+
+			select {
+			case jobs <- job{start: start, result: result}:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	stakedAmount := big.NewInt(0)
+
+	for result := range results {
+		batch := <-result
+		if batch.err != nil {
+			return nil, batch.err
+		}
+
+		for _, entry := range batch.entries {
+			if err := emit(entry); err != nil {
+				return nil, err
+			}
+		}
+
+		stakedAmount.Add(stakedAmount, batch.staked)
+	}
+
+	// The aggregate slots are written once, after every validator is processed
+	aggregates := []StorageEntry{
+		{
+			Key:   types.BytesToHash(big.NewInt(minNumValidatorSlot).Bytes()),
+			Value: types.BytesToHash(new(big.Int).SetUint64(g.Params.MinValidatorCount).Bytes()),
+		},
+		{
+			Key:   types.BytesToHash(big.NewInt(maxNumValidatorSlot).Bytes()),
+			Value: types.BytesToHash(new(big.Int).SetUint64(g.Params.MaxValidatorCount).Bytes()),
+		},
+	}
+
+	if len(validators) > 0 {
+		aggregates = append(aggregates,
+			StorageEntry{
+				Key:   types.BytesToHash(big.NewInt(stakedAmountSlot).Bytes()),
+				Value: types.BytesToHash(stakedAmount.Bytes()),
+			},
+			StorageEntry{
+				Key:   types.BytesToHash([]byte{byte(validatorsSlot)}),
+				Value: types.BytesToHash(new(big.Int).SetUint64(uint64(len(validators))).Bytes()),
+			},
+		)
+	}
+
+	for _, entry := range aggregates {
+		if err := emit(entry); err != nil {
+			return nil, err
+		}
+	}
+
+	return stakedAmount, nil
+}
+
+// Account generates the staking SC genesis account with the full storage map in memory
+func (g *StorageGenerator) Account() (*chain.GenesisAccount, error) {
+	scHex, _ := hex.DecodeHex(StakingSCBytecode)
+	storageMap := make(map[types.Hash]types.Hash, 4*len(g.Validators)+4)
+
+	stakedAmount, err := g.Generate(func(entry StorageEntry) error {
+		storageMap[entry.Key] = entry.Value
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &chain.GenesisAccount{
+		Code:    scHex,
+		Storage: storageMap,
+		Balance: stakedAmount,
+	}, nil
+}
+
+// WriteStorageJSON streams the storage of the staking SC to the writer as a JSON object,
+// in the format of the genesis account storage, and returns the total staked amount
+// (the balance of the staking SC)
+func (g *StorageGenerator) WriteStorageJSON(w io.Writer) (*big.Int, error) {
+	bw := bufio.NewWriter(w)
+	first := true
+
+	if _, err := bw.WriteString("{"); err != nil {
+		return nil, err
+	}
+
+	stakedAmount, err := g.Generate(func(entry StorageEntry) error {
+		if !first {
+			if err := bw.WriteByte(','); err != nil {
+				return err
+			}
+		}
+
+		first = false
+
+		_, err := fmt.Fprintf(bw, "\n\t%q: %q", entry.Key.String(), entry.Value.String())
+
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := bw.WriteString("\n}\n"); err != nil {
+		return nil, err
+	}
+
+	if err := bw.Flush(); err != nil {
+		return nil, err
+	}
+
+	return stakedAmount, nil
+}
@@ -0,0 +1,168 @@
+package staking
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/0xPolygon/polygon-edge/types"
+)
+
+// generatorValidators returns distinct, unordered validator addresses
+func generatorValidators(count int) []types.Address {
+	validators := make([]types.Address, count)
+	for i := range validators {
+		validators[i] = types.BytesToAddress(big.NewInt(int64(i*7919 + 13)).Bytes())
+	}
+
+	return validators
+}
+
+func TestStorageGeneratorMatchesPredeploy(t *testing.T) {
+	for _, count := range []int{0, 1, 5, 3000} {
+		validators := generatorValidators(count)
+
+		stakes := make(map[types.Address]*big.Int)
+		if count > 2 {
+			stakes[validators[1]] = big.NewInt(77)
+			stakes[validators[2]] = big.NewInt(0)
+		}
+
+		for _, ordering := range []ValidatorOrdering{OrderByInput, OrderByAddress, OrderByStake} {
+			for _, mode := range []StorageMode{StorageModeExplicit, StorageModeSparse} {
+				params := PredeployParams{
+					MinValidatorCount: 1,
+					MaxValidatorCount: 10000,
+					Ordering:          ordering,
+					StorageMode:       mode,
+				}
+
+				t.Run(fmt.Sprintf("%d validators %s %s", count, ordering, mode), func(t *testing.T) {
+					want, err := PredeployStakingSCWithStakes(validators, stakes, params)
+					if err != nil {
+						t.Fatal(err)
+					}
+
+					generator := &StorageGenerator{
+						Validators: validators,
+						Stakes:     stakes,
+						Params:     params,
+						Workers:    3,
+					}
+
+					got, err := generator.Account()
+					if err != nil {
+						t.Fatal(err)
+					}
+
+					if !bytes.Equal(want.Code, got.Code) {
+						t.Fatal("generated code doesn't match the predeployed one")
+					}
+
+					assertSameAccount(t, want, got)
+
+					var encoded strings.Builder
+					if _, err := generator.WriteStorageJSON(&encoded); err != nil {
+						t.Fatal(err)
+					}
+
+					storage := make(map[types.Hash]types.Hash)
+					if err := json.Unmarshal([]byte(encoded.String()), &storage); err != nil {
+						t.Fatal(err)
+					}
+
+					if !reflect.DeepEqual(want.Storage, storage) {
+						t.Fatal("streamed storage doesn't match the predeployed one")
+					}
+				})
+			}
+		}
+	}
+}
+
+func TestStorageGeneratorDuplicateValidator(t *testing.T) {
+	validators := generatorValidators(4)
+	validators = append(validators, validators[2])
+
+	params := PredeployParams{
+		MinValidatorCount: 1,
+		MaxValidatorCount: 10,
+	}
+
+	if _, err := PredeployStakingSC(validators, params); !errors.Is(err, errDuplicateValidator) {
+		t.Fatalf("expected errDuplicateValidator from the predeploy, got %v", err)
+	}
+
+	generator := &StorageGenerator{
+		Validators: validators,
+		Params:     params,
+	}
+
+	if _, err := generator.Account(); !errors.Is(err, errDuplicateValidator) {
+		t.Fatalf("expected errDuplicateValidator from the generator, got %v", err)
+	}
+
+	if _, err := generator.WriteStorageJSON(io.Discard); !errors.Is(err, errDuplicateValidator) {
+		t.Fatalf("expected errDuplicateValidator from the JSON stream, got %v", err)
+	}
+}
+
+var benchmarkValidatorCounts = []int{100, 1000, 10000, 100000}
+
+func BenchmarkPredeployStakingSC(b *testing.B) {
+	for _, count := range benchmarkValidatorCounts {
+		validators := generatorValidators(count)
+		params := PredeployParams{
+			MinValidatorCount: 1,
+			MaxValidatorCount: uint64(count),
+		}
+
+		b.Run(fmt.Sprintf("%d validators", count), func(b *testing.B) {
+			b.ReportAllocs()
+
+			for i := 0; i < b.N; i++ {
+				if _, err := PredeployStakingSC(validators, params); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkStorageGenerator(b *testing.B) {
+	for _, count := range benchmarkValidatorCounts {
+		generator := &StorageGenerator{
+			Validators: generatorValidators(count),
+			Params: PredeployParams{
+				MinValidatorCount: 1,
+				MaxValidatorCount: uint64(count),
+			},
+		}
+
+		b.Run(fmt.Sprintf("%d validators account", count), func(b *testing.B) {
+			b.ReportAllocs()
+
+			for i := 0; i < b.N; i++ {
+				if _, err := generator.Account(); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+
+		b.Run(fmt.Sprintf("%d validators json", count), func(b *testing.B) {
+			b.ReportAllocs()
+
+			for i := 0; i < b.N; i++ {
+				if _, err := generator.WriteStorageJSON(io.Discard); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,360 @@
+package staking
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+
+	"github.com/0xPolygon/polygon-edge/chain"
+	"github.com/0xPolygon/polygon-edge/types"
+)
+
+var (
+	// DefaultStakingSCAddress is the address the staking SC is deployed to
+	// when the spec doesn't specify one
+	DefaultStakingSCAddress = types.StringToAddress("1001")
+
+	// DefaultNFTSCAddress is the address the mock ERC721 SC is deployed to
+	// when the spec contains NFTs but doesn't specify an address
+	DefaultNFTSCAddress = types.StringToAddress("1002")
+)
+
+var (
+	ErrAllocCollision     = errors.New("address is already present in the genesis alloc")
+	errDuplicateValidator = errors.New("validator is listed more than once")
+	errValidatorCount     = errors.New("validator count is out of the [MinValidatorCount, MaxValidatorCount] range")
+	errStakeUnit          = errors.New("stakes are token counts when tokens are staked, wei amounts can't be set")
+)
+
+// StakingGenesisSpec is a declarative description of the staking setup of a PoS chain
+type StakingGenesisSpec struct {
+	// StakingAddress is the address of the staking SC.
+	// DefaultStakingSCAddress is used if it's not set
+	StakingAddress types.Address
+
+	// Params are the validator count bounds of the staking SC
+	Params PredeployParams
+
+	// Validators are the pre-staked validators, in _validators order
+	Validators []types.Address
+
+	// Stakes are the staked amounts of the validators, in wei.
+	// Validators missing from the map are staked with the DefaultStakedBalance.
+	// Every key has to be a validator.
+	// Stakes are only used without StakedTokens: the staking SC stores a single amount
+	// per staker, which counts the staked tokens once tokens are staked
+	Stakes map[types.Address]*big.Int
+
+	// NFTAddress is the address of the mock ERC721 SC.
+	// DefaultNFTSCAddress is used if it's not set and the spec contains NFTs
+	NFTAddress types.Address
+
+	// StakedTokens maps a validator to the token IDs it has staked.
+	// If it's set, every validator has to stake at least one token,
+	// and the staked amount of a validator is its token count,
+	// which is also the stake the OrderByStake ordering sorts on
+	StakedTokens map[types.Address][]*big.Int
+
+	// NFTHolders maps an account to the token IDs it holds in its own wallet
+	NFTHolders map[types.Address][]*big.Int
+
+	// Balances are the initial native balances of regular accounts
+	Balances map[types.Address]*big.Int
+
+	// Proxy, if set, deploys the staking SC behind an EIP-1967 transparent proxy.
+	// The proxy holds the staking storage at StakingAddress, and delegates to the
+	// staking logic deployed at Proxy.LogicAddress
+	Proxy *ProxyParams
+}
+
+// stakingAddress returns the address of the staking SC
+func (s *StakingGenesisSpec) stakingAddress() types.Address {
+	if s.StakingAddress == types.ZeroAddress {
+		return DefaultStakingSCAddress
+	}
+
+	return s.StakingAddress
+}
+
+// nftAddress returns the address of the mock ERC721 SC
+func (s *StakingGenesisSpec) nftAddress() types.Address {
+	if s.NFTAddress == types.ZeroAddress {
+		return DefaultNFTSCAddress
+	}
+
+	return s.NFTAddress
+}
+
+// hasNFTs checks if the spec requires the mock ERC721 SC to be deployed
+func (s *StakingGenesisSpec) hasNFTs() bool {
+	return s.NFTAddress != types.ZeroAddress || len(s.StakedTokens) > 0 || len(s.NFTHolders) > 0
+}
+
+// tokenStaking checks if the validators stake tokens, in which case
+// the staked amounts are token counts instead of wei amounts
+func (s *StakingGenesisSpec) tokenStaking() bool {
+	return len(s.StakedTokens) > 0
+}
+
+// predeployStakes returns the stakes the staking SC is predeployed with.
+// With staked tokens, every validator starts from zero, and SetStakedNFTs adds its token count
+func (s *StakingGenesisSpec) predeployStakes() map[types.Address]*big.Int {
+	if !s.tokenStaking() {
+		return s.Stakes
+	}
+
+	stakes := make(map[types.Address]*big.Int, len(s.Validators))
+	for _, validator := range s.Validators {
+		stakes[validator] = big.NewInt(0)
+	}
+
+	return stakes
+}
+
+// predeployValidators returns the validators and the params the staking SC is predeployed with.
+// With staked tokens, the predeployed stakes are all zero, so the validators are ordered here
+// on their effective stakes, the token counts, and predeployed in that order
+func (s *StakingGenesisSpec) predeployValidators() ([]types.Address, PredeployParams, error) {
+	if !s.tokenStaking() {
+		return s.Validators, s.Params, nil
+	}
+
+	tokenCounts := make(map[types.Address]*big.Int, len(s.Validators))
+	for _, validator := range s.Validators {
+		tokenCounts[validator] = big.NewInt(int64(len(s.StakedTokens[validator])))
+	}
+
+	validators, err := orderValidators(s.Validators, tokenCounts, big.NewInt(0), s.Params.Ordering)
+	if err != nil {
+		return nil, PredeployParams{}, err
+	}
+
+	params := s.Params
+	params.Ordering = OrderByInput
+
+	return validators, params, nil
+}
+
+// sortedAccounts returns the accounts of the amount map in ascending address order
+func sortedAccounts(amounts map[types.Address]*big.Int) []types.Address {
+	accounts := make([]types.Address, 0, len(amounts))
+	for account := range amounts {
+		accounts = append(accounts, account)
+	}
+
+	sort.Slice(accounts, func(i, j int) bool {
+		return bytes.Compare(accounts[i].Bytes(), accounts[j].Bytes()) < 0
+	})
+
+	return accounts
+}
+
+// validate checks the spec for consistency
+func (s *StakingGenesisSpec) validate() error {
+	seen := make(map[types.Address]struct{}, len(s.Validators))
+
+	for _, validator := range s.Validators {
+		if _, ok := seen[validator]; ok {
+			return fmt.Errorf("%w: %s", errDuplicateValidator, validator)
+		}
+
+		seen[validator] = struct{}{}
+	}
+
+	count := uint64(len(s.Validators))
+	if count < s.Params.MinValidatorCount || count > s.Params.MaxValidatorCount {
+		return fmt.Errorf(
+			"%w: %d validators, bounds [%d, %d]",
+			errValidatorCount,
+			count,
+			s.Params.MinValidatorCount,
+			s.Params.MaxValidatorCount,
+		)
+	}
+
+	// The maps are walked in address order, so the reported error doesn't depend on the map order
+	for _, staker := range sortedTokenOwners(s.StakedTokens) {
+		if _, ok := seen[staker]; !ok {
+			return fmt.Errorf("staker %s is not a validator", staker)
+		}
+	}
+
+	for _, staker := range sortedAccounts(s.Stakes) {
+		stake := s.Stakes[staker]
+
+		if _, ok := seen[staker]; !ok {
+			return fmt.Errorf("stake of %s, which is not a validator", staker)
+		}
+
+		if s.tokenStaking() && stake != nil && stake.Sign() != 0 {
+			return fmt.Errorf("%w, got %s for %s", errStakeUnit, stake, staker)
+		}
+	}
+
+	if s.tokenStaking() {
+		for _, validator := range s.Validators {
+			if len(s.StakedTokens[validator]) == 0 {
+				return fmt.Errorf("validator %s has no staked tokens", validator)
+			}
+		}
+	}
+
+	for _, account := range sortedAccounts(s.Balances) {
+		if balance := s.Balances[account]; balance == nil || balance.Sign() < 0 {
+			return fmt.Errorf("invalid balance for account %s", account)
+		}
+	}
+
+	return nil
+}
+
+// BuildStakingGenesis generates every genesis account described by the spec:
+// the staking SC, the mock ERC721 SC if the spec contains NFTs, and the
+// funded regular accounts.
+// The returned accounts are checked against the existing alloc, and an
+// ErrAllocCollision is returned if any of their addresses is already taken
+func BuildStakingGenesis(
+	spec StakingGenesisSpec,
+	alloc map[types.Address]*chain.GenesisAccount,
+) (map[types.Address]*chain.GenesisAccount, error) {
+	if err := spec.validate(); err != nil {
+		return nil, err
+	}
+
+	accounts := make(map[types.Address]*chain.GenesisAccount)
+	addAccount := func(address types.Address, account *chain.GenesisAccount) error {
+		if _, ok := alloc[address]; ok {
+			return fmt.Errorf("%w: %s", ErrAllocCollision, address)
+		}
+
+		if _, ok := accounts[address]; ok {
+			return fmt.Errorf("%w: %s is used twice by the staking spec", ErrAllocCollision, address)
+		}
+
+		accounts[address] = account
+
+		return nil
+	}
+
+	stakingAddress := spec.stakingAddress()
+
+	validators, params, err := spec.predeployValidators()
+	if err != nil {
+		return nil, err
+	}
+
+	stakingAccount, err := PredeployStakingSCWithStakes(validators, spec.predeployStakes(), params)
+	if err != nil {
+		return nil, err
+	}
+
+	if spec.hasNFTs() {
+		nftAddress := spec.nftAddress()
+
+		nftAccount, err := PredeployERC721(ERC721PredeployParams{
+			StakingAddress: stakingAddress,
+			Holders:        spec.NFTHolders,
+			StakedTokens:   spec.StakedTokens,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		if err := SetStakedNFTs(stakingAccount, nftAddress, spec.StakedTokens); err != nil {
+			return nil, err
+		}
+
+		if err := addAccount(nftAddress, nftAccount); err != nil {
+			return nil, err
+		}
+	}
+
+	if spec.Proxy != nil {
+		logicAddress := spec.Proxy.logicAddress()
+		if logicAddress == stakingAddress {
+			return nil, errProxyLogicAddress
+		}
+
+		logicAccount, err := WrapStakingSCInProxy(stakingAccount, *spec.Proxy)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := addAccount(logicAddress, logicAccount); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := addAccount(stakingAddress, stakingAccount); err != nil {
+		return nil, err
+	}
+
+	for address, balance := range spec.Balances {
+		if err := addAccount(address, &chain.GenesisAccount{
+			Balance: new(big.Int).Set(balance),
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	return accounts, nil
+}
+
+// ApplyStakingGenesis builds the accounts described by the spec and merges
+// them into the genesis alloc. In the proxy mode, the calls routed through
+// the proxy are validated in a local EVM first.
+// The alloc is left untouched on error
+func ApplyStakingGenesis(genesis *chain.Genesis, spec StakingGenesisSpec) error {
+	accounts, err := BuildStakingGenesis(spec, genesis.Alloc)
+	if err != nil {
+		return err
+	}
+
+	if spec.Proxy != nil {
+		if err := validateProxyAccounts(genesis, spec.stakingAddress(), accounts); err != nil {
+			return err
+		}
+	}
+
+	if genesis.Alloc == nil {
+		genesis.Alloc = make(map[types.Address]*chain.GenesisAccount, len(accounts))
+	}
+
+	for address, account := range accounts {
+		genesis.Alloc[address] = account
+	}
+
+	return nil
+}
+
+// validateProxyAccounts validates the proxy predeploy on a copy of the genesis
+// that contains the new accounts
+func validateProxyAccounts(
+	genesis *chain.Genesis,
+	stakingAddress types.Address,
+	accounts map[types.Address]*chain.GenesisAccount,
+) error {
+	genesisCopy := *genesis
+	genesisCopy.Alloc = make(map[types.Address]*chain.GenesisAccount, len(genesis.Alloc)+len(accounts))
+
+	for address, account := range genesis.Alloc {
+		genesisCopy.Alloc[address] = account
+	}
+
+	for address, account := range accounts {
+		genesisCopy.Alloc[address] = account
+	}
+
+	expected, err := NewStakingView(NewGenesisAccountReader(accounts[stakingAddress])).Validators()
+	if err != nil {
+		return err
+	}
+
+	if err := ValidateProxyPredeploy(&genesisCopy, stakingAddress, expected); err != nil {
+		return fmt.Errorf("invalid staking proxy predeploy, %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,215 @@
+package staking
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/0xPolygon/polygon-edge/types"
+)
+
+func TestBuildStakingGenesisStakes(t *testing.T) {
+	var (
+		validatorA = types.StringToAddress("0x1")
+		validatorB = types.StringToAddress("0x2")
+		outsider   = types.StringToAddress("0x3")
+	)
+
+	params := PredeployParams{
+		MinValidatorCount: 1,
+		MaxValidatorCount: 2,
+	}
+
+	cases := []struct {
+		name string
+		spec StakingGenesisSpec
+		err  string
+	}{
+		{
+			name: "stake of a non-validator",
+			spec: StakingGenesisSpec{
+				Params:     params,
+				Validators: []types.Address{validatorA, validatorB},
+				Stakes: map[types.Address]*big.Int{
+					validatorA: big.NewInt(10),
+					outsider:   big.NewInt(10),
+				},
+			},
+			err: "stake of " + outsider.String() + ", which is not a validator",
+		},
+		{
+			name: "wei stake with staked tokens",
+			spec: StakingGenesisSpec{
+				Params:     params,
+				Validators: []types.Address{validatorA, validatorB},
+				Stakes: map[types.Address]*big.Int{
+					validatorA: big.NewInt(10),
+				},
+				StakedTokens: map[types.Address][]*big.Int{
+					validatorA: {big.NewInt(1)},
+					validatorB: {big.NewInt(2)},
+				},
+			},
+			err: errStakeUnit.Error(),
+		},
+		{
+			name: "validator without staked tokens",
+			spec: StakingGenesisSpec{
+				Params:     params,
+				Validators: []types.Address{validatorA, validatorB},
+				StakedTokens: map[types.Address][]*big.Int{
+					validatorA: {big.NewInt(1)},
+				},
+			},
+			err: "validator " + validatorB.String() + " has no staked tokens",
+		},
+	}
+
+	for _, c := range cases {
+		c := c
+
+		t.Run(c.name, func(t *testing.T) {
+			_, err := BuildStakingGenesis(c.spec, nil)
+			if err == nil || !strings.Contains(err.Error(), c.err) {
+				t.Fatalf("expected an error containing %q, got %v", c.err, err)
+			}
+		})
+	}
+}
+
+func TestBuildStakingGenesisTokenStakes(t *testing.T) {
+	var (
+		validatorA = types.StringToAddress("0x1")
+		validatorB = types.StringToAddress("0x2")
+	)
+
+	spec := StakingGenesisSpec{
+		Params: PredeployParams{
+			MinValidatorCount: 1,
+			MaxValidatorCount: 2,
+		},
+		Validators: []types.Address{validatorA, validatorB},
+		Stakes: map[types.Address]*big.Int{
+			validatorA: big.NewInt(0),
+		},
+		StakedTokens: map[types.Address][]*big.Int{
+			validatorA: {big.NewInt(1), big.NewInt(2)},
+			validatorB: {big.NewInt(3)},
+		},
+	}
+
+	accounts, err := BuildStakingGenesis(spec, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	view := NewStakingView(NewGenesisAccountReader(accounts[DefaultStakingSCAddress]))
+
+	// The stakes are the token counts, the default wei stake isn't added
+	for validator, want := range map[types.Address]int64{validatorA: 2, validatorB: 1} {
+		stake, err := view.StakeOf(validator)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if stake.Cmp(big.NewInt(want)) != 0 {
+			t.Errorf("expected stake %d for %s, got %s", want, validator, stake)
+		}
+	}
+
+	total, err := view.TotalStaked()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if total.Cmp(big.NewInt(3)) != 0 {
+		t.Errorf("expected total staked amount 3, got %s", total)
+	}
+}
+
+func TestBuildStakingGenesisAllocCollision(t *testing.T) {
+	spec := StakingGenesisSpec{
+		Params: PredeployParams{
+			MinValidatorCount: 1,
+			MaxValidatorCount: 1,
+		},
+		Validators: []types.Address{types.StringToAddress("0x1")},
+		Balances: map[types.Address]*big.Int{
+			DefaultStakingSCAddress: big.NewInt(1),
+		},
+	}
+
+	if _, err := BuildStakingGenesis(spec, nil); !errors.Is(err, ErrAllocCollision) {
+		t.Fatalf("expected ErrAllocCollision, got %v", err)
+	}
+}
+
+func TestBuildStakingGenesisTokenStakeOrdering(t *testing.T) {
+	var (
+		validatorA = types.StringToAddress("0x1")
+		validatorB = types.StringToAddress("0x2")
+		validatorC = types.StringToAddress("0x3")
+	)
+
+	spec := StakingGenesisSpec{
+		Params: PredeployParams{
+			MinValidatorCount: 1,
+			MaxValidatorCount: 3,
+			Ordering:          OrderByStake,
+		},
+		Validators: []types.Address{validatorA, validatorB, validatorC},
+		StakedTokens: map[types.Address][]*big.Int{
+			validatorA: {big.NewInt(1)},
+			validatorB: {big.NewInt(2), big.NewInt(3), big.NewInt(4)},
+			validatorC: {big.NewInt(5), big.NewInt(6)},
+		},
+	}
+
+	accounts, err := BuildStakingGenesis(spec, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	validators, err := NewStakingView(NewGenesisAccountReader(accounts[DefaultStakingSCAddress])).Validators()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The validators are ordered by token count, not by their zero predeployed stakes
+	if want := []types.Address{validatorB, validatorC, validatorA}; !reflect.DeepEqual(validators, want) {
+		t.Fatalf("expected %v, got %v", want, validators)
+	}
+}
+
+func TestBuildStakingGenesisDeterministicErrors(t *testing.T) {
+	validator := types.StringToAddress("0x1")
+
+	stakes := map[types.Address]*big.Int{
+		validator: big.NewInt(10),
+	}
+
+	for i := 2; i < 10; i++ {
+		stakes[types.StringToAddress(fmt.Sprintf("0x%d", i))] = big.NewInt(10)
+	}
+
+	spec := StakingGenesisSpec{
+		Params: PredeployParams{
+			MinValidatorCount: 1,
+			MaxValidatorCount: 1,
+		},
+		Validators: []types.Address{validator},
+		Stakes:     stakes,
+	}
+
+	// The lowest non-validator address is always reported, whatever the map order
+	want := "stake of " + types.StringToAddress("0x2").String() + ", which is not a validator"
+
+	for i := 0; i < 10; i++ {
+		if _, err := BuildStakingGenesis(spec, nil); err == nil || err.Error() != want {
+			t.Fatalf("expected %q, got %v", want, err)
+		}
+	}
+}
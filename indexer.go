@@ -0,0 +1,513 @@
+package staking
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/0xPolygon/polygon-edge/types"
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+const (
+	// DefaultIndexerBlockRange is the default number of blocks indexed in a single step
+	DefaultIndexerBlockRange = uint64(1000)
+
+	// DefaultIndexerPollInterval is the default interval between two steps once the indexer caught up
+	DefaultIndexerPollInterval = 2 * time.Second
+)
+
+// Key prefixes of the indexer database
+var (
+	indexerCheckpointKey    = []byte("checkpoint")
+	indexerBlockHashPrefix  = []byte("h") // h + block number => block hash
+	indexerEventPrefix      = []byte("e") // e + block number + log index => JSON encoded StakingEvent
+	indexerValidatorsPrefix = []byte("v") // v + block number => encoded ValidatorSet
+)
+
+var (
+	// ErrBlockNotIndexed is returned for queries of blocks the indexer hasn't processed
+	ErrBlockNotIndexed = errors.New("block is not indexed")
+
+	// ErrRangeReorganized is returned by Step when the chain reorganized while a block range
+	// was read. Nothing is written, and the range is read again by the next step
+	ErrRangeReorganized = errors.New("block range reorganized while being indexed")
+
+	errReorgTooDeep = errors.New("reorg is deeper than the indexed block hashes")
+)
+
+// indexerKey returns the database key of the prefix followed by the big-endian numbers
+func indexerKey(prefix []byte, numbers ...uint64) []byte {
+	key := make([]byte, len(prefix)+8*len(numbers))
+	copy(key, prefix)
+
+	for n, number := range numbers {
+		binary.BigEndian.PutUint64(key[len(prefix)+8*n:], number)
+	}
+
+	return key
+}
+
+// IndexerConfig is the configuration of the StakingIndexer
+type IndexerConfig struct {
+	// Endpoint is the JSON-RPC endpoint of the node. The validator sets are read
+	// with eth_call at past blocks, so the node has to keep the historical state
+	Endpoint string
+
+	// StakingAddress is the address of the staking SC
+	StakingAddress types.Address
+
+	// StartBlock is the first indexed block, used when the database is empty
+	StartBlock uint64
+
+	// BlockRange is the number of blocks indexed in a single step.
+	// DefaultIndexerBlockRange is used if it's not set
+	BlockRange uint64
+
+	// PollInterval is the interval between two steps once the indexer caught up.
+	// DefaultIndexerPollInterval is used if it's not set
+	PollInterval time.Duration
+
+	// HTTPClient is the client used for the JSON-RPC requests.
+	// A default one with a timeout is used if it's not set
+	HTTPClient *http.Client
+
+	// MaxRetryBackoff is the upper bound of the exponentially growing delay between
+	// the retries of a failed step. DefaultMaxRetryBackoff is used if it's not set
+	MaxRetryBackoff time.Duration
+
+	// MaxRetries is the number of consecutive failed steps after which Run returns the error.
+	// Failed steps are retried until the context is done if it's not set
+	MaxRetries int
+}
+
+// IndexerCheckpoint is the last block processed by the indexer
+type IndexerCheckpoint struct {
+	Number uint64
+	Hash   types.Hash
+}
+
+// StakingIndexer persists the Staked and Unstaked events of the staking SC,
+// along with the validator set after every block containing such events.
+// The hashes of the processed blocks are kept, so the indexed data is rolled back
+// when the chain reorganizes. Indexing resumes from the last checkpoint after a restart
+type StakingIndexer struct {
+	config    IndexerConfig
+	db        *leveldb.DB
+	transport *jsonRPCTransport
+	client    *StakingClient
+
+	// lock serializes the indexing steps, the database itself is safe for concurrent use
+	lock sync.Mutex
+}
+
+// OpenStakingIndexer opens (or creates) the indexer database at the given path
+func OpenStakingIndexer(path string, config IndexerConfig) (*StakingIndexer, error) {
+	if config.BlockRange == 0 {
+		config.BlockRange = DefaultIndexerBlockRange
+	}
+
+	if config.PollInterval <= 0 {
+		config.PollInterval = DefaultIndexerPollInterval
+	}
+
+	db, err := leveldb.OpenFile(path, nil)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open the indexer database, %w", err)
+	}
+
+	transport := newJSONRPCTransport(config.Endpoint, config.HTTPClient)
+
+	return &StakingIndexer{
+		config:    config,
+		db:        db,
+		transport: transport,
+		client: &StakingClient{
+			transport: transport,
+			address:   config.StakingAddress,
+		},
+	}, nil
+}
+
+// Close closes the indexer database
+func (i *StakingIndexer) Close() error {
+	return i.db.Close()
+}
+
+// Checkpoint returns the last indexed block, or nil if nothing is indexed yet
+func (i *StakingIndexer) Checkpoint() (*IndexerCheckpoint, error) {
+	value, err := i.db.Get(indexerCheckpointKey, nil)
+	if errors.Is(err, leveldb.ErrNotFound) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	if len(value) != 8+types.HashLength {
+		return nil, fmt.Errorf("invalid indexer checkpoint")
+	}
+
+	return &IndexerCheckpoint{
+		Number: binary.BigEndian.Uint64(value[:8]),
+		Hash:   types.BytesToHash(value[8:]),
+	}, nil
+}
+
+// Run indexes the chain until the context is done.
+// Failed steps are retried with an exponential backoff, up to MaxRetries times in a row,
+// except for a reorg deeper than the indexed block hashes
+func (i *StakingIndexer) Run(ctx context.Context) error {
+	backoff := newRetryBackoff(i.config.MaxRetryBackoff)
+	failures := 0
+
+	for {
+		caughtUp, err := i.Step(ctx)
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		if err != nil {
+			if errors.Is(err, errReorgTooDeep) {
+				return err
+			}
+
+			if failures++; i.config.MaxRetries > 0 && failures > i.config.MaxRetries {
+				return err
+			}
+
+			if !backoff.wait(ctx) {
+				return nil
+			}
+
+			continue
+		}
+
+		failures = 0
+		backoff.reset()
+
+		if !caughtUp {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(i.config.PollInterval):
+		}
+	}
+}
+
+// Step indexes the next range of blocks, after rolling back any reorganized blocks.
+// It returns true once the indexer reached the latest block
+func (i *StakingIndexer) Step(ctx context.Context) (bool, error) {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	checkpoint, err := i.Checkpoint()
+	if err != nil {
+		return false, err
+	}
+
+	if checkpoint == nil {
+		return false, i.indexStartBlock(ctx)
+	}
+
+	if checkpoint, err = i.handleReorg(ctx, checkpoint); err != nil {
+		return false, err
+	}
+
+	head, err := i.transport.getBlockNumber(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	if head <= checkpoint.Number {
+		return true, nil
+	}
+
+	from := checkpoint.Number + 1
+	to := head
+
+	if to-from >= i.config.BlockRange {
+		to = from + i.config.BlockRange - 1
+	}
+
+	if err := i.indexRange(ctx, from, to); err != nil {
+		return false, err
+	}
+
+	return to == head, nil
+}
+
+// indexStartBlock stores the validator set at the start block, the base of every later set
+func (i *StakingIndexer) indexStartBlock(ctx context.Context) error {
+	header, err := i.transport.getBlockHeader(ctx, i.config.StartBlock)
+	if err != nil {
+		return err
+	}
+
+	if header == nil {
+		return fmt.Errorf("start block %d doesn't exist", i.config.StartBlock)
+	}
+
+	set, err := i.client.At(i.config.StartBlock).ValidatorSet(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to read the validator set at block %d, %w", i.config.StartBlock, err)
+	}
+
+	batch := new(leveldb.Batch)
+	batch.Put(indexerKey(indexerValidatorsPrefix, i.config.StartBlock), set.Encode())
+	i.putBlock(batch, i.config.StartBlock, header.Hash)
+	i.putCheckpoint(batch, i.config.StartBlock, header.Hash)
+
+	return i.db.Write(batch, nil)
+}
+
+// indexRange indexes the events of the block range, both ends included.
+// Everything is written in a single batch, together with the new checkpoint.
+// The logs, headers and validator sets are separate requests by block number,
+// so ErrRangeReorganized is returned if they don't all belong to the same chain
+func (i *StakingIndexer) indexRange(ctx context.Context, from, to uint64) error {
+	// The hash of the last block commits to the whole range. It's read before
+	// and after everything else, so an unchanged hash means a single chain was read
+	header, err := i.transport.requireBlockHeader(ctx, to)
+	if err != nil {
+		return err
+	}
+
+	logs, err := i.transport.getStakingLogs(ctx, i.config.StakingAddress, from, to)
+	if err != nil {
+		return err
+	}
+
+	eventsByBlock := make(map[uint64][]*StakingEvent)
+	hashes := make(map[uint64]types.Hash)
+
+	for _, log := range logs {
+		if log.Removed {
+			continue
+		}
+
+		event, err := decodeStakingLog(log)
+		if err != nil {
+			return err
+		}
+
+		if hash, ok := hashes[event.BlockNumber]; ok && hash != event.BlockHash {
+			return fmt.Errorf("%w, logs of block %d have different hashes", ErrRangeReorganized, event.BlockNumber)
+		}
+
+		eventsByBlock[event.BlockNumber] = append(eventsByBlock[event.BlockNumber], event)
+		hashes[event.BlockNumber] = event.BlockHash
+	}
+
+	blocks := make([]uint64, 0, len(eventsByBlock))
+	for block := range eventsByBlock {
+		blocks = append(blocks, block)
+	}
+
+	sort.Slice(blocks, func(a, b int) bool {
+		return blocks[a] < blocks[b]
+	})
+
+	// Every log has to come from the block of its number in the chain of the last block
+	headers, err := i.transport.getBlockHeaders(ctx, blocks)
+	if err != nil {
+		return err
+	}
+
+	for n, block := range blocks {
+		if headers[n] == nil || headers[n].Hash != hashes[block] {
+			return fmt.Errorf("%w, the logs of block %d aren't canonical", ErrRangeReorganized, block)
+		}
+	}
+
+	batch := new(leveldb.Batch)
+
+	for _, block := range blocks {
+		for _, event := range eventsByBlock[block] {
+			encoded, err := json.Marshal(event)
+			if err != nil {
+				return err
+			}
+
+			batch.Put(indexerKey(indexerEventPrefix, block, event.LogIndex), encoded)
+		}
+
+		set, err := i.client.At(block).ValidatorSet(ctx)
+		if err != nil {
+			return fmt.Errorf("unable to read the validator set at block %d, %w", block, err)
+		}
+
+		batch.Put(indexerKey(indexerValidatorsPrefix, block), set.Encode())
+		i.putBlock(batch, block, hashes[block])
+	}
+
+	last, err := i.transport.getBlockHeader(ctx, to)
+	if err != nil {
+		return err
+	}
+
+	if last == nil || last.Hash != header.Hash {
+		return fmt.Errorf("%w, block %d changed", ErrRangeReorganized, to)
+	}
+
+	i.putBlock(batch, to, header.Hash)
+	i.putCheckpoint(batch, to, header.Hash)
+
+	return i.db.Write(batch, nil)
+}
+
+func (i *StakingIndexer) putBlock(batch *leveldb.Batch, number uint64, hash types.Hash) {
+	batch.Put(indexerKey(indexerBlockHashPrefix, number), hash.Bytes())
+}
+
+func (i *StakingIndexer) putCheckpoint(batch *leveldb.Batch, number uint64, hash types.Hash) {
+	batch.Put(indexerCheckpointKey, append(indexerKey(nil, number), hash.Bytes()...))
+}
+
+// handleReorg checks that the checkpoint is still part of the canonical chain.
+// If it isn't, the indexed data is rolled back to the latest stored block
+// that is still canonical, which becomes the new checkpoint
+func (i *StakingIndexer) handleReorg(ctx context.Context, checkpoint *IndexerCheckpoint) (*IndexerCheckpoint, error) {
+	header, err := i.transport.getBlockHeader(ctx, checkpoint.Number)
+	if err != nil {
+		return nil, err
+	}
+
+	if header != nil && header.Hash == checkpoint.Hash {
+		return checkpoint, nil
+	}
+
+	iter := i.db.NewIterator(util.BytesPrefix(indexerBlockHashPrefix), nil)
+	defer iter.Release()
+
+	// Stored block hashes are walked from the newest to the oldest
+	for ok := iter.Last(); ok; ok = iter.Prev() {
+		number := binary.BigEndian.Uint64(iter.Key()[len(indexerBlockHashPrefix):])
+		hash := types.BytesToHash(iter.Value())
+
+		if number >= checkpoint.Number {
+			continue
+		}
+
+		header, err := i.transport.getBlockHeader(ctx, number)
+		if err != nil {
+			return nil, err
+		}
+
+		if header != nil && header.Hash == hash {
+			ancestor := &IndexerCheckpoint{
+				Number: number,
+				Hash:   hash,
+			}
+
+			return ancestor, i.rollback(ancestor)
+		}
+	}
+
+	if err := iter.Error(); err != nil {
+		return nil, err
+	}
+
+	return nil, fmt.Errorf("%w, block %d isn't canonical anymore", errReorgTooDeep, checkpoint.Number)
+}
+
+// rollback deletes everything indexed after the checkpoint, and makes it the latest one
+func (i *StakingIndexer) rollback(checkpoint *IndexerCheckpoint) error {
+	batch := new(leveldb.Batch)
+
+	for _, prefix := range [][]byte{indexerBlockHashPrefix, indexerEventPrefix, indexerValidatorsPrefix} {
+		iter := i.db.NewIterator(util.BytesPrefix(prefix), nil)
+
+		for ok := iter.Seek(indexerKey(prefix, checkpoint.Number+1)); ok; ok = iter.Next() {
+			batch.Delete(append([]byte(nil), iter.Key()...))
+		}
+
+		iter.Release()
+
+		if err := iter.Error(); err != nil {
+			return err
+		}
+	}
+
+	i.putCheckpoint(batch, checkpoint.Number, checkpoint.Hash)
+
+	return i.db.Write(batch, nil)
+}
+
+// checkIndexed returns ErrBlockNotIndexed if the block is outside of the indexed range
+func (i *StakingIndexer) checkIndexed(block uint64) error {
+	checkpoint, err := i.Checkpoint()
+	if err != nil {
+		return err
+	}
+
+	if checkpoint == nil || block < i.config.StartBlock || block > checkpoint.Number {
+		return fmt.Errorf("%w: %d", ErrBlockNotIndexed, block)
+	}
+
+	return nil
+}
+
+// ValidatorSetAt returns the validator set at the end of the given block
+func (i *StakingIndexer) ValidatorSetAt(block uint64) (*ValidatorSet, error) {
+	if err := i.checkIndexed(block); err != nil {
+		return nil, err
+	}
+
+	iter := i.db.NewIterator(util.BytesPrefix(indexerValidatorsPrefix), nil)
+	defer iter.Release()
+
+	// The set at the block is the last one stored at or before it
+	var ok bool
+	if block == ^uint64(0) || !iter.Seek(indexerKey(indexerValidatorsPrefix, block+1)) {
+		ok = iter.Last()
+	} else {
+		ok = iter.Prev()
+	}
+
+	if !ok {
+		if err := iter.Error(); err != nil {
+			return nil, err
+		}
+
+		return nil, fmt.Errorf("%w: %d", ErrBlockNotIndexed, block)
+	}
+
+	return DecodeValidatorSet(iter.Value())
+}
+
+// Events returns the indexed staking events of the block range, both ends included,
+// ordered by block number and log index
+func (i *StakingIndexer) Events(from, to uint64) ([]*StakingEvent, error) {
+	events := make([]*StakingEvent, 0)
+
+	if from > to {
+		return events, nil
+	}
+
+	iter := i.db.NewIterator(&util.Range{
+		Start: indexerKey(indexerEventPrefix, from),
+		Limit: indexerKey(indexerEventPrefix, to, ^uint64(0)),
+	}, nil)
+	defer iter.Release()
+
+	for iter.Next() {
+		event := new(StakingEvent)
+		if err := json.Unmarshal(iter.Value(), event); err != nil {
+			return nil, fmt.Errorf("invalid indexed event, %w", err)
+		}
+
+		events = append(events, event)
+	}
+
+	return events, iter.Error()
+}
@@ -0,0 +1,149 @@
+package staking
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/0xPolygon/polygon-edge/types"
+)
+
+func openTestIndexer(t *testing.T, config IndexerConfig) *StakingIndexer {
+	t.Helper()
+
+	indexer, err := OpenStakingIndexer(t.TempDir(), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		indexer.Close()
+	})
+
+	return indexer
+}
+
+func TestStakingIndexerStaleLogs(t *testing.T) {
+	var (
+		validatorA = types.StringToAddress("0x1")
+		validatorB = types.StringToAddress("0x2")
+	)
+
+	chain, endpoint := newStakingTestChain(t, 10, map[uint64]map[types.Address]int64{
+		0: {validatorA: 1},
+		6: {validatorA: 1, validatorB: 2},
+	})
+
+	indexer := openTestIndexer(t, IndexerConfig{
+		Endpoint:       endpoint,
+		StakingAddress: DefaultStakingSCAddress,
+	})
+
+	ctx := context.Background()
+
+	if _, err := indexer.Step(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// The node answers the logs from a stale branch, so the range isn't written
+	chain.update(func(c *stakingTestChain) {
+		c.staleLogs = 1
+	})
+
+	if _, err := indexer.Step(ctx); !errors.Is(err, ErrRangeReorganized) {
+		t.Fatalf("expected ErrRangeReorganized, got %v", err)
+	}
+
+	checkpoint, err := indexer.Checkpoint()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if checkpoint.Number != 0 {
+		t.Fatalf("expected the checkpoint to stay at block 0, got %d", checkpoint.Number)
+	}
+
+	caughtUp, err := indexer.Step(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !caughtUp {
+		t.Fatal("expected the indexer to catch up")
+	}
+
+	events, err := indexer.Events(0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(events) != 1 || events[0].BlockNumber != 6 || events[0].BlockHash != chain.hash(6) {
+		t.Fatalf("expected a single event of the canonical block 6, got %v", events)
+	}
+}
+
+func TestStakingIndexerRunRetries(t *testing.T) {
+	chain, endpoint := newStakingTestChain(t, 20, map[uint64]map[types.Address]int64{
+		0:  {types.StringToAddress("0x1"): 1},
+		12: {types.StringToAddress("0x2"): 1},
+	})
+
+	// Both kinds of failures are retried by Run
+	chain.failures = 3
+	chain.staleLogs = 2
+
+	indexer := openTestIndexer(t, IndexerConfig{
+		Endpoint:        endpoint,
+		StakingAddress:  DefaultStakingSCAddress,
+		BlockRange:      5,
+		PollInterval:    time.Millisecond,
+		MaxRetryBackoff: time.Millisecond,
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+
+	go func() {
+		done <- indexer.Run(ctx)
+	}()
+
+	waitFor(t, func() bool {
+		checkpoint, err := indexer.Checkpoint()
+
+		return err == nil && checkpoint != nil && checkpoint.Number == 20
+	})
+
+	cancel()
+
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	set, err := indexer.ValidatorSetAt(20)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(set.Validators) != 1 || set.Validators[0].Address != types.StringToAddress("0x2") {
+		t.Fatalf("expected the validator set changed at block 12, got %v", set.Validators)
+	}
+}
+
+func TestStakingIndexerMaxRetries(t *testing.T) {
+	chain, endpoint := newStakingTestChain(t, 1, map[uint64]map[types.Address]int64{
+		0: {types.StringToAddress("0x1"): 1},
+	})
+	chain.failures = 10
+
+	indexer := openTestIndexer(t, IndexerConfig{
+		Endpoint:        endpoint,
+		StakingAddress:  DefaultStakingSCAddress,
+		MaxRetryBackoff: time.Millisecond,
+		MaxRetries:      2,
+	})
+
+	if err := indexer.Run(context.Background()); err == nil {
+		t.Fatal("expected the error of the last retry")
+	}
+}
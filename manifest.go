@@ -0,0 +1,523 @@
+package staking
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/0xPolygon/polygon-edge/chain"
+	"github.com/0xPolygon/polygon-edge/helper/hex"
+	"github.com/0xPolygon/polygon-edge/types"
+	"gopkg.in/yaml.v3"
+)
+
+// ManifestFormat is the encoding of a validator manifest file
+type ManifestFormat string
+
+const (
+	ManifestJSON ManifestFormat = "json"
+	ManifestYAML ManifestFormat = "yaml"
+)
+
+var (
+	errEmptyManifest         = errors.New("manifest is empty")
+	errUnknownManifestFormat = errors.New("unknown manifest format")
+)
+
+// ManifestError is a validator manifest error tied to a line of the manifest file
+type ManifestError struct {
+	Line int
+	Err  error
+}
+
+func (e *ManifestError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+func (e *ManifestError) Unwrap() error {
+	return e.Err
+}
+
+// ValidatorManifest is the declarative list of pre-staked validators,
+// together with the staking SC settings
+type ValidatorManifest struct {
+	StakingAddress types.Address
+	NFTAddress     types.Address
+	Params         PredeployParams
+	Validators     []*ManifestValidator
+}
+
+// ManifestValidator is a single validator entry of the manifest
+type ManifestValidator struct {
+	Label        string
+	Address      types.Address
+	Stake        *big.Int   // nil if the default stake is used
+	NFTs         []*big.Int // staked token IDs
+	BLSPublicKey []byte     // nil if not set, encoded in the IBFT extra data by IBFTExtra
+
+	// Line is the manifest line the entry starts on
+	Line int
+}
+
+// manifestScalar is a scalar manifest value that remembers its position
+type manifestScalar struct {
+	value string
+	line  int
+}
+
+// UnmarshalYAML implements the yaml.Unmarshaler interface
+func (s *manifestScalar) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind != yaml.ScalarNode {
+		return &ManifestError{Line: node.Line, Err: errors.New("expected a scalar value")}
+	}
+
+	s.value = node.Value
+	s.line = node.Line
+
+	return nil
+}
+
+func (s *manifestScalar) errorf(format string, args ...interface{}) error {
+	return &ManifestError{Line: s.line, Err: fmt.Errorf(format, args...)}
+}
+
+func (s *manifestScalar) address() (types.Address, error) {
+	raw, err := hex.DecodeHex(s.value)
+	if err != nil || len(raw) != types.AddressLength {
+		return types.ZeroAddress, s.errorf("invalid address %q", s.value)
+	}
+
+	return types.BytesToAddress(raw), nil
+}
+
+func (s *manifestScalar) uint64() (uint64, error) {
+	value, err := types.ParseUint64orHex(&s.value)
+	if err != nil {
+		return 0, s.errorf("invalid unsigned integer %q", s.value)
+	}
+
+	return value, nil
+}
+
+func (s *manifestScalar) uint256() (*big.Int, error) {
+	value, err := types.ParseUint256orHex(&s.value)
+	if err != nil || value.Sign() < 0 {
+		return nil, s.errorf("invalid unsigned integer %q", s.value)
+	}
+
+	return value, nil
+}
+
+// rawManifest is the on-disk layout of the manifest
+type rawManifest struct {
+	StakingAddress *manifestScalar `yaml:"stakingAddress"`
+	NFTAddress     *manifestScalar `yaml:"nftAddress"`
+	Params         struct {
+		MinValidatorCount *manifestScalar `yaml:"minValidatorCount"`
+		MaxValidatorCount *manifestScalar `yaml:"maxValidatorCount"`
+		Ordering          *manifestScalar `yaml:"ordering"`
+		StorageMode       *manifestScalar `yaml:"storageMode"`
+	} `yaml:"params"`
+	Validators []rawManifestValidator `yaml:"validators"`
+}
+
+type rawManifestValidator struct {
+	Label        *manifestScalar  `yaml:"label"`
+	Address      *manifestScalar  `yaml:"address"`
+	Stake        *manifestScalar  `yaml:"stake"`
+	NFTs         []manifestScalar `yaml:"nfts"`
+	BLSPublicKey *manifestScalar  `yaml:"blsPublicKey"`
+
+	// line is the manifest line the entry starts on
+	line int
+}
+
+// rawManifestValidatorFields are the keys of a validator entry
+var rawManifestValidatorFields = map[string]bool{
+	"label":        true,
+	"address":      true,
+	"stake":        true,
+	"nfts":         true,
+	"blsPublicKey": true,
+}
+
+// UnmarshalYAML implements the yaml.Unmarshaler interface.
+// It keeps the line of the entry, and rejects unknown fields itself,
+// as decoding the node doesn't inherit the strictness of the manifest decoder
+func (v *rawManifestValidator) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind != yaml.MappingNode {
+		return &ManifestError{Line: node.Line, Err: errors.New("expected a validator entry")}
+	}
+
+	for i := 0; i < len(node.Content); i += 2 {
+		if key := node.Content[i]; !rawManifestValidatorFields[key.Value] {
+			return &ManifestError{Line: key.Line, Err: fmt.Errorf("unknown validator field %q", key.Value)}
+		}
+	}
+
+	type plainValidator rawManifestValidator
+
+	if err := node.Decode((*plainValidator)(v)); err != nil {
+		return err
+	}
+
+	v.line = node.Line
+
+	return nil
+}
+
+// LoadValidatorManifest reads and parses the manifest file at the given path.
+// The format is picked from the file extension
+func LoadValidatorManifest(path string) (*ValidatorManifest, error) {
+	var format ManifestFormat
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		format = ManifestJSON
+	case ".yaml", ".yml":
+		format = ManifestYAML
+	default:
+		return nil, fmt.Errorf("%w: %s", errUnknownManifestFormat, path)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read manifest, %w", err)
+	}
+
+	manifest, err := ParseValidatorManifest(data, format)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return manifest, nil
+}
+
+// ParseValidatorManifest strictly parses a validator manifest.
+// Unknown fields, duplicate keys and invalid values are rejected,
+// and the returned errors carry the offending line
+func ParseValidatorManifest(data []byte, format ManifestFormat) (*ValidatorManifest, error) {
+	switch format {
+	case ManifestJSON:
+		if err := checkJSONSyntax(data); err != nil {
+			return nil, err
+		}
+
+		// JSON whitespace is space, tab, LF and CR. YAML accepts all of them
+		// except tabs used for indentation, so the tabs are replaced with spaces.
+		// Valid JSON can't have a raw tab inside a string, so only whitespace
+		// is replaced, and the line numbers stay the same
+		data = bytes.ReplaceAll(data, []byte{'\t'}, []byte{' '})
+	case ManifestYAML:
+	default:
+		return nil, fmt.Errorf("%w: %s", errUnknownManifestFormat, format)
+	}
+
+	// JSON is a subset of YAML, so both formats share the same decoder
+	// and positional error reporting
+	var raw rawManifest
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+
+	if err := decoder.Decode(&raw); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, errEmptyManifest
+		}
+
+		var typeErr *yaml.TypeError
+		if errors.As(err, &typeErr) {
+			return nil, manifestTypeError(typeErr)
+		}
+
+		return nil, err
+	}
+
+	return raw.toManifest()
+}
+
+// manifestTypeError turns the first error of a yaml type error into a ManifestError.
+// yaml only reports the line of a type error in its message, as "line N: ..."
+func manifestTypeError(err *yaml.TypeError) error {
+	if len(err.Errors) == 0 {
+		return err
+	}
+
+	var line int
+	if _, scanErr := fmt.Sscanf(err.Errors[0], "line %d:", &line); scanErr != nil {
+		return err
+	}
+
+	message := err.Errors[0][strings.Index(err.Errors[0], ":")+1:]
+
+	return &ManifestError{Line: line, Err: errors.New(strings.TrimSpace(message))}
+}
+
+// checkJSONSyntax makes sure the data is valid JSON, and reports the line of the syntax error
+func checkJSONSyntax(data []byte) error {
+	var value interface{}
+
+	err := json.Unmarshal(data, &value)
+	if err == nil {
+		return nil
+	}
+
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		line := 1 + bytes.Count(data[:syntaxErr.Offset], []byte{'\n'})
+
+		return &ManifestError{Line: line, Err: err}
+	}
+
+	return err
+}
+
+// toManifest validates the raw manifest values
+func (r *rawManifest) toManifest() (*ValidatorManifest, error) {
+	var err error
+
+	manifest := &ValidatorManifest{
+		StakingAddress: DefaultStakingSCAddress,
+		Params: PredeployParams{
+			MinValidatorCount: MinValidatorCount,
+			MaxValidatorCount: MaxValidatorCount,
+		},
+		Validators: make([]*ManifestValidator, 0, len(r.Validators)),
+	}
+
+	if r.StakingAddress != nil {
+		if manifest.StakingAddress, err = r.StakingAddress.address(); err != nil {
+			return nil, err
+		}
+	}
+
+	if r.NFTAddress != nil {
+		if manifest.NFTAddress, err = r.NFTAddress.address(); err != nil {
+			return nil, err
+		}
+	}
+
+	if r.Params.MinValidatorCount != nil {
+		if manifest.Params.MinValidatorCount, err = r.Params.MinValidatorCount.uint64(); err != nil {
+			return nil, err
+		}
+	}
+
+	if r.Params.MaxValidatorCount != nil {
+		if manifest.Params.MaxValidatorCount, err = r.Params.MaxValidatorCount.uint64(); err != nil {
+			return nil, err
+		}
+
+		if manifest.Params.MaxValidatorCount < manifest.Params.MinValidatorCount {
+			return nil, r.Params.MaxValidatorCount.errorf(
+				"maxValidatorCount %d is lower than minValidatorCount %d",
+				manifest.Params.MaxValidatorCount,
+				manifest.Params.MinValidatorCount,
+			)
+		}
+	}
+
+	if r.Params.Ordering != nil {
+		if manifest.Params.Ordering, err = ParseValidatorOrdering(r.Params.Ordering.value); err != nil {
+			return nil, &ManifestError{Line: r.Params.Ordering.line, Err: err}
+		}
+	}
+
+	if r.Params.StorageMode != nil {
+		if manifest.Params.StorageMode, err = ParseStorageMode(r.Params.StorageMode.value); err != nil {
+			return nil, &ManifestError{Line: r.Params.StorageMode.line, Err: err}
+		}
+	}
+
+	addresses := make(map[types.Address]int)
+	labels := make(map[string]int)
+	tokens := make(map[string]int)
+
+	for _, rawValidator := range r.Validators {
+		if rawValidator.Address == nil {
+			return nil, &ManifestError{Line: rawValidator.line, Err: errors.New("validator entry without an address")}
+		}
+
+		validator := &ManifestValidator{
+			Line: rawValidator.line,
+		}
+
+		if validator.Address, err = rawValidator.Address.address(); err != nil {
+			return nil, err
+		}
+
+		if line, ok := addresses[validator.Address]; ok {
+			return nil, rawValidator.Address.errorf(
+				"validator %s is already listed on line %d",
+				validator.Address,
+				line,
+			)
+		}
+
+		addresses[validator.Address] = validator.Line
+
+		if rawValidator.Label != nil {
+			validator.Label = rawValidator.Label.value
+
+			if line, ok := labels[validator.Label]; ok {
+				return nil, rawValidator.Label.errorf(
+					"label %q is already used on line %d",
+					validator.Label,
+					line,
+				)
+			}
+
+			labels[validator.Label] = rawValidator.Label.line
+		}
+
+		if rawValidator.Stake != nil {
+			if validator.Stake, err = rawValidator.Stake.uint256(); err != nil {
+				return nil, err
+			}
+		}
+
+		for i := range rawValidator.NFTs {
+			rawTokenID := &rawValidator.NFTs[i]
+
+			tokenID, err := rawTokenID.uint256()
+			if err != nil {
+				return nil, err
+			}
+
+			if line, ok := tokens[tokenID.String()]; ok {
+				return nil, rawTokenID.errorf("token %s is already listed on line %d", tokenID, line)
+			}
+
+			tokens[tokenID.String()] = rawTokenID.line
+			validator.NFTs = append(validator.NFTs, tokenID)
+		}
+
+		if rawValidator.BLSPublicKey != nil {
+			if validator.BLSPublicKey, err = hex.DecodeHex(rawValidator.BLSPublicKey.value); err != nil ||
+				len(validator.BLSPublicKey) == 0 {
+				return nil, rawValidator.BLSPublicKey.errorf(
+					"invalid BLS public key %q",
+					rawValidator.BLSPublicKey.value,
+				)
+			}
+		}
+
+		manifest.Validators = append(manifest.Validators, validator)
+	}
+
+	return manifest, nil
+}
+
+// Addresses returns the validator addresses, in manifest order.
+// The order of the _validators array is decided by Params.Ordering
+func (m *ValidatorManifest) Addresses() []types.Address {
+	addresses := make([]types.Address, len(m.Validators))
+	for i, validator := range m.Validators {
+		addresses[i] = validator.Address
+	}
+
+	return addresses
+}
+
+// IBFTExtra encodes the genesis IBFT extra data of the manifest validators, in _validators order.
+// The BLS validator type is used if the validators have BLS public keys,
+// in which case every validator needs one
+func (m *ValidatorManifest) IBFTExtra() ([]byte, error) {
+	stakingAccount, err := PredeployStakingSCFromManifest(m)
+	if err != nil {
+		return nil, err
+	}
+
+	validators, err := NewStakingView(NewGenesisAccountReader(stakingAccount)).Validators()
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make(map[types.Address]*ManifestValidator, len(m.Validators))
+	blsValidators := false
+
+	for _, validator := range m.Validators {
+		entries[validator.Address] = validator
+		blsValidators = blsValidators || validator.BLSPublicKey != nil
+	}
+
+	if !blsValidators {
+		return EncodeIBFTExtra(validators, nil)
+	}
+
+	blsPublicKeys := make([][]byte, len(validators))
+
+	for i, validator := range validators {
+		entry := entries[validator]
+		if entry.BLSPublicKey == nil {
+			return nil, &ManifestError{
+				Line: entry.Line,
+				Err:  fmt.Errorf("validator %s has no BLS public key, but other validators do", validator),
+			}
+		}
+
+		blsPublicKeys[i] = entry.BLSPublicKey
+	}
+
+	return EncodeIBFTExtra(validators, blsPublicKeys)
+}
+
+// GenesisSpec converts the manifest into a staking genesis spec
+func (m *ValidatorManifest) GenesisSpec() StakingGenesisSpec {
+	spec := StakingGenesisSpec{
+		StakingAddress: m.StakingAddress,
+		NFTAddress:     m.NFTAddress,
+		Params:         m.Params,
+		Validators:     m.Addresses(),
+		Stakes:         make(map[types.Address]*big.Int),
+		StakedTokens:   make(map[types.Address][]*big.Int),
+	}
+
+	for _, validator := range m.Validators {
+		if validator.Stake != nil {
+			spec.Stakes[validator.Address] = validator.Stake
+		}
+
+		if len(validator.NFTs) > 0 {
+			spec.StakedTokens[validator.Address] = validator.NFTs
+		}
+	}
+
+	return spec
+}
+
+// PredeployStakingSCFromManifest generates the staking SC genesis account
+// for the validators listed in the manifest.
+// The manifest is checked like a genesis spec first, so the validator count
+// has to fit the [minValidatorCount, maxValidatorCount] bounds
+func PredeployStakingSCFromManifest(manifest *ValidatorManifest) (*chain.GenesisAccount, error) {
+	spec := manifest.GenesisSpec()
+
+	if err := spec.validate(); err != nil {
+		return nil, err
+	}
+
+	validators, params, err := spec.predeployValidators()
+	if err != nil {
+		return nil, err
+	}
+
+	stakingAccount, err := PredeployStakingSCWithStakes(validators, spec.predeployStakes(), params)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(spec.StakedTokens) > 0 {
+		if err := SetStakedNFTs(stakingAccount, spec.nftAddress(), spec.StakedTokens); err != nil {
+			return nil, err
+		}
+	}
+
+	return stakingAccount, nil
+}
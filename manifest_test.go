@@ -0,0 +1,255 @@
+package staking
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/0xPolygon/polygon-edge/types"
+)
+
+func TestParseValidatorManifestEntryLine(t *testing.T) {
+	cases := []struct {
+		name     string
+		manifest string
+		format   ManifestFormat
+		line     int
+	}{
+		{
+			name: "yaml entry without an address",
+			manifest: `validators:
+  - address: "0x0000000000000000000000000000000000000001"
+  - label: second
+    stake: 10
+`,
+			format: ManifestYAML,
+			line:   3,
+		},
+		{
+			name: "json entry without an address",
+			manifest: "{\n\t\"validators\": [\n\t\t{\"address\": \"0x0000000000000000000000000000000000000001\"},\n" +
+				"\t\t{\"label\": \"second\"}\n\t]\n}\n",
+			format: ManifestJSON,
+			line:   4,
+		},
+		{
+			name: "unknown validator field",
+			manifest: `validators:
+  - address: "0x0000000000000000000000000000000000000001"
+    stakes: 10
+`,
+			format: ManifestYAML,
+			line:   3,
+		},
+		{
+			name: "stake of the wrong type",
+			manifest: `validators:
+  - address: "0x0000000000000000000000000000000000000001"
+    stake: [1, 2]
+`,
+			format: ManifestYAML,
+			line:   3,
+		},
+		{
+			name: "token list of the wrong type",
+			manifest: `validators:
+  - address: "0x0000000000000000000000000000000000000001"
+  - address: "0x0000000000000000000000000000000000000002"
+    nfts: 5
+`,
+			format: ManifestYAML,
+			line:   4,
+		},
+		{
+			name:     "json validators of the wrong type",
+			manifest: "{\n\t\"params\": {},\n\t\"validators\": 5\n}\n",
+			format:   ManifestJSON,
+			line:     3,
+		},
+	}
+
+	for _, c := range cases {
+		c := c
+
+		t.Run(c.name, func(t *testing.T) {
+			_, err := ParseValidatorManifest([]byte(c.manifest), c.format)
+
+			var manifestErr *ManifestError
+			if !errors.As(err, &manifestErr) {
+				t.Fatalf("expected a ManifestError, got %v", err)
+			}
+
+			if manifestErr.Line != c.line {
+				t.Fatalf("expected the error on line %d, got %v", c.line, err)
+			}
+		})
+	}
+}
+
+func TestPredeployStakingSCFromManifestBounds(t *testing.T) {
+	manifest := &ValidatorManifest{
+		StakingAddress: DefaultStakingSCAddress,
+		Params: PredeployParams{
+			MinValidatorCount: 3,
+			MaxValidatorCount: 5,
+		},
+		Validators: []*ManifestValidator{
+			{Address: types.StringToAddress("0x1"), Stake: big.NewInt(1)},
+			{Address: types.StringToAddress("0x2"), Stake: big.NewInt(1)},
+		},
+	}
+
+	if _, err := PredeployStakingSCFromManifest(manifest); !errors.Is(err, errValidatorCount) {
+		t.Fatalf("expected errValidatorCount, got %v", err)
+	}
+
+	manifest.Params.MinValidatorCount = 2
+
+	if _, err := PredeployStakingSCFromManifest(manifest); err != nil {
+		t.Fatalf("unable to predeploy the staking SC, %v", err)
+	}
+}
+
+// manifestFixtures are the same complete manifest in both formats
+var manifestFixtures = map[ManifestFormat]string{
+	ManifestYAML: `stakingAddress: "0x0000000000000000000000000000000000002001"
+nftAddress: "0x0000000000000000000000000000000000002002"
+params:
+  minValidatorCount: 1
+  maxValidatorCount: 4
+  ordering: stake
+  storageMode: sparse
+validators:
+  - label: first
+    address: "0x0000000000000000000000000000000000000001"
+    stake: 5
+    blsPublicKey: "0x0101"
+  - label: second
+    address: "0x0000000000000000000000000000000000000002"
+    stake: "0x14"
+    blsPublicKey: "0x0202"
+  - label: third
+    address: "0x0000000000000000000000000000000000000003"
+    blsPublicKey: "0x0303"
+`,
+	ManifestJSON: `{
+	"stakingAddress": "0x0000000000000000000000000000000000002001",
+	"nftAddress": "0x0000000000000000000000000000000000002002",
+	"params": {
+		"minValidatorCount": 1,
+		"maxValidatorCount": 4,
+		"ordering": "stake",
+		"storageMode": "sparse"
+	},
+	"validators": [
+		{"label": "first", "address": "0x0000000000000000000000000000000000000001",
+			"stake": 5, "blsPublicKey": "0x0101"},
+		{"label": "second", "address": "0x0000000000000000000000000000000000000002",
+			"stake": "0x14", "blsPublicKey": "0x0202"},
+		{"label": "third", "address": "0x0000000000000000000000000000000000000003", "blsPublicKey": "0x0303"}
+	]
+}
+`,
+}
+
+func TestParseValidatorManifest(t *testing.T) {
+	var (
+		validatorA = types.StringToAddress("0x1")
+		validatorB = types.StringToAddress("0x2")
+		validatorC = types.StringToAddress("0x3")
+	)
+
+	for format, data := range manifestFixtures {
+		format, data := format, data
+
+		t.Run(string(format), func(t *testing.T) {
+			manifest, err := ParseValidatorManifest([]byte(data), format)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if manifest.StakingAddress != types.StringToAddress("0x2001") {
+				t.Errorf("unexpected staking address %s", manifest.StakingAddress)
+			}
+
+			if manifest.NFTAddress != types.StringToAddress("0x2002") {
+				t.Errorf("unexpected NFT address %s", manifest.NFTAddress)
+			}
+
+			wantParams := PredeployParams{
+				MinValidatorCount: 1,
+				MaxValidatorCount: 4,
+				Ordering:          OrderByStake,
+				StorageMode:       StorageModeSparse,
+			}
+			if manifest.Params != wantParams {
+				t.Errorf("expected params %+v, got %+v", wantParams, manifest.Params)
+			}
+
+			if want := []types.Address{validatorA, validatorB, validatorC}; !reflect.DeepEqual(manifest.Addresses(), want) {
+				t.Fatalf("expected validators %v, got %v", want, manifest.Addresses())
+			}
+
+			wants := []struct {
+				label string
+				stake *big.Int
+				key   []byte
+			}{
+				{"first", big.NewInt(5), []byte{0x01, 0x01}},
+				{"second", big.NewInt(20), []byte{0x02, 0x02}},
+				{"third", nil, []byte{0x03, 0x03}},
+			}
+
+			for i, want := range wants {
+				validator := manifest.Validators[i]
+
+				if validator.Label != want.label {
+					t.Errorf("validator %d: expected label %q, got %q", i, want.label, validator.Label)
+				}
+
+				if (want.stake == nil) != (validator.Stake == nil) ||
+					(want.stake != nil && want.stake.Cmp(validator.Stake) != 0) {
+					t.Errorf("validator %d: expected stake %v, got %v", i, want.stake, validator.Stake)
+				}
+
+				if !bytes.Equal(validator.BLSPublicKey, want.key) {
+					t.Errorf("validator %d: expected BLS key %x, got %x", i, want.key, validator.BLSPublicKey)
+				}
+			}
+		})
+	}
+}
+
+func TestValidatorManifestIBFTExtra(t *testing.T) {
+	manifest, err := ParseValidatorManifest([]byte(manifestFixtures[ManifestYAML]), ManifestYAML)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	extraData, err := manifest.IBFTExtra()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The extra data follows the stake ordering of _validators: 0x2 (20), 0x3 (default 10), 0x1 (5)
+	want, err := EncodeIBFTExtra(
+		[]types.Address{types.StringToAddress("0x2"), types.StringToAddress("0x3"), types.StringToAddress("0x1")},
+		[][]byte{{0x02, 0x02}, {0x03, 0x03}, {0x01, 0x01}},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(extraData, want) {
+		t.Fatalf("expected extra data %x, got %x", want, extraData)
+	}
+
+	manifest.Validators[2].BLSPublicKey = nil
+
+	var manifestErr *ManifestError
+	if _, err := manifest.IBFTExtra(); !errors.As(err, &manifestErr) || manifestErr.Line != manifest.Validators[2].Line {
+		t.Fatalf("expected a ManifestError on line %d, got %v", manifest.Validators[2].Line, err)
+	}
+}
@@ -0,0 +1,559 @@
+package staking
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"sort"
+
+	"github.com/0xPolygon/polygon-edge/helper/hex"
+	"github.com/0xPolygon/polygon-edge/helper/keccak"
+	"github.com/0xPolygon/polygon-edge/types"
+)
+
+// addressToBLSPublicKeySlot is the slot of the mapping(address => bytes)
+// of the BLS variant of the staking SC
+var addressToBLSPublicKeySlot = int64(7) // Slot 7
+
+var (
+	errMissingBLSPublicKey = errors.New("missing BLS public key")
+	errTokenStakeUnit      = errors.New("stakes are staked token counts, and the wei stake per token isn't set")
+)
+
+// StakingState is the decoded state of the staking SC, independent of the storage layout
+type StakingState struct {
+	// Validators are the validators in _validators order, with their stakes and weights
+	Validators []*ValidatorEntry
+
+	// Params holds the validator count bounds
+	Params PredeployParams
+
+	// StakedAmount is the total staked amount
+	StakedAmount *big.Int
+
+	// NFTAddress and StakedTokens are the NFT staking state of the NFT variant
+	NFTAddress   types.Address
+	StakedTokens map[types.Address][]*big.Int
+
+	// TokenStakes is set when the stakes and the total are staked token counts instead of wei amounts,
+	// as in the NFT variant once tokens are staked
+	TokenStakes bool
+
+	// BLSPublicKeys are the BLS public keys of the validators, used by the BLS variant
+	BLSPublicKeys map[types.Address][]byte
+}
+
+// ContractRevision is a revision of the staking SC, with its own code and storage layout
+type ContractRevision interface {
+	// Name returns the name of the revision
+	Name() string
+
+	// Code returns the runtime bytecode of the revision
+	Code() []byte
+
+	// StakesTokens reports if the stakes of the revision can be staked token counts
+	StakesTokens() bool
+
+	// Decode reads the state from storage in the layout of the revision
+	Decode(reader StateReader) (*StakingState, error)
+
+	// Encode writes the state into the full storage of the revision
+	Encode(state *StakingState) (map[types.Hash]types.Hash, error)
+}
+
+// NFTRevision is the NFT variant of the staking SC, the one embedded in StakingSCBytecode
+type NFTRevision struct {
+	// TokenIDs are the candidate staked token IDs used when decoding,
+	// as the staked tokens can't be enumerated from the storage
+	TokenIDs []*big.Int
+}
+
+// Name implements the ContractRevision interface
+func (r *NFTRevision) Name() string {
+	return "nft"
+}
+
+// Code implements the ContractRevision interface
+func (r *NFTRevision) Code() []byte {
+	code, _ := hex.DecodeHex(StakingSCBytecode)
+
+	return code
+}
+
+// StakesTokens implements the ContractRevision interface
+func (r *NFTRevision) StakesTokens() bool {
+	return true
+}
+
+// Decode implements the ContractRevision interface
+func (r *NFTRevision) Decode(reader StateReader) (*StakingState, error) {
+	export, err := ExportRegenesis(reader, RegenesisOptions{
+		TokenIDs: r.TokenIDs,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(export.Report.Issues) > 0 {
+		return nil, fmt.Errorf("unable to decode the %s revision state:\n%s", r.Name(), export.Report)
+	}
+
+	state := &StakingState{
+		Validators:   make([]*ValidatorEntry, len(export.Spec.Validators)),
+		Params:       export.Spec.Params,
+		StakedAmount: export.StakedAmount,
+		NFTAddress:   export.Spec.NFTAddress,
+		StakedTokens: export.Spec.StakedTokens,
+		TokenStakes:  export.Spec.tokenStaking(),
+	}
+
+	for i, validator := range export.Spec.Validators {
+		state.Validators[i] = &ValidatorEntry{
+			Address: validator,
+			Stake:   export.Stakes[validator],
+			Weight:  export.Weights[validator],
+		}
+	}
+
+	return state, nil
+}
+
+// Encode implements the ContractRevision interface
+func (r *NFTRevision) Encode(state *StakingState) (map[types.Hash]types.Hash, error) {
+	export := &RegenesisExport{
+		Spec: StakingGenesisSpec{
+			Params: PredeployParams{
+				MinValidatorCount: state.Params.MinValidatorCount,
+				MaxValidatorCount: state.Params.MaxValidatorCount,
+				Ordering:          OrderByInput,
+				StorageMode:       StorageModeSparse,
+			},
+			Validators:   make([]types.Address, len(state.Validators)),
+			NFTAddress:   state.NFTAddress,
+			StakedTokens: state.StakedTokens,
+		},
+		Stakes:       make(map[types.Address]*big.Int, len(state.Validators)),
+		Weights:      make(map[types.Address]*big.Int, len(state.Validators)),
+		StakedAmount: state.StakedAmount,
+	}
+
+	// With staked tokens, the spec derives the stakes from the token counts
+	if !export.Spec.tokenStaking() {
+		export.Spec.Stakes = make(map[types.Address]*big.Int, len(state.Validators))
+	}
+
+	for i, entry := range state.Validators {
+		export.Spec.Validators[i] = entry.Address
+		export.Stakes[entry.Address] = entry.Stake
+
+		if export.Spec.Stakes != nil {
+			export.Spec.Stakes[entry.Address] = entry.Stake
+		}
+
+		export.Weights[entry.Address] = big.NewInt(0)
+		if entry.Weight != nil {
+			export.Weights[entry.Address] = entry.Weight
+		}
+	}
+
+	if err := export.buildAccounts(); err != nil {
+		return nil, err
+	}
+
+	return export.Account.Storage, nil
+}
+
+// BLSRevision is the BLS variant of the staking SC, which keeps
+// a mapping(address => bytes) of the validator BLS public keys at slot 7
+// instead of the NFT staking state
+type BLSRevision struct {
+	code []byte
+
+	// PublicKeys are the BLS public keys of the validators
+	// whose keys are missing from the migrated state
+	PublicKeys map[types.Address][]byte
+
+	// TokenStake is the wei amount a staked token converts to.
+	// The BLS variant stakes wei, so a state whose stakes are token counts
+	// can only be encoded once it's set
+	TokenStake *big.Int
+}
+
+// NewBLSRevision creates the BLS revision with the given runtime bytecode
+func NewBLSRevision(code []byte, publicKeys map[types.Address][]byte) *BLSRevision {
+	return &BLSRevision{
+		code:       code,
+		PublicKeys: publicKeys,
+	}
+}
+
+// Name implements the ContractRevision interface
+func (r *BLSRevision) Name() string {
+	return "bls"
+}
+
+// Code implements the ContractRevision interface
+func (r *BLSRevision) Code() []byte {
+	return r.code
+}
+
+// StakesTokens implements the ContractRevision interface
+func (r *BLSRevision) StakesTokens() bool {
+	return false
+}
+
+// Decode implements the ContractRevision interface
+func (r *BLSRevision) Decode(reader StateReader) (*StakingState, error) {
+	view := NewStakingView(reader)
+
+	validators, err := view.Validators()
+	if err != nil {
+		return nil, err
+	}
+
+	bounds, err := view.Bounds()
+	if err != nil {
+		return nil, err
+	}
+
+	stakedAmount, err := view.TotalStaked()
+	if err != nil {
+		return nil, err
+	}
+
+	state := &StakingState{
+		Validators:    make([]*ValidatorEntry, len(validators)),
+		Params:        bounds,
+		StakedAmount:  stakedAmount,
+		BLSPublicKeys: make(map[types.Address][]byte, len(validators)),
+	}
+
+	for i, validator := range validators {
+		stake, err := view.StakeOf(validator)
+		if err != nil {
+			return nil, err
+		}
+
+		publicKey, err := readBytesStorage(reader, getAddressMapping(validator, addressToBLSPublicKeySlot))
+		if err != nil {
+			return nil, fmt.Errorf("unable to read the BLS public key of %s, %w", validator, err)
+		}
+
+		state.Validators[i] = &ValidatorEntry{
+			Address: validator,
+			Stake:   stake,
+			Weight:  big.NewInt(0),
+		}
+		state.BLSPublicKeys[validator] = publicKey
+	}
+
+	return state, nil
+}
+
+// Encode implements the ContractRevision interface
+func (r *BLSRevision) Encode(state *StakingState) (map[types.Hash]types.Hash, error) {
+	// Token counts are converted to wei at the given rate
+	stakeOf := func(stake *big.Int) *big.Int {
+		return stake
+	}
+
+	if state.TokenStakes {
+		if r.TokenStake == nil || r.TokenStake.Sign() <= 0 {
+			return nil, errTokenStakeUnit
+		}
+
+		stakeOf = func(stake *big.Int) *big.Int {
+			return new(big.Int).Mul(stake, r.TokenStake)
+		}
+	}
+
+	validators := make([]types.Address, len(state.Validators))
+	stakes := make(map[types.Address]*big.Int, len(state.Validators))
+
+	for i, entry := range state.Validators {
+		validators[i] = entry.Address
+		stakes[entry.Address] = stakeOf(entry.Stake)
+	}
+
+	account, err := PredeployStakingSCWithStakes(validators, stakes, PredeployParams{
+		MinValidatorCount: state.Params.MinValidatorCount,
+		MaxValidatorCount: state.Params.MaxValidatorCount,
+		Ordering:          OrderByInput,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	storageMap := account.Storage
+
+	// Set the value for the total staked amount
+	storageMap[types.BytesToHash(big.NewInt(stakedAmountSlot).Bytes())] =
+		types.BytesToHash(stakeOf(state.StakedAmount).Bytes())
+
+	for _, validator := range validators {
+		publicKey, ok := state.BLSPublicKeys[validator]
+		if !ok || len(publicKey) == 0 {
+			publicKey = r.PublicKeys[validator]
+		}
+
+		if len(publicKey) == 0 {
+			return nil, fmt.Errorf("%w for validator %s", errMissingBLSPublicKey, validator)
+		}
+
+		// Set the value for the address -> BLS public key mapping
+		writeBytesStorage(storageMap, getAddressMapping(validator, addressToBLSPublicKeySlot), publicKey)
+	}
+
+	return SparseStorage(storageMap), nil
+}
+
+// writeBytesStorage writes a Solidity bytes value to the given slot.
+// Short values are stored in the slot itself along with length * 2,
+// longer ones store length * 2 + 1 in the slot and the data from keccak(slot) on
+func writeBytesStorage(storage map[types.Hash]types.Hash, slot []byte, value []byte) {
+	slotHash := types.BytesToHash(slot)
+
+	if len(value) < 32 {
+		var word types.Hash
+
+		copy(word[:], value)
+		word[31] = byte(2 * len(value))
+		storage[slotHash] = word
+
+		return
+	}
+
+	storage[slotHash] = types.BytesToHash(big.NewInt(int64(2*len(value) + 1)).Bytes())
+	dataSlot := types.BytesToHash(keccak.Keccak256(nil, slotHash.Bytes()))
+
+	for offset := 0; offset < len(value); offset += 32 {
+		var word types.Hash
+
+		copy(word[:], value[offset:])
+		storage[addToHash(dataSlot, uint64(offset/32))] = word
+	}
+}
+
+// readBytesStorage reads a Solidity bytes value from the given slot
+func readBytesStorage(reader StateReader, slot []byte) ([]byte, error) {
+	slotHash := types.BytesToHash(slot)
+
+	word, err := reader.GetStorage(slotHash)
+	if err != nil {
+		return nil, err
+	}
+
+	if word[31]&1 == 0 {
+		length := int(word[31] / 2)
+		if length >= 32 {
+			return nil, fmt.Errorf("invalid short bytes length %d", length)
+		}
+
+		return append([]byte(nil), word[:length]...), nil
+	}
+
+	length := new(big.Int).SetBytes(word.Bytes())
+	length.Rsh(length, 1)
+
+	if !length.IsInt64() || length.Int64() > 1<<16 {
+		return nil, fmt.Errorf("invalid bytes length %s", length)
+	}
+
+	value := make([]byte, 0, length.Int64()+31)
+	dataSlot := types.BytesToHash(keccak.Keccak256(nil, slotHash.Bytes()))
+
+	for offset := int64(0); offset < length.Int64(); offset += 32 {
+		data, err := reader.GetStorage(addToHash(dataSlot, uint64(offset/32)))
+		if err != nil {
+			return nil, err
+		}
+
+		value = append(value, data.Bytes()...)
+	}
+
+	return value[:length.Int64()], nil
+}
+
+// StorageDiffEntry is a storage slot changed by a migration
+type StorageDiffEntry struct {
+	Key types.Hash `json:"key"`
+	Old types.Hash `json:"old"`
+	New types.Hash `json:"new"`
+}
+
+// StateWriter is the part of the state a fork's state override hook writes to.
+// polygon-edge's state transaction satisfies it
+type StateWriter interface {
+	SetCode(addr types.Address, code []byte)
+	SetState(addr types.Address, key, value types.Hash)
+}
+
+// MigrationWriter is the part of the state a storage migration writes to.
+// polygon-edge's state transaction satisfies it
+type MigrationWriter interface {
+	StateWriter
+	SetBalance(addr types.Address, balance *big.Int)
+}
+
+// StorageMigration rewrites the staking SC from one revision to another
+type StorageMigration struct {
+	Address types.Address `json:"address"`
+	From    string        `json:"from"`
+	To      string        `json:"to"`
+
+	// Code is the runtime bytecode of the target revision
+	Code []byte `json:"code"`
+
+	// Storage is the full storage of the target revision, without zero words
+	Storage map[types.Hash]types.Hash `json:"storage"`
+
+	// Diff holds every known slot whose value changes, ordered by key.
+	// Slots of the old layout missing from the new one are cleared
+	Diff []StorageDiffEntry `json:"diff"`
+
+	// Balance is the new balance of the staking SC, set when staked token counts
+	// are converted to wei stakes, so the SC holds the total it can be unstaked for.
+	// The difference with the current balance is minted by the migration
+	Balance *big.Int `json:"balance,omitempty"`
+
+	// StrandedTokens are the tokens staked in the source revision, which stay owned
+	// by the staking SC in the ERC721 SC once the target revision doesn't stake tokens.
+	// The target revision can't unstake them, so they have to be handed back separately
+	StrandedTokens map[types.Address][]*big.Int `json:"strandedTokens,omitempty"`
+}
+
+// MigrateStorage decodes the staking SC state in the layout of the source revision,
+// and encodes it in the layout of the target revision.
+// The slots of both layouts are compared with the current storage to build the diff.
+// If the target revision converts staked token counts to wei stakes, the migration
+// sets the SC balance to the new total, and reports the tokens left in the ERC721 SC
+func MigrateStorage(
+	reader StateReader,
+	address types.Address,
+	from, to ContractRevision,
+) (*StorageMigration, error) {
+	state, err := from.Decode(reader)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode the %s revision storage, %w", from.Name(), err)
+	}
+
+	// The old layout is encoded again, so its slots are known
+	oldStorage, err := from.Encode(state)
+	if err != nil {
+		return nil, fmt.Errorf("unable to encode the %s revision storage, %w", from.Name(), err)
+	}
+
+	newStorage, err := to.Encode(state)
+	if err != nil {
+		return nil, fmt.Errorf("unable to encode the %s revision storage, %w", to.Name(), err)
+	}
+
+	newStorage = SparseStorage(newStorage)
+
+	keys := make([]types.Hash, 0, len(oldStorage)+len(newStorage))
+	for key := range oldStorage {
+		keys = append(keys, key)
+	}
+
+	for key := range newStorage {
+		if _, ok := oldStorage[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		return bytes.Compare(keys[i].Bytes(), keys[j].Bytes()) < 0
+	})
+
+	migration := &StorageMigration{
+		Address: address,
+		From:    from.Name(),
+		To:      to.Name(),
+		Code:    to.Code(),
+		Storage: newStorage,
+		Diff:    make([]StorageDiffEntry, 0),
+	}
+
+	for _, key := range keys {
+		current, err := reader.GetStorage(key)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read storage slot %s, %w", key, err)
+		}
+
+		if current != newStorage[key] {
+			migration.Diff = append(migration.Diff, StorageDiffEntry{
+				Key: key,
+				Old: current,
+				New: newStorage[key],
+			})
+		}
+	}
+
+	if !state.TokenStakes || to.StakesTokens() {
+		return migration, nil
+	}
+
+	// The target revision converted the token counts to wei
+	if migration.Balance, err = NewStakingView(StorageMap(newStorage)).TotalStaked(); err != nil {
+		return nil, err
+	}
+
+	migration.StrandedTokens = state.StakedTokens
+
+	return migration, nil
+}
+
+// Apply writes the target revision code, the changed slots and the new balance to the state
+func (m *StorageMigration) Apply(writer MigrationWriter) {
+	writer.SetCode(m.Address, m.Code)
+
+	for _, entry := range m.Diff {
+		writer.SetState(m.Address, entry.Key, entry.New)
+	}
+
+	if m.Balance != nil {
+		writer.SetBalance(m.Address, m.Balance)
+	}
+}
+
+// WriteDiff writes the dry-run output of the migration, every changed slot
+// with its current and new value
+func (m *StorageMigration) WriteDiff(w io.Writer) error {
+	if _, err := fmt.Fprintf(
+		w,
+		"migration of %s from the %s revision to the %s revision, %d slots changed\n",
+		m.Address,
+		m.From,
+		m.To,
+		len(m.Diff),
+	); err != nil {
+		return err
+	}
+
+	for _, entry := range m.Diff {
+		if _, err := fmt.Fprintf(w, "%s\n  - %s\n  + %s\n", entry.Key, entry.Old, entry.New); err != nil {
+			return err
+		}
+	}
+
+	if m.Balance != nil {
+		if _, err := fmt.Fprintf(w, "balance set to %s\n", m.Balance); err != nil {
+			return err
+		}
+	}
+
+	for _, staker := range sortedTokenOwners(m.StrandedTokens) {
+		if _, err := fmt.Fprintf(
+			w,
+			"tokens %v of %s stay in the ERC721 SC\n",
+			m.StrandedTokens[staker],
+			staker,
+		); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,255 @@
+package staking
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/0xPolygon/polygon-edge/types"
+)
+
+// migrationWriter is a MigrationWriter over the state of a single account
+type migrationWriter struct {
+	code    []byte
+	storage map[types.Hash]types.Hash
+	balance *big.Int
+}
+
+func (w *migrationWriter) SetCode(addr types.Address, code []byte) {
+	w.code = code
+}
+
+func (w *migrationWriter) SetState(addr types.Address, key, value types.Hash) {
+	if value == types.ZeroHash {
+		delete(w.storage, key)
+
+		return
+	}
+
+	w.storage[key] = value
+}
+
+func (w *migrationWriter) SetBalance(addr types.Address, balance *big.Int) {
+	w.balance = balance
+}
+
+func TestBytesStorageRoundTrip(t *testing.T) {
+	slot := getAddressMapping(types.StringToAddress("0x11"), addressToBLSPublicKeySlot)
+
+	for _, length := range []int{0, 1, 31, 32, 33, 48, 64, 100} {
+		value := make([]byte, length)
+		for i := range value {
+			value[i] = byte(i + 1)
+		}
+
+		storage := make(map[types.Hash]types.Hash)
+		writeBytesStorage(storage, slot, value)
+
+		// Values up to 31 bytes fit in the slot itself, longer ones take a word per 32 bytes
+		expectedWords := 1
+		if length >= 32 {
+			expectedWords += (length + 31) / 32
+		}
+
+		if len(storage) != expectedWords {
+			t.Fatalf("%d bytes: expected %d words, got %d", length, expectedWords, len(storage))
+		}
+
+		head := storage[types.BytesToHash(slot)]
+		if length < 32 && head[31] != byte(2*length) {
+			t.Fatalf("%d bytes: expected the short length %d, got %d", length, 2*length, head[31])
+		}
+
+		if length >= 32 && new(big.Int).SetBytes(head.Bytes()).Int64() != int64(2*length+1) {
+			t.Fatalf("%d bytes: expected the long length %d, got %s", length, 2*length+1, head)
+		}
+
+		read, err := readBytesStorage(StorageMap(storage), slot)
+		if err != nil {
+			t.Fatalf("%d bytes: %v", length, err)
+		}
+
+		if !bytes.Equal(read, value) {
+			t.Fatalf("%d bytes: expected %x, got %x", length, value, read)
+		}
+	}
+}
+
+func TestMigrateStorageNFTToBLS(t *testing.T) {
+	validators := generatorValidators(3)
+	tokenStake := new(big.Int).Exp(big.NewInt(10), big.NewInt(18), nil)
+
+	stakedTokens := map[types.Address][]*big.Int{
+		validators[0]: {big.NewInt(4), big.NewInt(9)},
+		validators[1]: {big.NewInt(5)},
+		validators[2]: {big.NewInt(6)},
+	}
+
+	accounts, err := BuildStakingGenesis(StakingGenesisSpec{
+		Params: PredeployParams{
+			MinValidatorCount: 1,
+			MaxValidatorCount: 5,
+		},
+		Validators:   validators,
+		StakedTokens: stakedTokens,
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	live := SparseStorage(accounts[DefaultStakingSCAddress].Storage)
+	from := &NFTRevision{
+		TokenIDs: []*big.Int{big.NewInt(4), big.NewInt(5), big.NewInt(6), big.NewInt(9)},
+	}
+
+	// The BLS public keys of the first two validators don't fit in a single word
+	publicKeys := map[types.Address][]byte{
+		validators[0]: bytes.Repeat([]byte{0xab}, 48),
+		validators[1]: bytes.Repeat([]byte{0xcd}, 32),
+		validators[2]: {0x01, 0x02, 0x03},
+	}
+
+	// Token counts can't be migrated without a rate
+	if _, err := MigrateStorage(
+		StorageMap(live),
+		DefaultStakingSCAddress,
+		from,
+		NewBLSRevision([]byte{0x00}, publicKeys),
+	); !errors.Is(err, errTokenStakeUnit) {
+		t.Fatalf("expected the token stake unit error, got %v", err)
+	}
+
+	to := NewBLSRevision([]byte{0x00}, publicKeys)
+	to.TokenStake = tokenStake
+
+	migration, err := MigrateStorage(StorageMap(live), DefaultStakingSCAddress, from, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wei := func(tokens int64) *big.Int {
+		return new(big.Int).Mul(big.NewInt(tokens), tokenStake)
+	}
+
+	diff := make(map[types.Hash]StorageDiffEntry, len(migration.Diff))
+	for _, entry := range migration.Diff {
+		diff[entry.Key] = entry
+	}
+
+	// The NFT staking state of slots 7, 8 and 9 is cleared
+	cleared := map[types.Hash]types.Hash{
+		types.BytesToHash(big.NewInt(nftAddressSlot).Bytes()): types.BytesToHash(DefaultNFTSCAddress.Bytes()),
+	}
+
+	for staker, tokens := range stakedTokens {
+		weight := big.NewInt(0)
+
+		for _, tokenID := range tokens {
+			cleared[types.BytesToHash(getUint256Mapping(tokenID, tokenIDToOwnerSlot))] =
+				types.BytesToHash(staker.Bytes())
+
+			weight.Add(weight, getNFTWeight(tokenID))
+		}
+
+		cleared[types.BytesToHash(getAddressMapping(staker, addressToWeightSlot))] =
+			types.BytesToHash(weight.Bytes())
+	}
+
+	for key, old := range cleared {
+		entry, ok := diff[key]
+		if !ok {
+			t.Fatalf("slot %s isn't cleared", key)
+		}
+
+		if entry.Old != old || entry.New != types.ZeroHash {
+			t.Fatalf("slot %s: expected %s to be cleared, got %s -> %s", key, old, entry.Old, entry.New)
+		}
+	}
+
+	// The token counts are converted to wei, and the balance covers them
+	if migration.Balance == nil || migration.Balance.Cmp(wei(4)) != 0 {
+		t.Fatalf("expected the balance %s, got %v", wei(4), migration.Balance)
+	}
+
+	if len(migration.StrandedTokens) != len(stakedTokens) {
+		t.Fatalf("expected the stranded tokens %v, got %v", stakedTokens, migration.StrandedTokens)
+	}
+
+	for staker, tokens := range stakedTokens {
+		if len(migration.StrandedTokens[staker]) != len(tokens) {
+			t.Fatalf("expected the stranded tokens %v of %s, got %v", tokens, staker, migration.StrandedTokens[staker])
+		}
+	}
+
+	writer := &migrationWriter{
+		storage: make(map[types.Hash]types.Hash, len(live)),
+	}
+
+	for key, value := range live {
+		writer.storage[key] = value
+	}
+
+	migration.Apply(writer)
+
+	if !StorageEqual(writer.storage, migration.Storage) {
+		t.Fatal("the applied diff doesn't produce the migrated storage")
+	}
+
+	if writer.balance.Cmp(wei(4)) != 0 {
+		t.Fatalf("expected the applied balance %s, got %s", wei(4), writer.balance)
+	}
+
+	state, err := to.Decode(StorageMap(writer.storage))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if state.StakedAmount.Cmp(wei(4)) != 0 {
+		t.Fatalf("expected the total %s, got %s", wei(4), state.StakedAmount)
+	}
+
+	for i, entry := range state.Validators {
+		expected := wei(int64(len(stakedTokens[validators[i]])))
+
+		if entry.Address != validators[i] || entry.Stake.Cmp(expected) != 0 {
+			t.Fatalf("validator %d: expected %s staking %s, got %s staking %s",
+				i, validators[i], expected, entry.Address, entry.Stake)
+		}
+
+		if !bytes.Equal(state.BLSPublicKeys[entry.Address], publicKeys[entry.Address]) {
+			t.Fatalf("validator %d: expected the BLS key %x, got %x",
+				i, publicKeys[entry.Address], state.BLSPublicKeys[entry.Address])
+		}
+	}
+
+	var output strings.Builder
+	if err := migration.WriteDiff(&output); err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(output.String(), "balance set to "+wei(4).String()) ||
+		!strings.Contains(output.String(), "stay in the ERC721 SC") {
+		t.Fatalf("expected the balance and the stranded tokens in the diff, got:\n%s", output.String())
+	}
+
+	// Migrating to the same revision changes nothing
+	noop, err := MigrateStorage(StorageMap(writer.storage), DefaultStakingSCAddress, to, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(noop.Diff) != 0 || noop.Balance != nil {
+		t.Fatalf("expected an empty migration, got %d slots and the balance %v", len(noop.Diff), noop.Balance)
+	}
+
+	noop, err = MigrateStorage(StorageMap(live), DefaultStakingSCAddress, from, &NFTRevision{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(noop.Diff) != 0 || noop.Balance != nil || noop.StrandedTokens != nil {
+		t.Fatalf("expected an empty migration, got %d slots", len(noop.Diff))
+	}
+}
@@ -0,0 +1,148 @@
+package staking
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"sort"
+
+	"github.com/0xPolygon/polygon-edge/chain"
+	"github.com/0xPolygon/polygon-edge/types"
+)
+
+// ValidatorOrderingKey is the key of the validator ordering policy in the consensus engine config
+const ValidatorOrderingKey = "validatorOrdering"
+
+// ValidatorOrdering is the policy for assigning _validators indexes
+// to the pre-staked validators
+type ValidatorOrdering string
+
+const (
+	// OrderByInput keeps the order the validators were passed in.
+	// The zero value of ValidatorOrdering behaves the same way
+	OrderByInput ValidatorOrdering = "input"
+
+	// OrderByAddress sorts the validators by address, ascending
+	OrderByAddress ValidatorOrdering = "address"
+
+	// OrderByStake sorts the validators by stake, descending,
+	// and validators with equal stakes by address, ascending
+	OrderByStake ValidatorOrdering = "stake"
+)
+
+// ParseValidatorOrdering parses the name of a validator ordering policy
+func ParseValidatorOrdering(name string) (ValidatorOrdering, error) {
+	switch ordering := ValidatorOrdering(name); ordering {
+	case "", OrderByInput:
+		return OrderByInput, nil
+	case OrderByAddress, OrderByStake:
+		return ordering, nil
+	default:
+		return "", fmt.Errorf("unknown validator ordering %q", name)
+	}
+}
+
+// String returns the name of the ordering policy
+func (o ValidatorOrdering) String() string {
+	if o == "" {
+		return string(OrderByInput)
+	}
+
+	return string(o)
+}
+
+// orderValidators returns a copy of the validators ordered by the given policy.
+// Validators missing from the stakes map are considered staked with the default stake.
+// A validator listed more than once is rejected, as the validator mappings can only hold it once
+func orderValidators(
+	validators []types.Address,
+	stakes map[types.Address]*big.Int,
+	defaultStake *big.Int,
+	ordering ValidatorOrdering,
+) ([]types.Address, error) {
+	seen := make(map[types.Address]struct{}, len(validators))
+
+	for _, validator := range validators {
+		if _, ok := seen[validator]; ok {
+			return nil, fmt.Errorf("%w: %s", errDuplicateValidator, validator)
+		}
+
+		seen[validator] = struct{}{}
+	}
+
+	ordered := make([]types.Address, len(validators))
+	copy(ordered, validators)
+
+	byAddress := func(i, j int) bool {
+		return bytes.Compare(ordered[i].Bytes(), ordered[j].Bytes()) < 0
+	}
+
+	switch ordering {
+	case "", OrderByInput:
+	case OrderByAddress:
+		sort.SliceStable(ordered, byAddress)
+	case OrderByStake:
+		stakeOf := func(validator types.Address) *big.Int {
+			if stake, ok := stakes[validator]; ok && stake != nil {
+				return stake
+			}
+
+			return defaultStake
+		}
+
+		sort.SliceStable(ordered, func(i, j int) bool {
+			if cmp := stakeOf(ordered[i]).Cmp(stakeOf(ordered[j])); cmp != 0 {
+				return cmp > 0
+			}
+
+			return byAddress(i, j)
+		})
+	default:
+		return nil, fmt.Errorf("unknown validator ordering %q", string(ordering))
+	}
+
+	return ordered, nil
+}
+
+// SetValidatorOrdering stores the ordering policy in the config of the consensus engine,
+// next to the validator count bounds, so the predeploy can be reproduced from the chain config
+func SetValidatorOrdering(params *chain.Params, engine string, ordering ValidatorOrdering) error {
+	if _, err := ParseValidatorOrdering(string(ordering)); err != nil {
+		return err
+	}
+
+	config, err := engineConfig(params, engine)
+	if err != nil {
+		return err
+	}
+
+	config[ValidatorOrderingKey] = ordering.String()
+	params.Engine[engine] = config
+
+	return nil
+}
+
+// GetValidatorOrdering reads the ordering policy from the config of the consensus engine.
+// It returns OrderByInput if the config doesn't have one
+func GetValidatorOrdering(params *chain.Params, engine string) (ValidatorOrdering, error) {
+	config, err := engineConfig(params, engine)
+	if err != nil {
+		return "", err
+	}
+
+	return parseEngineOrdering(config[ValidatorOrderingKey])
+}
+
+// parseEngineOrdering parses the ordering policy value of the engine config
+func parseEngineOrdering(value interface{}) (ValidatorOrdering, error) {
+	if value == nil {
+		return OrderByInput, nil
+	}
+
+	name, ok := value.(string)
+	if !ok {
+		return "", fmt.Errorf("invalid %s of type %T", ValidatorOrderingKey, value)
+	}
+
+	return ParseValidatorOrdering(name)
+}
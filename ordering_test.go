@@ -0,0 +1,88 @@
+package staking
+
+import (
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/0xPolygon/polygon-edge/chain"
+	"github.com/0xPolygon/polygon-edge/types"
+)
+
+func TestOrderValidators(t *testing.T) {
+	var (
+		low  = types.StringToAddress("0x1")
+		mid  = types.StringToAddress("0x2")
+		high = types.StringToAddress("0x3")
+	)
+
+	validators := []types.Address{high, low, mid}
+	stakes := map[types.Address]*big.Int{
+		low:  big.NewInt(5),
+		high: big.NewInt(5),
+	}
+
+	cases := []struct {
+		ordering ValidatorOrdering
+		want     []types.Address
+	}{
+		{"", []types.Address{high, low, mid}},
+		{OrderByInput, []types.Address{high, low, mid}},
+		{OrderByAddress, []types.Address{low, mid, high}},
+		// mid has the default stake, low and high are tied and sorted by address
+		{OrderByStake, []types.Address{mid, low, high}},
+	}
+
+	for _, c := range cases {
+		got, err := orderValidators(validators, stakes, big.NewInt(10), c.ordering)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("ordering %s: expected %v, got %v", c.ordering, c.want, got)
+		}
+	}
+
+	if _, err := orderValidators(validators, stakes, big.NewInt(10), "weight"); err == nil {
+		t.Fatal("expected an unknown ordering error")
+	}
+}
+
+func TestValidatorOrderingEngineConfig(t *testing.T) {
+	params := &chain.Params{
+		Engine: map[string]interface{}{
+			IBFTEngineName: map[string]interface{}{
+				engineTypeKey:              enginePoSType,
+				engineMinValidatorCountKey: float64(1),
+				engineMaxValidatorCountKey: float64(4),
+			},
+		},
+	}
+
+	ordering, err := GetValidatorOrdering(params, IBFTEngineName)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ordering != OrderByInput {
+		t.Fatalf("expected the %s ordering by default, got %s", OrderByInput, ordering)
+	}
+
+	if err := SetValidatorOrdering(params, IBFTEngineName, OrderByStake); err != nil {
+		t.Fatal(err)
+	}
+
+	predeployParams, err := PredeployParamsFromChain(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if predeployParams.Ordering != OrderByStake {
+		t.Fatalf("expected the %s ordering, got %s", OrderByStake, predeployParams.Ordering)
+	}
+
+	if err := SetValidatorOrdering(params, IBFTEngineName, "weight"); err == nil {
+		t.Fatal("expected an unknown ordering error")
+	}
+}
@@ -0,0 +1,427 @@
+package staking
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/0xPolygon/polygon-edge/chain"
+	"github.com/0xPolygon/polygon-edge/helper/keccak"
+	"github.com/0xPolygon/polygon-edge/state"
+	itrie "github.com/0xPolygon/polygon-edge/state/immutable-trie"
+	"github.com/0xPolygon/polygon-edge/types"
+	"github.com/hashicorp/go-hclog"
+	"github.com/umbracle/fastrlp"
+)
+
+var (
+	errMissingTrieNode = errors.New("trie node is missing")
+	errInvalidTrieNode = errors.New("invalid trie node")
+	errProofMismatch   = errors.New("proof doesn't match the claimed value")
+)
+
+// NodeReader gives access to the encoded trie nodes by their hash.
+// The storage of polygon-edge's immutable trie satisfies it
+type NodeReader interface {
+	Get(k []byte) ([]byte, bool)
+}
+
+// StorageProof is the proof of a single storage slot, as returned by eth_getProof
+type StorageProof struct {
+	Key   types.Hash
+	Value *big.Int
+	Proof [][]byte
+}
+
+// AccountProof is the proof of an account and some of its storage slots,
+// as returned by eth_getProof
+type AccountProof struct {
+	Address      types.Address
+	Balance      *big.Int
+	Nonce        uint64
+	CodeHash     types.Hash
+	StorageHash  types.Hash
+	AccountProof [][]byte
+	StorageProof []*StorageProof
+}
+
+// proofNodes is a NodeReader over the nodes of a proof
+type proofNodes map[types.Hash][]byte
+
+func newProofNodes(proof [][]byte) proofNodes {
+	nodes := make(proofNodes, len(proof))
+	for _, node := range proof {
+		nodes[types.BytesToHash(keccak.Keccak256(nil, node))] = node
+	}
+
+	return nodes
+}
+
+func (p proofNodes) Get(k []byte) ([]byte, bool) {
+	node, ok := p[types.BytesToHash(k)]
+
+	return node, ok
+}
+
+// keyNibbles splits the key into the nibbles of the trie path
+func keyNibbles(key []byte) []byte {
+	nibbles := make([]byte, 2*len(key))
+	for i, b := range key {
+		nibbles[2*i] = b >> 4
+		nibbles[2*i+1] = b & 0x0f
+	}
+
+	return nibbles
+}
+
+// decodeHexPrefix decodes the path of a short node
+func decodeHexPrefix(encoded []byte) ([]byte, bool, error) {
+	if len(encoded) == 0 {
+		return nil, false, errInvalidTrieNode
+	}
+
+	nibbles := keyNibbles(encoded)
+	flag := nibbles[0]
+	isLeaf := flag&2 != 0
+
+	if flag&1 != 0 {
+		return nibbles[1:], isLeaf, nil
+	}
+
+	return nibbles[2:], isLeaf, nil
+}
+
+// walkTrie looks up the key in the trie with the given root.
+// It returns the stored value (nil if the key is absent) and the
+// hash-referenced nodes on the path, which form the proof
+func walkTrie(nodes NodeReader, root types.Hash, key []byte) ([]byte, [][]byte, error) {
+	var proof [][]byte
+
+	if root == types.EmptyRootHash || root == types.ZeroHash {
+		return nil, proof, nil
+	}
+
+	var (
+		path    = keyNibbles(keccak.Keccak256(nil, key))
+		hash    = root.Bytes()
+		encoded []byte
+	)
+
+	for {
+		if encoded == nil {
+			node, ok := nodes.Get(hash)
+			if !ok {
+				return nil, nil, fmt.Errorf("%w: %s", errMissingTrieNode, types.BytesToHash(hash))
+			}
+
+			// The proof gets its own copy, so it doesn't alias the node storage
+			encoded = append([]byte{}, node...)
+			proof = append(proof, encoded)
+		}
+
+		// The parsed values are only valid until the next parse, so every node gets its own parser
+		node, err := (&fastrlp.Parser{}).Parse(encoded)
+		if err != nil || node.Type() != fastrlp.TypeArray {
+			return nil, nil, errInvalidTrieNode
+		}
+
+		var child *fastrlp.Value
+
+		switch node.Elems() {
+		case 17:
+			// Full node
+			if len(path) == 0 {
+				value, err := trieValue(node.Get(16))
+
+				return value, proof, err
+			}
+
+			child = node.Get(int(path[0]))
+			path = path[1:]
+		case 2:
+			// Short node, either a leaf or an extension
+			encodedPath, err := node.Get(0).Bytes()
+			if err != nil {
+				return nil, nil, errInvalidTrieNode
+			}
+
+			nodePath, isLeaf, err := decodeHexPrefix(encodedPath)
+			if err != nil {
+				return nil, nil, err
+			}
+
+			if isLeaf {
+				if !bytes.Equal(nodePath, path) {
+					return nil, proof, nil
+				}
+
+				value, err := trieValue(node.Get(1))
+
+				return value, proof, err
+			}
+
+			if len(path) < len(nodePath) || !bytes.Equal(nodePath, path[:len(nodePath)]) {
+				return nil, proof, nil
+			}
+
+			child = node.Get(1)
+			path = path[len(nodePath):]
+		default:
+			return nil, nil, errInvalidTrieNode
+		}
+
+		if child.Type() == fastrlp.TypeArray {
+			// The child is embedded in its parent
+			encoded = child.MarshalTo(nil)
+
+			continue
+		}
+
+		ref, err := child.Bytes()
+
+		switch {
+		case err != nil:
+			return nil, nil, errInvalidTrieNode
+		case len(ref) == 0:
+			return nil, proof, nil
+		case len(ref) != types.HashLength:
+			return nil, nil, errInvalidTrieNode
+		}
+
+		hash = append([]byte{}, ref...)
+		encoded = nil
+	}
+}
+
+// trieValue returns a copy of the value stored in a trie node, nil if it's empty
+func trieValue(value *fastrlp.Value) ([]byte, error) {
+	data, err := value.Bytes()
+	if err != nil {
+		return nil, errInvalidTrieNode
+	}
+
+	if len(data) == 0 {
+		return nil, nil
+	}
+
+	return append([]byte{}, data...), nil
+}
+
+// decodeAccount decodes an account of the state trie
+func decodeAccount(encoded []byte, proof *AccountProof) error {
+	account, err := (&fastrlp.Parser{}).Parse(encoded)
+	if err != nil {
+		return err
+	}
+
+	if account.Type() != fastrlp.TypeArray || account.Elems() != 4 {
+		return fmt.Errorf("%w: invalid account", errInvalidTrieNode)
+	}
+
+	if proof.Nonce, err = account.Get(0).GetUint64(); err != nil {
+		return fmt.Errorf("%w: invalid account nonce", errInvalidTrieNode)
+	}
+
+	fields := []*types.Hash{&proof.StorageHash, &proof.CodeHash}
+	for i, field := range fields {
+		hash, err := account.Get(i+2).GetBytes(nil, types.HashLength)
+		if err != nil {
+			return fmt.Errorf("%w: invalid account hash", errInvalidTrieNode)
+		}
+
+		*field = types.BytesToHash(hash)
+	}
+
+	proof.Balance = new(big.Int)
+
+	if err := account.Get(1).GetBigInt(proof.Balance); err != nil {
+		return fmt.Errorf("%w: invalid account balance", errInvalidTrieNode)
+	}
+
+	return nil
+}
+
+// decodeStorageValue decodes a storage word of the storage trie
+func decodeStorageValue(encoded []byte) (*big.Int, error) {
+	if encoded == nil {
+		return big.NewInt(0), nil
+	}
+
+	value, err := (&fastrlp.Parser{}).Parse(encoded)
+	if err != nil {
+		return nil, err
+	}
+
+	decoded := new(big.Int)
+
+	if err := value.GetBigInt(decoded); err != nil {
+		return nil, fmt.Errorf("%w: invalid storage value", errInvalidTrieNode)
+	}
+
+	return decoded, nil
+}
+
+// GetProof generates the proof of the account and the given storage slots
+// from the trie nodes, the same way eth_getProof does.
+// Absent accounts and slots get a proof of absence
+func GetProof(
+	nodes NodeReader,
+	stateRoot types.Hash,
+	address types.Address,
+	storageKeys []types.Hash,
+) (*AccountProof, error) {
+	encodedAccount, accountProof, err := walkTrie(nodes, stateRoot, address.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("unable to prove account %s, %w", address, err)
+	}
+
+	proof := &AccountProof{
+		Address:      address,
+		Balance:      big.NewInt(0),
+		StorageHash:  types.EmptyRootHash,
+		CodeHash:     types.BytesToHash(keccak.Keccak256(nil, nil)),
+		AccountProof: accountProof,
+		StorageProof: make([]*StorageProof, 0, len(storageKeys)),
+	}
+
+	if encodedAccount != nil {
+		if err := decodeAccount(encodedAccount, proof); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, key := range storageKeys {
+		encodedValue, storageProof, err := walkTrie(nodes, proof.StorageHash, key.Bytes())
+		if err != nil {
+			return nil, fmt.Errorf("unable to prove storage slot %s, %w", key, err)
+		}
+
+		value, err := decodeStorageValue(encodedValue)
+		if err != nil {
+			return nil, err
+		}
+
+		proof.StorageProof = append(proof.StorageProof, &StorageProof{
+			Key:   key,
+			Value: value,
+			Proof: storageProof,
+		})
+	}
+
+	return proof, nil
+}
+
+// VerifyAccountProof checks the account and storage proofs against the state root
+func VerifyAccountProof(stateRoot types.Hash, proof *AccountProof) error {
+	encodedAccount, _, err := walkTrie(newProofNodes(proof.AccountProof), stateRoot, proof.Address.Bytes())
+	if err != nil {
+		return fmt.Errorf("invalid account proof, %w", err)
+	}
+
+	expected := &AccountProof{
+		Balance:     big.NewInt(0),
+		StorageHash: types.EmptyRootHash,
+		CodeHash:    types.BytesToHash(keccak.Keccak256(nil, nil)),
+	}
+
+	if encodedAccount != nil {
+		if err := decodeAccount(encodedAccount, expected); err != nil {
+			return err
+		}
+	}
+
+	if expected.Nonce != proof.Nonce ||
+		expected.Balance.Cmp(proof.Balance) != 0 ||
+		expected.StorageHash != proof.StorageHash ||
+		expected.CodeHash != proof.CodeHash {
+		return fmt.Errorf("%w: account %s", errProofMismatch, proof.Address)
+	}
+
+	for _, storageProof := range proof.StorageProof {
+		encodedValue, _, err := walkTrie(
+			newProofNodes(storageProof.Proof),
+			proof.StorageHash,
+			storageProof.Key.Bytes(),
+		)
+		if err != nil {
+			return fmt.Errorf("invalid storage proof for slot %s, %w", storageProof.Key, err)
+		}
+
+		value, err := decodeStorageValue(encodedValue)
+		if err != nil {
+			return err
+		}
+
+		if storageProof.Value == nil || value.Cmp(storageProof.Value) != 0 {
+			return fmt.Errorf("%w: storage slot %s", errProofMismatch, storageProof.Key)
+		}
+	}
+
+	return nil
+}
+
+// validatorMembershipKeys returns the storage slots proving the validator membership
+func validatorMembershipKeys(validator types.Address) []types.Hash {
+	return []types.Hash{
+		types.BytesToHash(getAddressMapping(validator, addressToIsValidatorSlot)),
+		types.BytesToHash(getAddressMapping(validator, addressToStakedAmountSlot)),
+	}
+}
+
+// ProveValidatorMembership generates the proof of the _addressToIsValidator
+// and _addressToStakedAmount slots of the validator in the staking SC
+func ProveValidatorMembership(
+	nodes NodeReader,
+	stateRoot types.Hash,
+	stakingAddress types.Address,
+	validator types.Address,
+) (*AccountProof, error) {
+	return GetProof(nodes, stateRoot, stakingAddress, validatorMembershipKeys(validator))
+}
+
+// VerifyValidatorMembership checks a validator membership proof against the state root,
+// and returns whether the address is a validator along with its staked amount
+func VerifyValidatorMembership(
+	stateRoot types.Hash,
+	stakingAddress types.Address,
+	validator types.Address,
+	proof *AccountProof,
+) (bool, *big.Int, error) {
+	if proof.Address != stakingAddress {
+		return false, nil, fmt.Errorf("proof is for account %s, not the staking SC", proof.Address)
+	}
+
+	keys := validatorMembershipKeys(validator)
+	if len(proof.StorageProof) != len(keys) {
+		return false, nil, fmt.Errorf("expected %d storage proofs, got %d", len(keys), len(proof.StorageProof))
+	}
+
+	for i, key := range keys {
+		if proof.StorageProof[i].Key != key {
+			return false, nil, fmt.Errorf("storage proof %d is not for the validator slots", i)
+		}
+	}
+
+	if err := VerifyAccountProof(stateRoot, proof); err != nil {
+		return false, nil, err
+	}
+
+	return proof.StorageProof[0].Value.Sign() != 0, proof.StorageProof[1].Value, nil
+}
+
+// GenesisStateNodes writes the genesis alloc to an in-memory state,
+// and returns its trie nodes together with the state root
+func GenesisStateNodes(genesis *chain.Genesis) (NodeReader, types.Hash) {
+	config := genesis.Config
+	if config == nil {
+		config = &chain.Params{
+			Forks: chain.AllForksEnabled,
+		}
+	}
+
+	storage := itrie.NewMemoryStorage()
+	executor := state.NewExecutor(config, itrie.NewState(storage), hclog.NewNullLogger())
+
+	return storage, executor.WriteGenesis(genesis.Alloc)
+}
@@ -0,0 +1,309 @@
+package staking
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"testing"
+
+	"github.com/0xPolygon/polygon-edge/chain"
+	"github.com/0xPolygon/polygon-edge/helper/keccak"
+	"github.com/0xPolygon/polygon-edge/types"
+	"github.com/umbracle/fastrlp"
+)
+
+// proofTestGenesis returns a genesis with the staking SC and enough funded accounts
+// for the state trie to have branch, extension and leaf nodes
+func proofTestGenesis(t *testing.T, validators []types.Address) *chain.Genesis {
+	t.Helper()
+
+	stakes := make(map[types.Address]*big.Int, len(validators))
+	for i, validator := range validators {
+		stakes[validator] = big.NewInt(int64(100 + i))
+	}
+
+	account, err := PredeployStakingSCWithStakes(validators, stakes, PredeployParams{
+		MinValidatorCount: 1,
+		MaxValidatorCount: uint64(len(validators)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	genesis := &chain.Genesis{
+		Alloc: map[types.Address]*chain.GenesisAccount{
+			DefaultStakingSCAddress: account,
+		},
+	}
+
+	for i := 0; i < 64; i++ {
+		genesis.Alloc[types.StringToAddress(fmt.Sprintf("0x%x", 0x10000+i))] = &chain.GenesisAccount{
+			Balance: big.NewInt(int64(i + 1)),
+		}
+	}
+
+	return genesis
+}
+
+// trieNodeKind returns the kind of an encoded trie node: branch, extension or leaf
+func trieNodeKind(t *testing.T, encoded []byte) string {
+	t.Helper()
+
+	node, err := (&fastrlp.Parser{}).Parse(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if node.Elems() == 17 {
+		return "branch"
+	}
+
+	path, err := node.Get(0).Bytes()
+	if err != nil || len(path) == 0 {
+		t.Fatalf("invalid short node %x", encoded)
+	}
+
+	if path[0]>>4&2 != 0 {
+		return "leaf"
+	}
+
+	return "extension"
+}
+
+func TestValidatorMembershipProof(t *testing.T) {
+	validators := generatorValidators(8)
+	nodes, root := GenesisStateNodes(proofTestGenesis(t, validators))
+
+	kinds := make(map[string]bool)
+	collectKinds := func(proof *AccountProof) {
+		for _, node := range proof.AccountProof {
+			kinds[trieNodeKind(t, node)] = true
+		}
+
+		for _, storageProof := range proof.StorageProof {
+			for _, node := range storageProof.Proof {
+				kinds[trieNodeKind(t, node)] = true
+			}
+		}
+	}
+
+	for i, validator := range validators {
+		proof, err := ProveValidatorMembership(nodes, root, DefaultStakingSCAddress, validator)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		collectKinds(proof)
+
+		isValidator, stake, err := VerifyValidatorMembership(root, DefaultStakingSCAddress, validator, proof)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !isValidator || stake.Cmp(big.NewInt(int64(100+i))) != 0 {
+			t.Fatalf("expected validator %s with stake %d, got %v and %s", validator, 100+i, isValidator, stake)
+		}
+	}
+
+	for _, kind := range []string{"branch", "extension", "leaf"} {
+		if !kinds[kind] {
+			t.Errorf("expected the proofs to go through a %s node", kind)
+		}
+	}
+
+	// A non-member gets a proof of absence of its slots
+	outsider := types.StringToAddress("0xdead")
+
+	proof, err := ProveValidatorMembership(nodes, root, DefaultStakingSCAddress, outsider)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	isValidator, stake, err := VerifyValidatorMembership(root, DefaultStakingSCAddress, outsider, proof)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if isValidator || stake.Sign() != 0 {
+		t.Fatalf("expected a non-member without stake, got %v and %s", isValidator, stake)
+	}
+
+	// A proof for a non-member can't be passed off as the proof of a validator
+	if _, _, err := VerifyValidatorMembership(root, DefaultStakingSCAddress, validators[0], proof); err == nil {
+		t.Fatal("expected the proof of another address to be rejected")
+	}
+
+	// The storage of the staking SC can be read through the trie as well
+	total, err := NewStakingView(NewTrieStateReader(nodes, root, DefaultStakingSCAddress)).TotalStaked()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := big.NewInt(8*100 + 28); total.Cmp(want) != 0 {
+		t.Fatalf("expected total staked amount %s, got %s", want, total)
+	}
+}
+
+func TestValidatorMembershipProofTampered(t *testing.T) {
+	validators := generatorValidators(8)
+	validator := validators[3]
+	nodes, root := GenesisStateNodes(proofTestGenesis(t, validators))
+
+	prove := func() *AccountProof {
+		t.Helper()
+
+		proof, err := ProveValidatorMembership(nodes, root, DefaultStakingSCAddress, validator)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return proof
+	}
+
+	_, otherRoot := GenesisStateNodes(proofTestGenesis(t, validators[:7]))
+
+	cases := []struct {
+		name   string
+		root   types.Hash
+		tamper func(proof *AccountProof)
+		err    error
+	}{
+		{
+			name: "tampered account node",
+			root: root,
+			tamper: func(proof *AccountProof) {
+				last := proof.AccountProof[len(proof.AccountProof)-1]
+				last[len(last)-1] ^= 0x01
+			},
+			err: errMissingTrieNode,
+		},
+		{
+			name: "tampered storage node",
+			root: root,
+			tamper: func(proof *AccountProof) {
+				proof.StorageProof[1].Proof[0][2] ^= 0x01
+			},
+			err: errMissingTrieNode,
+		},
+		{
+			name: "claimed stake",
+			root: root,
+			tamper: func(proof *AccountProof) {
+				proof.StorageProof[1].Value = big.NewInt(1000)
+			},
+			err: errProofMismatch,
+		},
+		{
+			name: "claimed balance",
+			root: root,
+			tamper: func(proof *AccountProof) {
+				proof.Balance = new(big.Int).Add(proof.Balance, big.NewInt(1))
+			},
+			err: errProofMismatch,
+		},
+		{
+			name:   "wrong root",
+			root:   otherRoot,
+			tamper: func(proof *AccountProof) {},
+			err:    errMissingTrieNode,
+		},
+		{
+			name: "truncated account proof",
+			root: root,
+			tamper: func(proof *AccountProof) {
+				proof.AccountProof = proof.AccountProof[:len(proof.AccountProof)-1]
+			},
+			err: errMissingTrieNode,
+		},
+		{
+			name: "truncated storage proof",
+			root: root,
+			tamper: func(proof *AccountProof) {
+				proof.StorageProof[0].Proof = proof.StorageProof[0].Proof[:len(proof.StorageProof[0].Proof)-1]
+			},
+			err: errMissingTrieNode,
+		},
+	}
+
+	for _, c := range cases {
+		c := c
+
+		t.Run(c.name, func(t *testing.T) {
+			proof := prove()
+			c.tamper(proof)
+
+			_, _, err := VerifyValidatorMembership(c.root, DefaultStakingSCAddress, validator, proof)
+			if !errors.Is(err, c.err) {
+				t.Fatalf("expected %v, got %v", c.err, err)
+			}
+		})
+	}
+}
+
+func TestWalkTrieInlineNodes(t *testing.T) {
+	key := types.StringToHash("0x1").Bytes()
+	path := keyNibbles(keccak.Keccak256(nil, key))
+
+	ar := &fastrlp.Arena{}
+
+	// A leaf with an empty path under the last nibble, and the branch holding it,
+	// are both shorter than 32 bytes, so they're embedded in their parents
+	leaf := ar.NewArray()
+	leaf.Set(ar.NewBytes([]byte{0x20}))
+	leaf.Set(ar.NewBytes([]byte{0x05}))
+
+	branch := ar.NewArray()
+
+	for i := 0; i < 17; i++ {
+		if i == int(path[63]) {
+			branch.Set(leaf)
+		} else {
+			branch.Set(ar.NewNull())
+		}
+	}
+
+	if size := len(branch.MarshalTo(nil)); size >= types.HashLength {
+		t.Fatalf("expected an inline branch, got %d bytes", size)
+	}
+
+	// The extension covers the first 63 nibbles, an odd count, behind the 0x1 flag
+	extensionPath := []byte{0x10 | path[0]}
+	for i := 1; i < 63; i += 2 {
+		extensionPath = append(extensionPath, path[i]<<4|path[i+1])
+	}
+
+	extension := ar.NewArray()
+	extension.Set(ar.NewBytes(extensionPath))
+	extension.Set(branch)
+
+	encoded := extension.MarshalTo(nil)
+	root := types.BytesToHash(keccak.Keccak256(nil, encoded))
+	nodes := newProofNodes([][]byte{encoded})
+
+	value, proof, err := walkTrie(nodes, root, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the hash-referenced extension is part of the proof
+	if len(proof) != 1 {
+		t.Fatalf("expected a single proof node, got %d", len(proof))
+	}
+
+	decoded, err := decodeStorageValue(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if decoded.Cmp(big.NewInt(5)) != 0 {
+		t.Fatalf("expected the value 5, got %s", decoded)
+	}
+
+	// Another key ends on an empty branch child, or leaves the extension path
+	for _, other := range []types.Hash{types.StringToHash("0x2"), types.StringToHash("0x3")} {
+		value, _, err := walkTrie(nodes, root, other.Bytes())
+		if err != nil || value != nil {
+			t.Fatalf("expected key %s to be absent, got %x and %v", other, value, err)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package staking
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/0xPolygon/polygon-edge/chain"
+	"github.com/0xPolygon/polygon-edge/helper/hex"
+	"github.com/0xPolygon/polygon-edge/types"
+)
+
+var (
+	// EIP1967ImplementationSlot is the proxy slot holding the implementation address,
+	// keccak256("eip1967.proxy.implementation") - 1
+	EIP1967ImplementationSlot = types.StringToHash(
+		"0x360894a13ba1a3210667c828492db98dca3e2076cc3735a920a3ca505d382bbc",
+	)
+
+	// EIP1967AdminSlot is the proxy slot holding the admin address,
+	// keccak256("eip1967.proxy.admin") - 1
+	EIP1967AdminSlot = types.StringToHash(
+		"0xb53127684a568b3173ae13b9f8a6016e243e63b6e8ee1178d6a717850b5d6103",
+	)
+
+	// DefaultStakingLogicAddress is the address the staking SC logic is deployed to
+	// behind the proxy, when the spec doesn't specify one
+	DefaultStakingLogicAddress = types.StringToAddress("1003")
+)
+
+var (
+	errZeroProxyAdmin    = errors.New("proxy admin can't be the zero address")
+	errProxyLogicAddress = errors.New("proxy and logic addresses must differ")
+)
+
+// TransparentProxyBytecode is the runtime bytecode of a minimal EIP-1967 transparent proxy.
+// Calls from the admin are handled by the proxy itself: admin(), implementation(),
+// upgradeTo(address) and changeAdmin(address). Every other call is delegated to the implementation.
+// It's hand assembled, the source listing is proxySource in proxy_test.go,
+// and TestTransparentProxyBytecodeSource rebuilds it from the listing
+//
+//nolint:lll
+const TransparentProxyBytecode = "0x337fb53127684a568b3173ae13b9f8a6016e243e63b6e8ee1178d6a717850b5d6103541461006d57366000600037600060003660007f360894a13ba1a3210667c828492db98dca3e2076cc3735a920a3ca505d382bbc545af43d600060003e15610068573d6000f35b3d6000fd5b346100ad57600436106100ad5760003560e01c8063f851a440146100b25780635c60da1b146100dd5780633659cfe6146101085780638f2839701461016b575b600080fd5b7fb53127684a568b3173ae13b9f8a6016e243e63b6e8ee1178d6a717850b5d61035460005260206000f35b7f360894a13ba1a3210667c828492db98dca3e2076cc3735a920a3ca505d382bbc5460005260206000f35b60043573ffffffffffffffffffffffffffffffffffffffff16807f360894a13ba1a3210667c828492db98dca3e2076cc3735a920a3ca505d382bbc557fbc7cd75a20ee27fd9adebab32041f755214dbc6bffa90cc0225b39da2e5c2d3b600080a2005b60043573ffffffffffffffffffffffffffffffffffffffff1680156100ad577fb53127684a568b3173ae13b9f8a6016e243e63b6e8ee1178d6a717850b5d610354600052806020527fb53127684a568b3173ae13b9f8a6016e243e63b6e8ee1178d6a717850b5d6103557f7e644d79422f17c01e4894b5f4f588d331ebfa28653d42ae832dc59e38c9798f60406000a100"
+
+// ProxyParams configures the proxy predeploy mode of the staking SC
+type ProxyParams struct {
+	// Admin is the only account able to upgrade the proxy
+	Admin types.Address
+
+	// LogicAddress is the address of the staking SC logic.
+	// DefaultStakingLogicAddress is used if it's not set
+	LogicAddress types.Address
+}
+
+// logicAddress returns the address of the staking SC logic
+func (p *ProxyParams) logicAddress() types.Address {
+	if p.LogicAddress == types.ZeroAddress {
+		return DefaultStakingLogicAddress
+	}
+
+	return p.LogicAddress
+}
+
+// WrapStakingSCInProxy moves the code of the generated staking SC account to a logic account,
+// and turns the account into an EIP-1967 transparent proxy delegating to it.
+// The staking storage and balance stay in the proxy account, which is deployed
+// at the public staking address. The returned account is the logic one
+func WrapStakingSCInProxy(stakingAccount *chain.GenesisAccount, params ProxyParams) (*chain.GenesisAccount, error) {
+	if params.Admin == types.ZeroAddress {
+		return nil, errZeroProxyAdmin
+	}
+
+	proxyCode, _ := hex.DecodeHex(TransparentProxyBytecode)
+
+	logicAccount := &chain.GenesisAccount{
+		Code: stakingAccount.Code,
+	}
+
+	if stakingAccount.Storage == nil {
+		stakingAccount.Storage = make(map[types.Hash]types.Hash)
+	}
+
+	stakingAccount.Code = proxyCode
+
+	// Set the value for the proxy implementation
+	stakingAccount.Storage[EIP1967ImplementationSlot] = types.BytesToHash(params.logicAddress().Bytes())
+
+	// Set the value for the proxy admin
+	stakingAccount.Storage[EIP1967AdminSlot] = types.BytesToHash(params.Admin.Bytes())
+
+	return logicAccount, nil
+}
+
+// ValidateProxyPredeploy executes the validators() and isValidator(address) calls
+// through the staking proxy in a local EVM, and checks that they return the expected validator set
+func ValidateProxyPredeploy(
+	genesis *chain.Genesis,
+	stakingAddress types.Address,
+	expected []types.Address,
+) error {
+	proxyAccount, ok := genesis.Alloc[stakingAddress]
+	if !ok {
+		return fmt.Errorf("staking proxy %s is missing from the genesis alloc", stakingAddress)
+	}
+
+	// The admin calls aren't delegated, so the calls are made from another account
+	admin := types.BytesToAddress(proxyAccount.Storage[EIP1967AdminSlot].Bytes())
+
+	caller := types.StringToAddress("0xca11")
+	if caller == admin {
+		caller = types.StringToAddress("0xca12")
+	}
+
+	evm, err := NewGenesisEVM(genesis)
+	if err != nil {
+		return err
+	}
+
+	var validators []types.Address
+	if err := evm.View(caller, stakingAddress, ValidatorsCall(&validators)); err != nil {
+		return fmt.Errorf("unable to call the staking proxy, %w", err)
+	}
+
+	if len(validators) != len(expected) {
+		return fmt.Errorf("proxy returned %d validators, expected %d", len(validators), len(expected))
+	}
+
+	for i, validator := range validators {
+		if validator != expected[i] {
+			return fmt.Errorf("proxy returned validator %s at index %d, expected %s", validator, i, expected[i])
+		}
+
+		var isValidator bool
+		if err := evm.View(caller, stakingAddress, IsValidatorCall(validator, &isValidator)); err != nil {
+			return fmt.Errorf("unable to call the staking proxy, %w", err)
+		}
+
+		if !isValidator {
+			return fmt.Errorf("proxy doesn't report %s as a validator", validator)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,331 @@
+package staking
+
+import (
+	"encoding/hex"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/0xPolygon/polygon-edge/chain"
+	"github.com/0xPolygon/polygon-edge/helper/keccak"
+	"github.com/0xPolygon/polygon-edge/types"
+)
+
+// The transparent proxy is hand assembled the same way as the mock ERC721 SC,
+// and follows the conventions of erc721Source. The EIP-1967 slots, the selectors
+// and the event topics take their full size. TestTransparentProxyBytecodeSource
+// assembles the listing and checks it against TransparentProxyBytecode
+
+// proxySource returns the listing of the transparent proxy
+func proxySource(t *testing.T) asmProgram {
+	t.Helper()
+
+	addressMask := new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 160), big.NewInt(1))
+	hashOf := func(text string) *big.Int {
+		return new(big.Int).SetBytes(keccak.Keccak256(nil, []byte(text)))
+	}
+
+	implementationSlot := new(big.Int).SetBytes(EIP1967ImplementationSlot.Bytes())
+	adminSlot := new(big.Int).SetBytes(EIP1967AdminSlot.Bytes())
+
+	p := asmProgram{}
+
+	// address loads the first argument as an address
+	address := func() {
+		p.pushUint(4)
+		p.ops(t, "CALLDATALOAD")
+		p.pushFixed(addressMask, 20)
+		p.ops(t, "AND")
+	}
+	revertIf := func() {
+		p.ref("revert")
+		p.ops(t, "JUMPI")
+	}
+	returnWord := func() {
+		p.pushUint(0)
+		p.ops(t, "MSTORE")
+		p.pushUint(0x20)
+		p.pushUint(0)
+		p.ops(t, "RETURN")
+	}
+
+	// Calls from the admin are handled by the proxy, the other ones are delegated
+	p.ops(t, "CALLER")
+	p.pushFixed(adminSlot, 32)
+	p.ops(t, "SLOAD", "EQ")
+	p.ref("admin")
+	p.ops(t, "JUMPI")
+
+	// Fallback: copy the calldata to memory and delegate it to the implementation
+	p.ops(t, "CALLDATASIZE")
+	p.pushUint(0)
+	p.pushUint(0)
+	p.ops(t, "CALLDATACOPY")
+	p.pushUint(0)
+	p.pushUint(0)
+	p.ops(t, "CALLDATASIZE")
+	p.pushUint(0)
+	p.pushFixed(implementationSlot, 32)
+	p.ops(t, "SLOAD", "GAS", "DELEGATECALL")
+
+	// Return or revert with the return data of the implementation
+	p.ops(t, "RETURNDATASIZE")
+	p.pushUint(0)
+	p.pushUint(0)
+	p.ops(t, "RETURNDATACOPY", "ISZERO")
+	p.ref("bubble")
+	p.ops(t, "JUMPI", "RETURNDATASIZE")
+	p.pushUint(0)
+	p.ops(t, "RETURN")
+	p.label("bubble")
+	p.ops(t, "RETURNDATASIZE")
+	p.pushUint(0)
+	p.ops(t, "REVERT")
+
+	// Admin dispatcher, non-payable
+	p.label("admin")
+	p.ops(t, "CALLVALUE")
+	revertIf()
+	p.pushUint(4)
+	p.ops(t, "CALLDATASIZE", "LT")
+	revertIf()
+	p.pushUint(0)
+	p.ops(t, "CALLDATALOAD")
+	p.pushUint(0xe0)
+	p.ops(t, "SHR")
+
+	functions := []struct {
+		signature string
+		label     string
+	}{
+		{"admin()", "getAdmin"},
+		{"implementation()", "getImplementation"},
+		{"upgradeTo(address)", "upgradeTo"},
+		{"changeAdmin(address)", "changeAdmin"},
+	}
+
+	for _, function := range functions {
+		p.ops(t, "DUP1")
+		p.pushFixed(new(big.Int).Rsh(hashOf(function.signature), 224), 4)
+		p.ops(t, "EQ")
+		p.ref(function.label)
+		p.ops(t, "JUMPI")
+	}
+
+	p.label("revert")
+	p.pushUint(0)
+	p.ops(t, "DUP1", "REVERT")
+
+	// admin()
+	p.label("getAdmin")
+	p.pushFixed(adminSlot, 32)
+	p.ops(t, "SLOAD")
+	returnWord()
+
+	// implementation()
+	p.label("getImplementation")
+	p.pushFixed(implementationSlot, 32)
+	p.ops(t, "SLOAD")
+	returnWord()
+
+	// upgradeTo(implementation), emits Upgraded(implementation)
+	p.label("upgradeTo")
+	address()
+	p.ops(t, "DUP1")
+	p.pushFixed(implementationSlot, 32)
+	p.ops(t, "SSTORE")
+	p.pushFixed(hashOf("Upgraded(address)"), 32)
+	p.pushUint(0)
+	p.ops(t, "DUP1", "LOG2", "STOP")
+
+	// changeAdmin(admin), emits AdminChanged(previousAdmin, admin)
+	p.label("changeAdmin")
+	address()
+	p.ops(t, "DUP1", "ISZERO")
+	revertIf()
+	p.pushFixed(adminSlot, 32)
+	p.ops(t, "SLOAD")
+	p.pushUint(0)
+	p.ops(t, "MSTORE", "DUP1")
+	p.pushUint(0x20)
+	p.ops(t, "MSTORE")
+	p.pushFixed(adminSlot, 32)
+	p.ops(t, "SSTORE")
+	p.pushFixed(hashOf("AdminChanged(address,address)"), 32)
+	p.pushUint(0x40)
+	p.pushUint(0)
+	p.ops(t, "LOG1", "STOP")
+
+	return p
+}
+
+func TestTransparentProxyBytecodeSource(t *testing.T) {
+	code := proxySource(t).assemble(t)
+
+	if got := "0x" + hex.EncodeToString(code); got != TransparentProxyBytecode {
+		t.Fatalf("assembled transparent proxy doesn't match TransparentProxyBytecode:\n%s", got)
+	}
+}
+
+func TestEIP1967Slots(t *testing.T) {
+	slotOf := func(name string) types.Hash {
+		slot := new(big.Int).SetBytes(keccak.Keccak256(nil, []byte(name)))
+
+		return types.BytesToHash(slot.Sub(slot, big.NewInt(1)).Bytes())
+	}
+
+	if slot := slotOf("eip1967.proxy.implementation"); slot != EIP1967ImplementationSlot {
+		t.Fatalf("expected the implementation slot %s, got %s", slot, EIP1967ImplementationSlot)
+	}
+
+	if slot := slotOf("eip1967.proxy.admin"); slot != EIP1967AdminSlot {
+		t.Fatalf("expected the admin slot %s, got %s", slot, EIP1967AdminSlot)
+	}
+}
+
+func TestWrapStakingSCInProxy(t *testing.T) {
+	validators := generatorValidators(2)
+	admin := types.StringToAddress("0xad")
+
+	account, err := PredeployStakingSC(validators, PredeployParams{
+		MinValidatorCount: 1,
+		MaxValidatorCount: 2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stakingCode := account.Code
+	stakingStorage := len(account.Storage)
+
+	if _, err := WrapStakingSCInProxy(account, ProxyParams{}); !errors.Is(err, errZeroProxyAdmin) {
+		t.Fatalf("expected the zero admin error, got %v", err)
+	}
+
+	logic, err := WrapStakingSCInProxy(account, ProxyParams{Admin: admin})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if hex.EncodeToString(logic.Code) != hex.EncodeToString(stakingCode) || len(logic.Storage) != 0 {
+		t.Fatal("expected the logic account to hold the staking code only")
+	}
+
+	if "0x"+hex.EncodeToString(account.Code) != TransparentProxyBytecode {
+		t.Fatal("expected the staking account to hold the proxy code")
+	}
+
+	// The staking storage stays in the proxy, next to the EIP-1967 slots
+	if len(account.Storage) != stakingStorage+2 {
+		t.Fatalf("expected %d slots, got %d", stakingStorage+2, len(account.Storage))
+	}
+
+	if account.Storage[EIP1967ImplementationSlot] != types.BytesToHash(DefaultStakingLogicAddress.Bytes()) ||
+		account.Storage[EIP1967AdminSlot] != types.BytesToHash(admin.Bytes()) {
+		t.Fatal("unexpected EIP-1967 slots")
+	}
+}
+
+func TestTransparentProxyEVM(t *testing.T) {
+	validators := generatorValidators(3)
+
+	var (
+		admin    = types.StringToAddress("0xad")
+		caller   = types.StringToAddress("0xca11")
+		upgraded = types.StringToAddress("0x1004")
+	)
+
+	genesis := &chain.Genesis{}
+	if err := ApplyStakingGenesis(genesis, StakingGenesisSpec{
+		Params: PredeployParams{
+			MinValidatorCount: 1,
+			MaxValidatorCount: 3,
+		},
+		Validators: validators,
+		Proxy: &ProxyParams{
+			Admin: admin,
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// A second copy of the staking logic to upgrade to
+	genesis.Alloc[upgraded] = &chain.GenesisAccount{
+		Code: genesis.Alloc[DefaultStakingLogicAddress].Code,
+	}
+
+	evm, err := NewGenesisEVM(genesis)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectValidators := func(t *testing.T) {
+		t.Helper()
+
+		var got []types.Address
+		if err := evm.View(caller, DefaultStakingSCAddress, ValidatorsCall(&got)); err != nil {
+			t.Fatal(err)
+		}
+
+		if len(got) != len(validators) {
+			t.Fatalf("expected %d validators, got %d", len(validators), len(got))
+		}
+
+		for i := range validators {
+			if got[i] != validators[i] {
+				t.Fatalf("validator %d: expected %s, got %s", i, validators[i], got[i])
+			}
+		}
+	}
+
+	implementation := func() types.Hash {
+		slot, _ := evm.Storage(DefaultStakingSCAddress).GetStorage(EIP1967ImplementationSlot)
+
+		return slot
+	}
+
+	// validators() is delegated to the staking logic, which reads the proxy storage
+	expectValidators(t)
+
+	// The admin can't reach the fallback
+	_, err = evm.Call(admin, DefaultStakingSCAddress, encodeCall(selectorValidators))
+	if !errors.Is(err, errEVMCallFailed) {
+		t.Fatalf("expected the admin call to validators() to revert, got %v", err)
+	}
+
+	output, err := evm.Call(admin, DefaultStakingSCAddress, encodeCall(proxySelector("implementation()")))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if types.BytesToHash(output) != types.BytesToHash(DefaultStakingLogicAddress.Bytes()) {
+		t.Fatalf("expected the implementation %s, got %x", DefaultStakingLogicAddress, output)
+	}
+
+	// upgradeTo from another account is delegated, and doesn't touch the implementation slot
+	upgradeTo := encodeCall(proxySelector("upgradeTo(address)"), upgraded.Bytes())
+
+	if _, err := evm.Call(caller, DefaultStakingSCAddress, upgradeTo); err == nil {
+		t.Fatal("expected the delegated upgradeTo call to revert")
+	}
+
+	if slot := implementation(); slot != types.BytesToHash(DefaultStakingLogicAddress.Bytes()) {
+		t.Fatalf("expected the implementation slot to be unchanged, got %s", slot)
+	}
+
+	// upgradeTo from the admin writes the implementation slot
+	if _, err := evm.Call(admin, DefaultStakingSCAddress, upgradeTo); err != nil {
+		t.Fatal(err)
+	}
+
+	if slot := implementation(); slot != types.BytesToHash(upgraded.Bytes()) {
+		t.Fatalf("expected the implementation slot to hold %s, got %s", upgraded, slot)
+	}
+
+	expectValidators(t)
+}
+
+// proxySelector returns the selector of the proxy function
+func proxySelector(signature string) []byte {
+	return keccak.Keccak256(nil, []byte(signature))[:4]
+}
@@ -0,0 +1,222 @@
+package staking
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/0xPolygon/polygon-edge/chain"
+	"github.com/0xPolygon/polygon-edge/helper/hex"
+	"github.com/0xPolygon/polygon-edge/state"
+	"github.com/0xPolygon/polygon-edge/types"
+)
+
+// StateReader reads the storage words of the staking SC
+type StateReader interface {
+	// GetStorage returns the storage word at the given slot.
+	// Missing slots are read as the zero word
+	GetStorage(slot types.Hash) (types.Hash, error)
+}
+
+// StorageMap is a StateReader over an in-memory storage, like the one of a genesis account
+type StorageMap map[types.Hash]types.Hash
+
+// GetStorage implements the StateReader interface
+func (s StorageMap) GetStorage(slot types.Hash) (types.Hash, error) {
+	return s[slot], nil
+}
+
+// readWord reads the storage word at the given index as an integer
+func readWord(reader StateReader, index []byte) (*big.Int, error) {
+	value, err := reader.GetStorage(types.BytesToHash(index))
+	if err != nil {
+		return nil, err
+	}
+
+	return new(big.Int).SetBytes(value.Bytes()), nil
+}
+
+// NewGenesisAccountReader creates a StateReader over the storage of a genesis account
+func NewGenesisAccountReader(account *chain.GenesisAccount) StateReader {
+	return StorageMap(account.Storage)
+}
+
+// TrieStateReader is a StateReader over the state trie at a given root,
+// like the state database of a polygon-edge node
+type TrieStateReader struct {
+	nodes     NodeReader
+	stateRoot types.Hash
+	address   types.Address
+
+	storageRoot *types.Hash
+}
+
+// NewTrieStateReader creates a StateReader for the account storage in the state trie
+// with the given root. The storage of polygon-edge's immutable trie can be used as the NodeReader
+func NewTrieStateReader(nodes NodeReader, stateRoot types.Hash, address types.Address) *TrieStateReader {
+	return &TrieStateReader{
+		nodes:     nodes,
+		stateRoot: stateRoot,
+		address:   address,
+	}
+}
+
+// StorageRoot returns the storage root of the account
+func (r *TrieStateReader) StorageRoot() (types.Hash, error) {
+	if r.storageRoot != nil {
+		return *r.storageRoot, nil
+	}
+
+	encodedAccount, _, err := walkTrie(r.nodes, r.stateRoot, r.address.Bytes())
+	if err != nil {
+		return types.ZeroHash, fmt.Errorf("unable to read account %s, %w", r.address, err)
+	}
+
+	account := &AccountProof{
+		StorageHash: types.EmptyRootHash,
+	}
+
+	if encodedAccount != nil {
+		if err := decodeAccount(encodedAccount, account); err != nil {
+			return types.ZeroHash, err
+		}
+	}
+
+	r.storageRoot = &account.StorageHash
+
+	return account.StorageHash, nil
+}
+
+// GetStorage implements the StateReader interface
+func (r *TrieStateReader) GetStorage(slot types.Hash) (types.Hash, error) {
+	storageRoot, err := r.StorageRoot()
+	if err != nil {
+		return types.ZeroHash, err
+	}
+
+	encodedValue, _, err := walkTrie(r.nodes, storageRoot, slot.Bytes())
+	if err != nil {
+		return types.ZeroHash, fmt.Errorf("unable to read storage slot %s, %w", slot, err)
+	}
+
+	value, err := decodeStorageValue(encodedValue)
+	if err != nil {
+		return types.ZeroHash, err
+	}
+
+	return types.BytesToHash(value.Bytes()), nil
+}
+
+// SnapshotStorage is the part of polygon-edge's state.Snapshot read by the SnapshotStateReader
+type SnapshotStorage interface {
+	GetAccount(addr types.Address) (*state.Account, error)
+	GetStorage(addr types.Address, root types.Hash, key types.Hash) types.Hash
+}
+
+// SnapshotStateReader is a StateReader over a polygon-edge state snapshot,
+// like the one of the executor at a given state root
+type SnapshotStateReader struct {
+	snapshot SnapshotStorage
+	address  types.Address
+
+	storageRoot *types.Hash
+}
+
+// NewSnapshotStateReader creates a StateReader for the account storage in the state snapshot
+func NewSnapshotStateReader(snapshot SnapshotStorage, address types.Address) *SnapshotStateReader {
+	return &SnapshotStateReader{
+		snapshot: snapshot,
+		address:  address,
+	}
+}
+
+// StorageRoot returns the storage root of the account,
+// the empty root if the account doesn't exist
+func (r *SnapshotStateReader) StorageRoot() (types.Hash, error) {
+	if r.storageRoot != nil {
+		return *r.storageRoot, nil
+	}
+
+	account, err := r.snapshot.GetAccount(r.address)
+	if err != nil {
+		return types.ZeroHash, fmt.Errorf("unable to read account %s, %w", r.address, err)
+	}
+
+	storageRoot := types.EmptyRootHash
+	if account != nil {
+		storageRoot = account.Root
+	}
+
+	r.storageRoot = &storageRoot
+
+	return storageRoot, nil
+}
+
+// GetStorage implements the StateReader interface
+func (r *SnapshotStateReader) GetStorage(slot types.Hash) (types.Hash, error) {
+	storageRoot, err := r.StorageRoot()
+	if err != nil {
+		return types.ZeroHash, err
+	}
+
+	if storageRoot == types.EmptyRootHash {
+		return types.ZeroHash, nil
+	}
+
+	return r.snapshot.GetStorage(r.address, storageRoot, slot), nil
+}
+
+// StateReaderFunc is a StateReader over a function reading the storage words
+type StateReaderFunc func(slot types.Hash) (types.Hash, error)
+
+// GetStorage implements the StateReader interface
+func (f StateReaderFunc) GetStorage(slot types.Hash) (types.Hash, error) {
+	return f(slot)
+}
+
+// RPCStateReader reads the account storage over the eth_getStorageAt method of a JSON-RPC endpoint.
+// Every read takes its own context, and Reader binds one to a StateReader for a single request
+type RPCStateReader struct {
+	transport *jsonRPCTransport
+	address   types.Address
+	block     string
+}
+
+// NewRPCStateReader creates a reader for the account storage at the given block,
+// read from the JSON-RPC endpoint. A nil block reads the latest state
+func NewRPCStateReader(endpoint string, address types.Address, block *uint64) *RPCStateReader {
+	return &RPCStateReader{
+		transport: newJSONRPCTransport(endpoint, nil),
+		address:   address,
+		block:     blockParam(block),
+	}
+}
+
+// Reader returns a StateReader whose reads are bound to the context.
+// It's meant to be created per request, so canceling the context only aborts the reads of that request
+func (r *RPCStateReader) Reader(ctx context.Context) StateReader {
+	return StateReaderFunc(func(slot types.Hash) (types.Hash, error) {
+		return r.GetStorageAt(ctx, slot)
+	})
+}
+
+// GetStorageAt returns the storage word at the given slot, read within the context
+func (r *RPCStateReader) GetStorageAt(ctx context.Context, slot types.Hash) (types.Hash, error) {
+	var value string
+
+	if err := r.transport.call(
+		ctx,
+		"eth_getStorageAt",
+		[]interface{}{r.address, slot, r.block},
+		&value,
+	); err != nil {
+		return types.ZeroHash, err
+	}
+
+	raw, err := hex.DecodeHex(value)
+	if err != nil {
+		return types.ZeroHash, fmt.Errorf("invalid storage value %q, %w", value, err)
+	}
+
+	return types.BytesToHash(raw), nil
+}
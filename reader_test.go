@@ -0,0 +1,131 @@
+package staking
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/0xPolygon/polygon-edge/state"
+	"github.com/0xPolygon/polygon-edge/types"
+)
+
+// mapSnapshot is a SnapshotStorage with a single storage trie per root
+type mapSnapshot struct {
+	accounts map[types.Address]*state.Account
+	storage  map[types.Hash]map[types.Hash]types.Hash
+}
+
+func (s *mapSnapshot) GetAccount(addr types.Address) (*state.Account, error) {
+	return s.accounts[addr], nil
+}
+
+func (s *mapSnapshot) GetStorage(addr types.Address, root types.Hash, key types.Hash) types.Hash {
+	return s.storage[root][key]
+}
+
+func TestSnapshotStateReader(t *testing.T) {
+	validators := generatorValidators(3)
+
+	account, err := PredeployStakingSC(validators, PredeployParams{
+		MinValidatorCount: 1,
+		MaxValidatorCount: 3,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	root := types.StringToHash("0x1234")
+	snapshot := &mapSnapshot{
+		accounts: map[types.Address]*state.Account{
+			DefaultStakingSCAddress: {Root: root},
+		},
+		storage: map[types.Hash]map[types.Hash]types.Hash{
+			root: account.Storage,
+		},
+	}
+
+	got, err := NewStakingView(NewSnapshotStateReader(snapshot, DefaultStakingSCAddress)).Validators()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(got) != len(validators) {
+		t.Fatalf("expected %d validators, got %d", len(validators), len(got))
+	}
+
+	for i := range validators {
+		if got[i] != validators[i] {
+			t.Errorf("validator %d: expected %s, got %s", i, validators[i], got[i])
+		}
+	}
+
+	// A missing account has an empty storage
+	missing, err := NewStakingView(NewSnapshotStateReader(snapshot, DefaultNFTSCAddress)).Validators()
+	if err != nil || len(missing) != 0 {
+		t.Fatalf("expected no validators for a missing account, got %v, %v", missing, err)
+	}
+}
+
+func TestRPCStateReaderContext(t *testing.T) {
+	stake := types.BytesToHash(big.NewInt(10).Bytes())
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request jsonRPCRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Errorf("unable to decode the request, %v", err)
+
+			return
+		}
+
+		if request.Method != "eth_getStorageAt" {
+			t.Errorf("unexpected method %s", request.Method)
+		}
+
+		result, _ := json.Marshal(stake.String())
+		if err := json.NewEncoder(w).Encode(jsonRPCResponse{ID: request.ID, Result: result}); err != nil {
+			t.Errorf("unable to encode the response, %v", err)
+		}
+	}))
+	defer server.Close()
+
+	rpcReader := NewRPCStateReader(server.URL, DefaultStakingSCAddress, nil)
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := rpcReader.Reader(canceled).GetStorage(types.ZeroHash); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected the canceled context error, got %v", err)
+	}
+
+	// A canceled request doesn't affect the later ones
+	value, err := rpcReader.Reader(context.Background()).GetStorage(types.ZeroHash)
+	if err != nil {
+		t.Fatalf("unable to read the storage, %v", err)
+	}
+
+	if value != stake {
+		t.Fatalf("expected %s, got %s", stake, value)
+	}
+
+	if value, err = rpcReader.GetStorageAt(context.Background(), types.ZeroHash); err != nil || value != stake {
+		t.Fatalf("expected %s, got %s, %v", stake, value, err)
+	}
+}
+
+func TestStakingViewValidatorCountLimit(t *testing.T) {
+	storage := StorageMap{
+		types.BytesToHash(big.NewInt(validatorsSlot).Bytes()): types.BytesToHash(
+			new(big.Int).SetUint64(MaxViewValidatorCount + 1).Bytes(),
+		),
+	}
+
+	_, err := NewStakingView(storage).Validators()
+	if err == nil || !strings.Contains(err.Error(), "above the limit") {
+		t.Fatalf("expected the validator count limit error, got %v", err)
+	}
+}
@@ -0,0 +1,472 @@
+package staking
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+
+	"github.com/0xPolygon/polygon-edge/chain"
+	"github.com/0xPolygon/polygon-edge/types"
+)
+
+// RegenesisOptions are the inputs of ExportRegenesis that can't be read from the SC storage
+type RegenesisOptions struct {
+	// StakingAddress is the address of the staking SC in the new genesis.
+	// DefaultStakingSCAddress is used if it's not set
+	StakingAddress types.Address
+
+	// TokenIDs are the candidate token IDs. The token ID -> staker mapping
+	// can't be enumerated from the storage, so the tokens have to be
+	// discovered elsewhere, for example from the indexed Staked events
+	TokenIDs []*big.Int
+
+	// NFTReader reads the storage of the ERC721 SC at the same block.
+	// The candidate tokens that aren't staked are exported with their holders.
+	// If it's not set, the exported ERC721 account only holds the staked tokens
+	NFTReader StateReader
+
+	// Balance is the native balance of the staking SC, which isn't in its storage.
+	// If it's not set, the exported account keeps the balance derived from the spec
+	Balance *big.Int
+
+	// StorageMode is the storage mode of the exported account
+	StorageMode StorageMode
+}
+
+// RegenesisIssue is a piece of the live staking state that isn't carried over as is
+type RegenesisIssue struct {
+	Address types.Address
+	Reason  string
+}
+
+func (i RegenesisIssue) String() string {
+	if i.Address == types.ZeroAddress {
+		return i.Reason
+	}
+
+	return fmt.Sprintf("%s: %s", i.Address, i.Reason)
+}
+
+// RegenesisReport summarizes the export of the live staking state
+type RegenesisReport struct {
+	Validators   int
+	StakedTokens int
+	HeldTokens   int
+	Issues       []RegenesisIssue
+}
+
+func (r *RegenesisReport) addIssue(address types.Address, format string, args ...interface{}) {
+	r.Issues = append(r.Issues, RegenesisIssue{
+		Address: address,
+		Reason:  fmt.Sprintf(format, args...),
+	})
+}
+
+// String returns a human readable report
+func (r *RegenesisReport) String() string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "validators: %d\n", r.Validators)
+	fmt.Fprintf(&b, "staked tokens: %d\n", r.StakedTokens)
+	fmt.Fprintf(&b, "held tokens: %d\n", r.HeldTokens)
+
+	if len(r.Issues) == 0 {
+		b.WriteString("every value was carried over\n")
+
+		return b.String()
+	}
+
+	fmt.Fprintf(&b, "issues: %d\n", len(r.Issues))
+
+	for _, issue := range r.Issues {
+		fmt.Fprintf(&b, "  - %s\n", issue)
+	}
+
+	return b.String()
+}
+
+// RegenesisExport is the live staking state, converted to the inputs of a new genesis
+type RegenesisExport struct {
+	// Spec holds the inputs of BuildStakingGenesis.
+	// With staked tokens the stakes are the token counts, which BuildStakingGenesis derives,
+	// so the spec has no stakes
+	Spec StakingGenesisSpec
+
+	// Stakes, Weights and StakedAmount are the live values, written over the ones
+	// BuildStakingGenesis derives from the spec
+	Stakes       map[types.Address]*big.Int
+	Weights      map[types.Address]*big.Int
+	StakedAmount *big.Int
+
+	// Account is the finished staking SC genesis account
+	Account *chain.GenesisAccount
+
+	// NFTAccount is the finished ERC721 SC genesis account, if the spec contains NFTs
+	NFTAccount *chain.GenesisAccount
+
+	// Report lists anything that couldn't be carried over
+	Report *RegenesisReport
+}
+
+// ExportRegenesis reads the staking SC storage and converts it into the inputs of a new genesis,
+// preserving the _validators order, the mappings, the total staked amount and the bounds.
+// The reader can be a TrieStateReader over a local state database, or the Reader of an
+// RPCStateReader at the chosen block.
+// The holders of the candidate tokens are read from the ERC721 SC storage, if it's given.
+// Token approvals can't be enumerated, so they aren't carried over.
+// The exported accounts are read back and compared with the live storage,
+// and every difference ends up in the report. Only validators are carried over,
+// so the stake of any other staker, still counted in the total, is reported as well
+func ExportRegenesis(reader StateReader, options RegenesisOptions) (*RegenesisExport, error) {
+	view := NewStakingView(reader)
+	report := &RegenesisReport{}
+
+	validators, err := view.Validators()
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the validators, %w", err)
+	}
+
+	bounds, err := view.Bounds()
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the validator count bounds, %w", err)
+	}
+
+	nftAddress, err := view.NFTAddress()
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the NFT address, %w", err)
+	}
+
+	stakedAmount, err := view.TotalStaked()
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the total staked amount, %w", err)
+	}
+
+	report.Validators = len(validators)
+
+	export := &RegenesisExport{
+		Spec: StakingGenesisSpec{
+			StakingAddress: options.StakingAddress,
+			Params: PredeployParams{
+				MinValidatorCount: bounds.MinValidatorCount,
+				MaxValidatorCount: bounds.MaxValidatorCount,
+				Ordering:          OrderByInput,
+				StorageMode:       options.StorageMode,
+			},
+			Validators:   validators,
+			NFTAddress:   nftAddress,
+			StakedTokens: make(map[types.Address][]*big.Int),
+			NFTHolders:   make(map[types.Address][]*big.Int),
+		},
+		Stakes:       make(map[types.Address]*big.Int, len(validators)),
+		Weights:      make(map[types.Address]*big.Int, len(validators)),
+		StakedAmount: stakedAmount,
+		Report:       report,
+	}
+
+	if count := uint64(len(validators)); count < bounds.MinValidatorCount || count > bounds.MaxValidatorCount {
+		report.addIssue(
+			types.ZeroAddress,
+			"%d validators are out of the bounds [%d, %d]",
+			count,
+			bounds.MinValidatorCount,
+			bounds.MaxValidatorCount,
+		)
+	}
+
+	isValidator := make(map[types.Address]bool, len(validators))
+	for _, validator := range validators {
+		isValidator[validator] = true
+	}
+
+	// Find the stakers or the holders of the candidate tokens
+	seenTokens := make(map[string]bool, len(options.TokenIDs))
+
+	for _, tokenID := range options.TokenIDs {
+		if tokenID == nil || tokenID.Sign() < 0 || seenTokens[tokenID.String()] {
+			continue
+		}
+
+		seenTokens[tokenID.String()] = true
+
+		staker, err := view.TokenOwner(tokenID)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read the staker of token %s, %w", tokenID, err)
+		}
+
+		if staker == types.ZeroAddress {
+			if err := export.addHeldToken(options.NFTReader, tokenID); err != nil {
+				return nil, err
+			}
+
+			continue
+		}
+
+		if !isValidator[staker] {
+			report.addIssue(staker, "token %s is staked by an address that isn't a validator", tokenID)
+
+			continue
+		}
+
+		export.Spec.StakedTokens[staker] = append(export.Spec.StakedTokens[staker], tokenID)
+		report.StakedTokens++
+	}
+
+	// With staked tokens, the spec derives the stakes from the token counts
+	if !export.Spec.tokenStaking() {
+		export.Spec.Stakes = make(map[types.Address]*big.Int, len(validators))
+	}
+
+	validatorStake := big.NewInt(0)
+
+	for _, validator := range validators {
+		stake, err := view.StakeOf(validator)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read the stake of %s, %w", validator, err)
+		}
+
+		weight, err := view.WeightOf(validator)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read the weight of %s, %w", validator, err)
+		}
+
+		tokens := export.Spec.StakedTokens[validator]
+		sort.Slice(tokens, func(i, j int) bool {
+			return tokens[i].Cmp(tokens[j]) < 0
+		})
+
+		validatorStake.Add(validatorStake, stake)
+		export.Stakes[validator] = stake
+
+		if export.Spec.Stakes != nil {
+			export.Spec.Stakes[validator] = stake
+		} else if stake.Cmp(big.NewInt(int64(len(tokens)))) != 0 {
+			report.addIssue(
+				validator,
+				"stake %s isn't the count of its %d known staked tokens, which the spec stakes",
+				stake,
+				len(tokens),
+			)
+		}
+
+		derivedWeight := big.NewInt(0)
+		for _, tokenID := range tokens {
+			derivedWeight.Add(derivedWeight, getNFTWeight(tokenID))
+		}
+
+		if derivedWeight.Cmp(weight) != 0 {
+			report.addIssue(
+				validator,
+				"weight %s doesn't match the known staked tokens (weight %s), some staked tokens are missing",
+				weight,
+				derivedWeight,
+			)
+		}
+
+		export.Weights[validator] = weight
+	}
+
+	// The storage can't enumerate the stakers, so the stake held outside of the validator set
+	// is only visible as the part of the total that the validators don't account for
+	switch unaccounted := new(big.Int).Sub(stakedAmount, validatorStake); unaccounted.Sign() {
+	case 1:
+		report.addIssue(
+			types.ZeroAddress,
+			"%s of the total staked amount %s is staked by addresses that aren't validators, and isn't carried over",
+			unaccounted,
+			stakedAmount,
+		)
+	case -1:
+		report.addIssue(
+			types.ZeroAddress,
+			"the total staked amount %s is lower than the %s staked by the validators",
+			stakedAmount,
+			validatorStake,
+		)
+	}
+
+	if err := export.buildAccounts(); err != nil {
+		return nil, err
+	}
+
+	if options.Balance != nil {
+		if options.Balance.Cmp(export.Account.Balance) != 0 {
+			report.addIssue(
+				types.ZeroAddress,
+				"balance %s isn't the %s derived from the spec, only the exported account carries it over",
+				options.Balance,
+				export.Account.Balance,
+			)
+		}
+
+		export.Account.Balance = new(big.Int).Set(options.Balance)
+	}
+
+	if err := export.compare(reader, options.NFTReader); err != nil {
+		return nil, err
+	}
+
+	return export, nil
+}
+
+// addHeldToken adds a token that isn't staked to the spec, under the holder read from the ERC721 SC storage
+func (e *RegenesisExport) addHeldToken(nftReader StateReader, tokenID *big.Int) error {
+	if nftReader == nil {
+		return nil
+	}
+
+	holder, err := readWord(nftReader, getUint256Mapping(tokenID, erc721OwnersSlot))
+	if err != nil {
+		return fmt.Errorf("unable to read the holder of token %s, %w", tokenID, err)
+	}
+
+	if holder.Sign() == 0 {
+		return nil
+	}
+
+	address := types.BytesToAddress(holder.Bytes())
+
+	e.Spec.NFTHolders[address] = append(e.Spec.NFTHolders[address], tokenID)
+	e.Report.HeldTokens++
+
+	return nil
+}
+
+// buildAccounts generates the genesis accounts from the spec,
+// and writes the live stakes, weights and total over the staking SC account
+func (e *RegenesisExport) buildAccounts() error {
+	accounts, err := BuildStakingGenesis(e.Spec, nil)
+	if err != nil {
+		return fmt.Errorf("unable to build the exported genesis, %w", err)
+	}
+
+	account := accounts[e.Spec.stakingAddress()]
+
+	for validator, stake := range e.Stakes {
+		// Set the value for the address -> staked amount mapping
+		account.Storage[types.BytesToHash(getAddressMapping(validator, addressToStakedAmountSlot))] =
+			types.BytesToHash(stake.Bytes())
+	}
+
+	for validator, weight := range e.Weights {
+		// Set the value for the address -> weight mapping
+		account.Storage[types.BytesToHash(getAddressMapping(validator, addressToWeightSlot))] =
+			types.BytesToHash(weight.Bytes())
+	}
+
+	// Set the value for the total staked amount
+	account.Storage[types.BytesToHash(big.NewInt(stakedAmountSlot).Bytes())] =
+		types.BytesToHash(e.StakedAmount.Bytes())
+
+	if account.Storage, err = applyStorageMode(account.Storage, e.Spec.Params.StorageMode); err != nil {
+		return err
+	}
+
+	e.Account = account
+
+	if e.Spec.hasNFTs() {
+		e.NFTAccount = accounts[e.Spec.nftAddress()]
+	}
+
+	return nil
+}
+
+// compare reads every slot of the exported accounts from the live storage,
+// and reports the ones holding different values
+func (e *RegenesisExport) compare(reader, nftReader StateReader) error {
+	// Every slot of the live state the export depends on
+	slots := make(map[types.Hash]string)
+
+	slots[types.BytesToHash(big.NewInt(validatorsSlot).Bytes())] = "validator count"
+	slots[types.BytesToHash(big.NewInt(stakedAmountSlot).Bytes())] = "total staked amount"
+	slots[types.BytesToHash(big.NewInt(minNumValidatorSlot).Bytes())] = "minimum validator count"
+	slots[types.BytesToHash(big.NewInt(maxNumValidatorSlot).Bytes())] = "maximum validator count"
+	slots[types.BytesToHash(big.NewInt(nftAddressSlot).Bytes())] = "NFT address"
+
+	for indx, validator := range e.Spec.Validators {
+		storageIndexes := getStorageIndexes(validator, int64(indx))
+
+		slots[types.BytesToHash(storageIndexes.ValidatorsIndex)] = fmt.Sprintf("_validators[%d]", indx)
+		slots[types.BytesToHash(storageIndexes.AddressToIsValidatorIndex)] =
+			fmt.Sprintf("_addressToIsValidator[%s]", validator)
+		slots[types.BytesToHash(storageIndexes.AddressToStakedAmountIndex)] =
+			fmt.Sprintf("_addressToStakedAmount[%s]", validator)
+		slots[types.BytesToHash(storageIndexes.AddressToValidatorIndexIndex)] =
+			fmt.Sprintf("_addressToValidatorIndex[%s]", validator)
+		slots[types.BytesToHash(getAddressMapping(validator, addressToWeightSlot))] =
+			fmt.Sprintf("weight[%s]", validator)
+
+		for _, tokenID := range e.Spec.StakedTokens[validator] {
+			slots[types.BytesToHash(getUint256Mapping(tokenID, tokenIDToOwnerSlot))] =
+				fmt.Sprintf("staker[%s]", tokenID)
+		}
+	}
+
+	if err := e.compareSlots(reader, e.Account.Storage, slots); err != nil {
+		return err
+	}
+
+	if nftReader == nil || e.NFTAccount == nil {
+		return nil
+	}
+
+	// Every slot of the live ERC721 state the export depends on
+	nftSlots := make(map[types.Hash]string)
+	owners := []types.Address{e.Spec.stakingAddress()}
+
+	addTokens := func(tokens map[types.Address][]*big.Int) {
+		for _, tokenIDs := range tokens {
+			for _, tokenID := range tokenIDs {
+				nftSlots[types.BytesToHash(getUint256Mapping(tokenID, erc721OwnersSlot))] =
+					fmt.Sprintf("ERC721 ownerOf[%s]", tokenID)
+			}
+		}
+	}
+
+	addTokens(e.Spec.StakedTokens)
+	addTokens(e.Spec.NFTHolders)
+
+	owners = append(owners, sortedTokenOwners(e.Spec.NFTHolders)...)
+	for _, owner := range owners {
+		nftSlots[types.BytesToHash(getAddressMapping(owner, erc721BalancesSlot))] =
+			fmt.Sprintf("ERC721 balanceOf[%s]", owner)
+	}
+
+	return e.compareSlots(nftReader, e.NFTAccount.Storage, nftSlots)
+}
+
+// compareSlots reads the named slots from the live storage, and reports
+// the ones holding a different value in the exported storage
+func (e *RegenesisExport) compareSlots(
+	reader StateReader,
+	storage map[types.Hash]types.Hash,
+	slots map[types.Hash]string,
+) error {
+	keys := make([]types.Hash, 0, len(slots))
+	for key := range slots {
+		keys = append(keys, key)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].String() < keys[j].String()
+	})
+
+	for _, key := range keys {
+		live, err := reader.GetStorage(key)
+		if err != nil {
+			return fmt.Errorf("unable to read storage slot %s, %w", key, err)
+		}
+
+		if exported := storage[key]; exported != live {
+			e.Report.addIssue(
+				types.ZeroAddress,
+				"%s is exported as %s instead of %s",
+				slots[key],
+				exported,
+				live,
+			)
+		}
+	}
+
+	return nil
+}
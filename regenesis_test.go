@@ -0,0 +1,231 @@
+package staking
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/0xPolygon/polygon-edge/chain"
+	"github.com/0xPolygon/polygon-edge/types"
+)
+
+func TestExportRegenesisUnaccountedStake(t *testing.T) {
+	validators := generatorValidators(2)
+	outsider := types.StringToAddress("0xdead")
+
+	live, err := PredeployStakingSCWithStakes(validators, map[types.Address]*big.Int{
+		validators[0]: big.NewInt(10),
+		validators[1]: big.NewInt(20),
+	}, PredeployParams{
+		MinValidatorCount: 1,
+		MaxValidatorCount: 2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	export, err := ExportRegenesis(StorageMap(live.Storage), RegenesisOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(export.Report.Issues) != 0 {
+		t.Fatalf("expected no issues, got %s", export.Report)
+	}
+
+	// A staker that isn't a validator, which the storage can't enumerate
+	live.Storage[types.BytesToHash(getAddressMapping(outsider, addressToStakedAmountSlot))] =
+		types.BytesToHash(big.NewInt(5).Bytes())
+	live.Storage[types.BytesToHash(big.NewInt(stakedAmountSlot).Bytes())] =
+		types.BytesToHash(big.NewInt(35).Bytes())
+
+	export, err = ExportRegenesis(StorageMap(live.Storage), RegenesisOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(export.Report.Issues) != 1 ||
+		!strings.Contains(export.Report.Issues[0].Reason, "5 of the total staked amount 35") {
+		t.Fatalf("expected the unaccounted stake to be reported, got %s", export.Report)
+	}
+}
+
+// regenesisLive builds the live staking and ERC721 accounts of the spec
+func regenesisLive(t *testing.T, spec StakingGenesisSpec) (*chain.GenesisAccount, *chain.GenesisAccount) {
+	t.Helper()
+
+	accounts, err := BuildStakingGenesis(spec, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return accounts[spec.stakingAddress()], accounts[spec.nftAddress()]
+}
+
+func TestExportRegenesisRoundTrip(t *testing.T) {
+	validators := generatorValidators(3)
+	holder := types.StringToAddress("0xbeef")
+
+	tokenStaking := StakingGenesisSpec{
+		Params: PredeployParams{
+			MinValidatorCount: 1,
+			MaxValidatorCount: 5,
+			Ordering:          OrderByStake,
+		},
+		Validators: validators,
+		StakedTokens: map[types.Address][]*big.Int{
+			validators[0]: {big.NewInt(4), big.NewInt(9)},
+			validators[1]: {big.NewInt(2)},
+			validators[2]: {big.NewInt(5), big.NewInt(6), big.NewInt(7)},
+		},
+		NFTHolders: map[types.Address][]*big.Int{
+			holder: {big.NewInt(1), big.NewInt(3)},
+		},
+	}
+
+	weiStaking := StakingGenesisSpec{
+		Params: PredeployParams{
+			MinValidatorCount: 1,
+			MaxValidatorCount: 5,
+		},
+		Validators: validators,
+		Stakes: map[types.Address]*big.Int{
+			validators[0]: big.NewInt(30),
+			validators[2]: big.NewInt(1),
+		},
+	}
+
+	cases := []struct {
+		name         string
+		spec         StakingGenesisSpec
+		stakedTokens int
+		heldTokens   int
+	}{
+		{"token stakes", tokenStaking, 6, 2},
+		{"wei stakes", weiStaking, 0, 0},
+	}
+
+	for _, c := range cases {
+		c := c
+
+		t.Run(c.name, func(t *testing.T) {
+			live, liveNFT := regenesisLive(t, c.spec)
+
+			options := RegenesisOptions{
+				TokenIDs: []*big.Int{big.NewInt(100), big.NewInt(4)},
+				Balance:  live.Balance,
+			}
+
+			for i := int64(1); i <= 9; i++ {
+				options.TokenIDs = append(options.TokenIDs, big.NewInt(i))
+			}
+
+			if liveNFT != nil {
+				options.NFTReader = StorageMap(liveNFT.Storage)
+			}
+
+			export, err := ExportRegenesis(StorageMap(live.Storage), options)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(export.Report.Issues) != 0 {
+				t.Fatalf("expected no issues, got %s", export.Report)
+			}
+
+			if export.Report.StakedTokens != c.stakedTokens || export.Report.HeldTokens != c.heldTokens {
+				t.Fatalf(
+					"expected %d staked and %d held tokens, got %s",
+					c.stakedTokens,
+					c.heldTokens,
+					export.Report,
+				)
+			}
+
+			// The exported spec builds the live accounts again
+			rebuilt, err := BuildStakingGenesis(export.Spec, nil)
+			if err != nil {
+				t.Fatalf("unable to build the exported spec, %v", err)
+			}
+
+			staking := rebuilt[DefaultStakingSCAddress]
+			if !StorageEqual(staking.Storage, live.Storage) || !StorageEqual(export.Account.Storage, live.Storage) {
+				t.Fatal("the staking SC storage isn't carried over")
+			}
+
+			if staking.Balance.Cmp(live.Balance) != 0 || export.Account.Balance.Cmp(live.Balance) != 0 {
+				t.Fatalf("expected the balance %s, got %s and %s", live.Balance, staking.Balance, export.Account.Balance)
+			}
+
+			if liveNFT == nil {
+				if export.NFTAccount != nil {
+					t.Fatal("expected no ERC721 account")
+				}
+
+				return
+			}
+
+			if !StorageEqual(rebuilt[DefaultNFTSCAddress].Storage, liveNFT.Storage) ||
+				!StorageEqual(export.NFTAccount.Storage, liveNFT.Storage) {
+				t.Fatal("the ERC721 SC storage isn't carried over")
+			}
+		})
+	}
+}
+
+func TestExportRegenesisReportsUncarriedState(t *testing.T) {
+	validators := generatorValidators(2)
+	holder := types.StringToAddress("0xbeef")
+
+	live, liveNFT := regenesisLive(t, StakingGenesisSpec{
+		Params: PredeployParams{
+			MinValidatorCount: 1,
+			MaxValidatorCount: 2,
+		},
+		Validators: validators,
+		StakedTokens: map[types.Address][]*big.Int{
+			validators[0]: {big.NewInt(1)},
+			validators[1]: {big.NewInt(2)},
+		},
+		NFTHolders: map[types.Address][]*big.Int{
+			holder: {big.NewInt(3), big.NewInt(4)},
+		},
+	})
+
+	export, err := ExportRegenesis(StorageMap(live.Storage), RegenesisOptions{
+		// Token 4 of the holder is missing from the candidates
+		TokenIDs:  []*big.Int{big.NewInt(1), big.NewInt(2), big.NewInt(3)},
+		NFTReader: StorageMap(liveNFT.Storage),
+		Balance:   big.NewInt(7),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		"balance 7 isn't the 0 derived from the spec",
+		"ERC721 balanceOf[" + holder.String() + "] is exported as",
+	}
+
+	if len(export.Report.Issues) != len(expected) {
+		t.Fatalf("expected %d issues, got %s", len(expected), export.Report)
+	}
+
+	for i, reason := range expected {
+		if !strings.Contains(export.Report.Issues[i].Reason, reason) {
+			t.Fatalf("expected issue %d to contain %q, got %s", i, reason, export.Report)
+		}
+	}
+
+	// The exported account keeps the live balance
+	if export.Account.Balance.Cmp(big.NewInt(7)) != 0 {
+		t.Fatalf("expected the balance 7, got %s", export.Account.Balance)
+	}
+
+	// A validator whose staked tokens are all unknown can't be exported as a token staker
+	if _, err := ExportRegenesis(StorageMap(live.Storage), RegenesisOptions{
+		TokenIDs: []*big.Int{big.NewInt(1)},
+	}); err == nil || !strings.Contains(err.Error(), "has no staked tokens") {
+		t.Fatalf("expected the missing staked tokens error, got %v", err)
+	}
+}
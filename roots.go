@@ -0,0 +1,75 @@
+package staking
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/0xPolygon/polygon-edge/chain"
+	"github.com/0xPolygon/polygon-edge/helper/keccak"
+	itrie "github.com/0xPolygon/polygon-edge/state/immutable-trie"
+	"github.com/0xPolygon/polygon-edge/types"
+	"github.com/umbracle/fastrlp"
+)
+
+// encodeStorageValue RLP encodes a storage word the way the state trie stores it,
+// as a byte string with the leading zeros trimmed
+func encodeStorageValue(ar *fastrlp.Arena, value types.Hash) []byte {
+	return ar.NewBytes(bytes.TrimLeft(value.Bytes(), "\x00")).MarshalTo(nil)
+}
+
+// StorageRoot computes the Merkle-Patricia root of the account storage,
+// the same way the state does when the account is written to genesis.
+// Zero words are not part of the trie, so they don't affect the root
+func StorageRoot(account *chain.GenesisAccount) (types.Hash, error) {
+	if len(account.Storage) == 0 {
+		return types.EmptyRootHash, nil
+	}
+
+	txn := itrie.NewTrie().Txn(itrie.NewMemoryStorage())
+	ar := &fastrlp.Arena{}
+
+	for key, value := range account.Storage {
+		if value == types.ZeroHash {
+			continue
+		}
+
+		txn.Insert(keccak.Keccak256(nil, key.Bytes()), encodeStorageValue(ar, value))
+	}
+
+	root, err := txn.Hash()
+	if err != nil {
+		return types.ZeroHash, fmt.Errorf("unable to hash the storage trie, %w", err)
+	}
+
+	if len(root) == 0 {
+		return types.EmptyRootHash, nil
+	}
+
+	return types.BytesToHash(root), nil
+}
+
+// GenesisRoots computes the state root and the block hash of the genesis block,
+// by writing the genesis alloc to an in-memory state. No running node is needed
+func GenesisRoots(genesis *chain.Genesis) (types.Hash, types.Hash, error) {
+	if genesis == nil {
+		return types.ZeroHash, types.ZeroHash, fmt.Errorf("genesis is not set")
+	}
+
+	stateRoot := GenesisStateRoot(genesis)
+
+	// The header is built from a copy, so the passed in genesis isn't modified
+	genesisCopy := *genesis
+	genesisCopy.StateRoot = stateRoot
+
+	header := genesisCopy.GenesisHeader()
+	header.ComputeHash()
+
+	return stateRoot, header.Hash, nil
+}
+
+// GenesisStateRoot computes the state root of the genesis alloc
+func GenesisStateRoot(genesis *chain.Genesis) types.Hash {
+	_, stateRoot := GenesisStateNodes(genesis)
+
+	return stateRoot
+}
@@ -0,0 +1,190 @@
+package staking
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/0xPolygon/polygon-edge/chain"
+	"github.com/0xPolygon/polygon-edge/helper/keccak"
+	itrie "github.com/0xPolygon/polygon-edge/state/immutable-trie"
+	"github.com/0xPolygon/polygon-edge/types"
+	"github.com/umbracle/fastrlp"
+)
+
+// storageWord returns the storage word with the given value
+func storageWord(value *big.Int) types.Hash {
+	return types.BytesToHash(value.Bytes())
+}
+
+func TestStorageRootKnownValues(t *testing.T) {
+	highBit := new(big.Int).Lsh(big.NewInt(1), 255)
+
+	cases := []struct {
+		name    string
+		storage map[types.Hash]types.Hash
+		root    string
+	}{
+		{
+			name: "empty",
+			root: types.EmptyRootHash.String(),
+		},
+		{
+			name: "only zero words",
+			storage: map[types.Hash]types.Hash{
+				storageWord(big.NewInt(0)): types.ZeroHash,
+				storageWord(big.NewInt(1)): types.ZeroHash,
+			},
+			root: types.EmptyRootHash.String(),
+		},
+		{
+			// The storage root of a contract with 1 in slot 0, as computed by every Ethereum client
+			name: "single word",
+			storage: map[types.Hash]types.Hash{
+				storageWord(big.NewInt(0)): storageWord(big.NewInt(1)),
+			},
+			root: "0x821e2556a290c86405f8160a2d662042a431ba456b9db265c79bb837c04be5f0",
+		},
+		{
+			name: "words of different sizes",
+			storage: map[types.Hash]types.Hash{
+				storageWord(big.NewInt(0)): storageWord(big.NewInt(1)),
+				storageWord(big.NewInt(1)): storageWord(big.NewInt(0x1234)),
+				types.BytesToHash(keccak.Keccak256(nil, storageWord(big.NewInt(0)).Bytes())): storageWord(highBit),
+				storageWord(big.NewInt(2)): types.ZeroHash,
+			},
+			root: "0xd0c164cb440fac2c4cc5af1c831ca1f042eac4077e03b51e068bd512b58c1f65",
+		},
+	}
+
+	for _, c := range cases {
+		c := c
+
+		t.Run(c.name, func(t *testing.T) {
+			root, err := StorageRoot(&chain.GenesisAccount{Storage: c.storage})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if root.String() != c.root {
+				t.Fatalf("expected storage root %s, got %s", c.root, root)
+			}
+		})
+	}
+}
+
+// zeroWordSet is a validator set with a zero stake, which is an explicit zero word in the explicit storage mode
+func zeroWordSet(t *testing.T, mode StorageMode) *chain.GenesisAccount {
+	t.Helper()
+
+	validators := generatorValidators(3)
+
+	account, err := PredeployStakingSCWithStakes(
+		validators,
+		map[types.Address]*big.Int{validators[1]: big.NewInt(0)},
+		PredeployParams{
+			MinValidatorCount: 1,
+			MaxValidatorCount: 3,
+			StorageMode:       mode,
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return account
+}
+
+func TestStorageRootZeroWords(t *testing.T) {
+	explicit := zeroWordSet(t, StorageModeExplicit)
+	sparse := zeroWordSet(t, StorageModeSparse)
+
+	if len(explicit.Storage) == len(sparse.Storage) {
+		t.Fatal("expected the explicit storage to have zero words")
+	}
+
+	explicitRoot, err := StorageRoot(explicit)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sparseRoot, err := StorageRoot(sparse)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if explicitRoot != sparseRoot {
+		t.Fatalf("expected the same storage root, got %s and %s", explicitRoot, sparseRoot)
+	}
+
+	genesis := func(account *chain.GenesisAccount) *chain.Genesis {
+		return &chain.Genesis{
+			Alloc: map[types.Address]*chain.GenesisAccount{
+				DefaultStakingSCAddress: account,
+			},
+		}
+	}
+
+	explicitGenesis := genesis(explicit)
+
+	explicitState, explicitHash, err := GenesisRoots(explicitGenesis)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sparseState, sparseHash, err := GenesisRoots(genesis(sparse))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if explicitState != sparseState || explicitHash != sparseHash {
+		t.Fatalf("expected the same genesis roots, got %s/%s and %s/%s", explicitState, explicitHash, sparseState, sparseHash)
+	}
+
+	if explicitGenesis.StateRoot != types.ZeroHash {
+		t.Fatal("expected the genesis to be left untouched")
+	}
+}
+
+func TestGenesisStateRootMatchesStorageRoot(t *testing.T) {
+	account := zeroWordSet(t, StorageModeExplicit)
+	account.Nonce = 1
+
+	alloc := map[types.Address]*chain.GenesisAccount{
+		DefaultStakingSCAddress:        account,
+		types.StringToAddress("0x100"): {Balance: big.NewInt(1000)},
+	}
+
+	// Build the state trie by hand, with the storage root of StorageRoot
+	// and the account layout of the state: [nonce, balance, storage root, code hash]
+	txn := itrie.NewTrie().Txn(itrie.NewMemoryStorage())
+	ar := &fastrlp.Arena{}
+
+	for address, genesisAccount := range alloc {
+		storageRoot, err := StorageRoot(genesisAccount)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		balance := genesisAccount.Balance
+		if balance == nil {
+			balance = big.NewInt(0)
+		}
+
+		encoded := ar.NewArray()
+		encoded.Set(ar.NewUint(genesisAccount.Nonce))
+		encoded.Set(ar.NewBigInt(balance))
+		encoded.Set(ar.NewCopyBytes(storageRoot.Bytes()))
+		encoded.Set(ar.NewCopyBytes(keccak.Keccak256(nil, genesisAccount.Code)))
+
+		txn.Insert(keccak.Keccak256(nil, address.Bytes()), encoded.MarshalTo(nil))
+	}
+
+	want, err := txn.Hash()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := GenesisStateRoot(&chain.Genesis{Alloc: alloc}); got != types.BytesToHash(want) {
+		t.Fatalf("expected state root %s, got %s", types.BytesToHash(want), got)
+	}
+}
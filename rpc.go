@@ -0,0 +1,236 @@
+package staking
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/0xPolygon/polygon-edge/helper/hex"
+)
+
+const (
+	// defaultRPCTimeout is the timeout of the default JSON-RPC HTTP client
+	defaultRPCTimeout = 30 * time.Second
+
+	// DefaultMaxRetryBackoff is the default upper bound of the delay between two retries of a failed step
+	DefaultMaxRetryBackoff = 30 * time.Second
+
+	// minRetryBackoff is the delay before the first retry of a failed step
+	minRetryBackoff = 500 * time.Millisecond
+)
+
+// jsonRPCRequest is a JSON-RPC 2.0 request
+type jsonRPCRequest struct {
+	JSONRPC string        `json:"jsonrpc"`
+	ID      uint64        `json:"id"`
+	Method  string        `json:"method"`
+	Params  []interface{} `json:"params"`
+}
+
+// jsonRPCResponse is a JSON-RPC 2.0 response
+type jsonRPCResponse struct {
+	ID     uint64          `json:"id"`
+	Result json.RawMessage `json:"result"`
+	Error  *JSONRPCError   `json:"error"`
+}
+
+// JSONRPCError is an error returned by the JSON-RPC endpoint
+type JSONRPCError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *JSONRPCError) Error() string {
+	return fmt.Sprintf("json-rpc error %d: %s", e.Code, e.Message)
+}
+
+// jsonRPCTransport sends JSON-RPC requests over HTTP
+type jsonRPCTransport struct {
+	endpoint string
+	client   *http.Client
+	nextID   uint64
+}
+
+func newJSONRPCTransport(endpoint string, client *http.Client) *jsonRPCTransport {
+	if client == nil {
+		client = &http.Client{
+			Timeout: defaultRPCTimeout,
+		}
+	}
+
+	return &jsonRPCTransport{
+		endpoint: endpoint,
+		client:   client,
+	}
+}
+
+// post sends the request body and decodes the response body into the result
+func (t *jsonRPCTransport) post(ctx context.Context, body interface{}, result interface{}) error {
+	encoded, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.endpoint, bytes.NewReader(encoded))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("unable to reach json-rpc endpoint, %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("json-rpc endpoint returned status %s", resp.Status)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+		return fmt.Errorf("unable to decode json-rpc response, %w", err)
+	}
+
+	return nil
+}
+
+// call invokes a single JSON-RPC method and decodes its result
+func (t *jsonRPCTransport) call(
+	ctx context.Context,
+	method string,
+	params []interface{},
+	result interface{},
+) error {
+	request := jsonRPCRequest{
+		JSONRPC: "2.0",
+		ID:      atomic.AddUint64(&t.nextID, 1),
+		Method:  method,
+		Params:  params,
+	}
+
+	var response jsonRPCResponse
+	if err := t.post(ctx, request, &response); err != nil {
+		return err
+	}
+
+	if response.Error != nil {
+		return response.Error
+	}
+
+	return json.Unmarshal(response.Result, result)
+}
+
+// jsonRPCBatchElem is a single call of a JSON-RPC batch
+type jsonRPCBatchElem struct {
+	method string
+	params []interface{}
+	result interface{}
+	err    error
+}
+
+// batchCall invokes several JSON-RPC methods in a single batch request.
+// Errors of individual calls are stored in their batch elements
+func (t *jsonRPCTransport) batchCall(ctx context.Context, elems []*jsonRPCBatchElem) error {
+	requests := make([]jsonRPCRequest, len(elems))
+	byID := make(map[uint64]*jsonRPCBatchElem, len(elems))
+
+	for i, elem := range elems {
+		requests[i] = jsonRPCRequest{
+			JSONRPC: "2.0",
+			ID:      atomic.AddUint64(&t.nextID, 1),
+			Method:  elem.method,
+			Params:  elem.params,
+		}
+
+		byID[requests[i].ID] = elem
+	}
+
+	var responses []jsonRPCResponse
+	if err := t.post(ctx, requests, &responses); err != nil {
+		return err
+	}
+
+	// Responses of a batch can arrive in any order, so they are matched by ID
+	for _, response := range responses {
+		elem, ok := byID[response.ID]
+		if !ok {
+			return fmt.Errorf("unexpected json-rpc response id %d", response.ID)
+		}
+
+		delete(byID, response.ID)
+
+		if response.Error != nil {
+			elem.err = response.Error
+
+			continue
+		}
+
+		elem.err = json.Unmarshal(response.Result, elem.result)
+	}
+
+	for id := range byID {
+		return fmt.Errorf("missing json-rpc response for id %d", id)
+	}
+
+	return nil
+}
+
+// blockParam returns the JSON-RPC block parameter for the given block number,
+// or latest if it's not set
+func blockParam(block *uint64) string {
+	if block == nil {
+		return "latest"
+	}
+
+	return hex.EncodeUint64(*block)
+}
+
+// retryBackoff is the exponentially growing delay between the retries of a failed step
+type retryBackoff struct {
+	max   time.Duration
+	delay time.Duration
+}
+
+func newRetryBackoff(max time.Duration) *retryBackoff {
+	if max <= 0 {
+		max = DefaultMaxRetryBackoff
+	}
+
+	backoff := &retryBackoff{
+		max: max,
+	}
+	backoff.reset()
+
+	return backoff
+}
+
+// reset restores the initial delay, after a successful step
+func (b *retryBackoff) reset() {
+	b.delay = minRetryBackoff
+	if b.delay > b.max {
+		b.delay = b.max
+	}
+}
+
+// wait sleeps for the current delay and doubles it, up to the maximum.
+// It returns false if the context is done first
+func (b *retryBackoff) wait(ctx context.Context) bool {
+	timer := time.NewTimer(b.delay)
+	defer timer.Stop()
+
+	if b.delay *= 2; b.delay > b.max {
+		b.delay = b.max
+	}
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
@@ -0,0 +1,161 @@
+package staking
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/0xPolygon/polygon-edge/chain"
+	"github.com/0xPolygon/polygon-edge/crypto"
+	"github.com/0xPolygon/polygon-edge/secrets"
+	"github.com/0xPolygon/polygon-edge/types"
+)
+
+var errNoDataDirs = errors.New("no data directories provided")
+
+// SecretsValidator is a validator derived from a polygon-edge data directory
+type SecretsValidator struct {
+	DataDir      string
+	Address      types.Address
+	BLSPublicKey []byte // nil if the directory has no BLS key
+}
+
+// ReadSecretsValidators derives the validators from the local secrets
+// of the given data directories, as laid out by polygon-edge's secrets init.
+// The result is sorted by address, so the order of the directories doesn't
+// affect it
+func ReadSecretsValidators(dataDirs []string) ([]*SecretsValidator, error) {
+	if len(dataDirs) == 0 {
+		return nil, errNoDataDirs
+	}
+
+	validators := make([]*SecretsValidator, 0, len(dataDirs))
+	seen := make(map[types.Address]string, len(dataDirs))
+
+	for _, dataDir := range dataDirs {
+		validator, err := readSecretsValidator(dataDir)
+		if err != nil {
+			return nil, err
+		}
+
+		if prevDir, ok := seen[validator.Address]; ok {
+			return nil, fmt.Errorf(
+				"validator %s is present in both %s and %s",
+				validator.Address,
+				prevDir,
+				dataDir,
+			)
+		}
+
+		seen[validator.Address] = dataDir
+
+		validators = append(validators, validator)
+	}
+
+	sort.Slice(validators, func(i, j int) bool {
+		return bytes.Compare(validators[i].Address.Bytes(), validators[j].Address.Bytes()) < 0
+	})
+
+	return validators, nil
+}
+
+// readSecretsValidator derives a single validator from its data directory
+func readSecretsValidator(dataDir string) (*SecretsValidator, error) {
+	consensusDir := filepath.Join(dataDir, secrets.ConsensusFolderLocal)
+
+	keyBytes, err := os.ReadFile(filepath.Join(consensusDir, secrets.ValidatorKeyLocal))
+	if err != nil {
+		return nil, fmt.Errorf("unable to read validator key from %s, %w", dataDir, err)
+	}
+
+	key, err := crypto.BytesToECDSAPrivateKey(bytes.TrimSpace(keyBytes))
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse validator key from %s, %w", dataDir, err)
+	}
+
+	validator := &SecretsValidator{
+		DataDir: dataDir,
+		Address: crypto.PubKeyToAddress(&key.PublicKey),
+	}
+
+	blsKeyBytes, err := os.ReadFile(filepath.Join(consensusDir, secrets.ValidatorBLSKeyLocal))
+	if errors.Is(err, os.ErrNotExist) {
+		return validator, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("unable to read validator BLS key from %s, %w", dataDir, err)
+	}
+
+	blsKey, err := crypto.BytesToBLSSecretKey(bytes.TrimSpace(blsKeyBytes))
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse validator BLS key from %s, %w", dataDir, err)
+	}
+
+	if validator.BLSPublicKey, err = crypto.BLSSecretKeyToPubkeyBytes(blsKey); err != nil {
+		return nil, fmt.Errorf("unable to derive validator BLS public key from %s, %w", dataDir, err)
+	}
+
+	return validator, nil
+}
+
+// PredeployStakingSCFromSecrets is a helper method for setting up the staking smart contract account,
+// using the validators derived from the given data directories as pre-staked validators.
+// The validators are returned too, in _validators order, so their BLS public keys
+// can go into the genesis extra data (see SecretsIBFTExtra)
+func PredeployStakingSCFromSecrets(
+	dataDirs []string,
+	params PredeployParams,
+) (*chain.GenesisAccount, []*SecretsValidator, error) {
+	validators, err := ReadSecretsValidators(dataDirs)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	addresses := make([]types.Address, len(validators))
+	for i, validator := range validators {
+		addresses[i] = validator.Address
+	}
+
+	// The validators are sorted by address and equally staked,
+	// so every ordering policy keeps them in this order
+	account, err := PredeployStakingSC(addresses, params)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return account, validators, nil
+}
+
+// SecretsIBFTExtra encodes the genesis IBFT extra data of the validators.
+// The BLS validator type is used if the validators have BLS keys,
+// in which case every validator needs one
+func SecretsIBFTExtra(validators []*SecretsValidator) ([]byte, error) {
+	addresses := make([]types.Address, len(validators))
+	for i, validator := range validators {
+		addresses[i] = validator.Address
+	}
+
+	if len(validators) == 0 || validators[0].BLSPublicKey == nil {
+		for _, validator := range validators {
+			if validator.BLSPublicKey != nil {
+				return nil, fmt.Errorf("validator %s has a BLS key, but not every validator does", validator.Address)
+			}
+		}
+
+		return EncodeIBFTExtra(addresses, nil)
+	}
+
+	blsPublicKeys := make([][]byte, len(validators))
+
+	for i, validator := range validators {
+		if validator.BLSPublicKey == nil {
+			return nil, fmt.Errorf("validator %s in %s has no BLS key", validator.Address, validator.DataDir)
+		}
+
+		blsPublicKeys[i] = validator.BLSPublicKey
+	}
+
+	return EncodeIBFTExtra(addresses, blsPublicKeys)
+}
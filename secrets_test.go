@@ -0,0 +1,150 @@
+package staking
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/0xPolygon/polygon-edge/secrets"
+)
+
+// writeSecretsDir writes a data directory in polygon-edge's local secrets layout,
+// with the validator key derived from the seed byte and, if bls is set, a BLS key
+func writeSecretsDir(t *testing.T, dir string, seed byte, bls bool) string {
+	t.Helper()
+
+	dataDir := filepath.Join(dir, fmt.Sprintf("data-%d", seed))
+	consensusDir := filepath.Join(dataDir, secrets.ConsensusFolderLocal)
+
+	if err := writeSecret(consensusDir, secrets.ValidatorKeyLocal, bytes.Repeat([]byte{seed}, 32)); err != nil {
+		t.Fatal(err)
+	}
+
+	if bls {
+		blsKey := append([]byte{0x01}, bytes.Repeat([]byte{seed}, 31)...)
+
+		if err := writeSecret(consensusDir, secrets.ValidatorBLSKeyLocal, blsKey); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return dataDir
+}
+
+func TestReadSecretsValidatorsOrder(t *testing.T) {
+	dir := t.TempDir()
+
+	dataDirs := make([]string, 4)
+	for i := range dataDirs {
+		dataDirs[i] = writeSecretsDir(t, dir, byte(i+1), true)
+	}
+
+	want, err := ReadSecretsValidators(dataDirs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 1; i < len(want); i++ {
+		if bytes.Compare(want[i-1].Address.Bytes(), want[i].Address.Bytes()) >= 0 {
+			t.Fatalf("validators %d and %d aren't sorted by address", i-1, i)
+		}
+	}
+
+	shuffles := [][]string{
+		{dataDirs[3], dataDirs[2], dataDirs[1], dataDirs[0]},
+		{dataDirs[2], dataDirs[0], dataDirs[3], dataDirs[1]},
+		{dataDirs[1], dataDirs[3], dataDirs[0], dataDirs[2]},
+	}
+
+	for _, shuffled := range shuffles {
+		got, err := ReadSecretsValidators(shuffled)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("directories %v: expected the same validators as %v", shuffled, dataDirs)
+		}
+
+		wantExtra, err := SecretsIBFTExtra(want)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		gotExtra, err := SecretsIBFTExtra(got)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(gotExtra, wantExtra) {
+			t.Fatalf("directories %v: expected the same extra data as %v", shuffled, dataDirs)
+		}
+	}
+}
+
+func TestReadSecretsValidatorsErrors(t *testing.T) {
+	dir := t.TempDir()
+
+	var (
+		first     = writeSecretsDir(t, dir, 1, true)
+		duplicate = filepath.Join(dir, "duplicate")
+	)
+
+	if err := writeSecret(
+		filepath.Join(duplicate, secrets.ConsensusFolderLocal),
+		secrets.ValidatorKeyLocal,
+		bytes.Repeat([]byte{1}, 32),
+	); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ReadSecretsValidators(nil); !errors.Is(err, errNoDataDirs) {
+		t.Fatalf("expected errNoDataDirs, got %v", err)
+	}
+
+	_, err := ReadSecretsValidators([]string{first, duplicate})
+	if err == nil || !strings.Contains(err.Error(), "is present in both "+first+" and "+duplicate) {
+		t.Fatalf("expected a duplicate key error, got %v", err)
+	}
+
+	if _, err := ReadSecretsValidators([]string{first, filepath.Join(dir, "missing")}); err == nil {
+		t.Fatal("expected a missing validator key error")
+	}
+}
+
+func TestReadSecretsValidatorsMissingBLSKey(t *testing.T) {
+	dir := t.TempDir()
+
+	validators, err := ReadSecretsValidators([]string{
+		writeSecretsDir(t, dir, 1, true),
+		writeSecretsDir(t, dir, 2, false),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	withoutKey := 0
+
+	for _, validator := range validators {
+		if validator.BLSPublicKey == nil {
+			withoutKey++
+
+			if validator.DataDir != filepath.Join(dir, "data-2") {
+				t.Fatalf("expected %s to have a BLS key", validator.DataDir)
+			}
+		}
+	}
+
+	if withoutKey != 1 {
+		t.Fatalf("expected a single validator without a BLS key, got %d", withoutKey)
+	}
+
+	// The extra data can't mix ECDSA and BLS validators
+	if _, err := SecretsIBFTExtra(validators); err == nil {
+		t.Fatal("expected a missing BLS key error")
+	}
+}
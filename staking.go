@@ -33,6 +33,19 @@ func getAddressMapping(address types.Address, slot int64) []byte {
 	return keccakValue
 }
 
+// getUint256Mapping returns the key for the SC storage mapping (uint256 => something)
+func getUint256Mapping(key *big.Int, slot int64) []byte {
+	bigSlot := big.NewInt(slot)
+
+	finalSlice := append(
+		common.PadLeftOrTrim(key.Bytes(), 32),
+		common.PadLeftOrTrim(bigSlot.Bytes(), 32)...,
+	)
+	keccakValue := keccak.Keccak256(nil, finalSlice)
+
+	return keccakValue
+}
+
 // getIndexWithOffset is a helper method for adding an offset to the already found keccak hash
 func getIndexWithOffset(keccakHash []byte, offset int64) []byte {
 	bigOffset := big.NewInt(offset)
@@ -77,10 +90,18 @@ func getStorageIndexes(address types.Address, index int64) *StorageIndexes {
 	return &storageIndexes
 }
 
+// GetStorageIndexes returns the storage indexes of the validator at the given
+// _validators index, for packages checking the staking SC storage directly
+func GetStorageIndexes(address types.Address, index int64) *StorageIndexes {
+	return getStorageIndexes(address, index)
+}
+
 // PredeployParams contains the values used to predeploy the PoS staking contract
 type PredeployParams struct {
 	MinValidatorCount uint64
 	MaxValidatorCount uint64
+	Ordering          ValidatorOrdering
+	StorageMode       StorageMode
 }
 
 // StorageIndexes is a wrapper for different storage indexes that
@@ -103,6 +124,9 @@ var (
 	stakedAmountSlot            = int64(4) // Slot 4
 	minNumValidatorSlot         = int64(5) // Slot 5
 	maxNumValidatorSlot         = int64(6) // Slot 6
+	nftAddressSlot              = int64(7) // Slot 7
+	tokenIDToOwnerSlot          = int64(8) // Slot 8
+	addressToWeightSlot         = int64(9) // Slot 9
 )
 
 const (
@@ -116,6 +140,17 @@ const (
 func PredeployStakingSC(
 	validators []types.Address,
 	params PredeployParams,
+) (*chain.GenesisAccount, error) {
+	return PredeployStakingSCWithStakes(validators, nil, params)
+}
+
+// PredeployStakingSCWithStakes is a helper method for setting up the staking smart contract account,
+// using the passed in validators as pre-staked validators with the given stakes.
+// Validators missing from the stakes map are staked with the DefaultStakedBalance
+func PredeployStakingSCWithStakes(
+	validators []types.Address,
+	stakes map[types.Address]*big.Int,
+	params PredeployParams,
 ) (*chain.GenesisAccount, error) {
 	// Set the code for the staking smart contract
 	// Code retrieved from https://github.com/0xPolygon/staking-contracts
@@ -132,6 +167,12 @@ func PredeployStakingSC(
 		return nil, fmt.Errorf("unable to generate DefaultStatkedBalance, %w", err)
 	}
 
+	// Order the validators according to the ordering policy
+	validators, err = orderValidators(validators, stakes, bigDefaultStakedBalance, params.Ordering)
+	if err != nil {
+		return nil, err
+	}
+
 	// Generate the empty account storage map
 	storageMap := make(map[types.Hash]types.Hash)
 	bigTrueValue := big.NewInt(1)
@@ -140,8 +181,17 @@ func PredeployStakingSC(
 	bigMaxNumValidators := big.NewInt(int64(params.MaxValidatorCount))
 
 	for indx, validator := range validators {
+		stake := bigDefaultStakedBalance
+		if customStake, ok := stakes[validator]; ok {
+			if customStake == nil || customStake.Sign() < 0 {
+				return nil, fmt.Errorf("invalid stake for validator %s", validator)
+			}
+
+			stake = customStake
+		}
+
 		// Update the total staked amount
-		stakedAmount.Add(stakedAmount, bigDefaultStakedBalance)
+		stakedAmount.Add(stakedAmount, stake)
 
 		// Get the storage indexes
 		storageIndexes := getStorageIndexes(validator, int64(indx))
@@ -158,7 +208,7 @@ func PredeployStakingSC(
 
 		// Set the value for the address -> staked amount mapping
 		storageMap[types.BytesToHash(storageIndexes.AddressToStakedAmountIndex)] =
-			types.StringToHash(hex.EncodeBig(bigDefaultStakedBalance))
+			types.StringToHash(hex.EncodeBig(stake))
 
 		// Set the value for the address -> validator index mapping
 		storageMap[types.BytesToHash(storageIndexes.AddressToValidatorIndexIndex)] =
@@ -181,8 +231,10 @@ func PredeployStakingSC(
 	storageMap[types.BytesToHash(big.NewInt(maxNumValidatorSlot).Bytes())] =
 		types.BytesToHash(bigMaxNumValidators.Bytes())
 
-	// Save the storage map
-	stakingAccount.Storage = storageMap
+	// Save the storage map, in the layout of the selected storage mode
+	if stakingAccount.Storage, err = applyStorageMode(storageMap, params.StorageMode); err != nil {
+		return nil, err
+	}
 
 	// Set the Staking SC balance to numValidators * defaultStakedBalance
 	stakingAccount.Balance = stakedAmount
@@ -0,0 +1,115 @@
+package stakingtest
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/0xPolygon/polygon-edge/helper/staking"
+	"github.com/0xPolygon/polygon-edge/types"
+)
+
+// readWord reads a storage word of the staking SC, and fails the test if the read fails
+func readWord(t testing.TB, state staking.StateReader, index []byte) *big.Int {
+	t.Helper()
+
+	value, err := state.GetStorage(types.BytesToHash(index))
+	if err != nil {
+		t.Fatalf("unable to read storage slot %s, %v", types.BytesToHash(index), err)
+	}
+
+	return new(big.Int).SetBytes(value.Bytes())
+}
+
+// AssertValidatorSet checks the staking SC storage against the expected validators, in _validators order.
+// The slots are read directly through staking.GetStorageIndexes, so the check doesn't depend on
+// the staking view: the array length, every array element, and the
+// _addressToIsValidator and _addressToValidatorIndex mappings of every validator
+func AssertValidatorSet(t testing.TB, state staking.StateReader, want []types.Address) {
+	t.Helper()
+
+	size := readWord(t, state, staking.GetStorageIndexes(types.ZeroAddress, 0).ValidatorsArraySizeIndex)
+	if !size.IsUint64() || size.Uint64() != uint64(len(want)) {
+		t.Fatalf("expected %d validators, got %s", len(want), size)
+	}
+
+	for i, validator := range want {
+		indexes := staking.GetStorageIndexes(validator, int64(i))
+
+		if got := types.BytesToAddress(readWord(t, state, indexes.ValidatorsIndex).Bytes()); got != validator {
+			t.Errorf("validator %d: expected %s, got %s", i, validator, got)
+		}
+
+		if isValidator := readWord(t, state, indexes.AddressToIsValidatorIndex); isValidator.Cmp(big.NewInt(1)) != 0 {
+			t.Errorf("validator %s: expected _addressToIsValidator 1, got %s", validator, isValidator)
+		}
+
+		if index := readWord(t, state, indexes.AddressToValidatorIndexIndex); index.Cmp(big.NewInt(int64(i))) != 0 {
+			t.Errorf("validator %s: expected _addressToValidatorIndex %d, got %s", validator, i, index)
+		}
+	}
+}
+
+// AssertStakes checks the staked amount of every account in the map, and that the
+// total staked amount is their sum. The map has to contain every staker
+func AssertStakes(t testing.TB, state staking.StateReader, want map[types.Address]*big.Int) {
+	t.Helper()
+
+	total := big.NewInt(0)
+
+	for account, stake := range want {
+		indexes := staking.GetStorageIndexes(account, 0)
+
+		if got := readWord(t, state, indexes.AddressToStakedAmountIndex); got.Cmp(stake) != 0 {
+			t.Errorf("account %s: expected stake %s, got %s", account, stake, got)
+		}
+
+		total.Add(total, stake)
+	}
+
+	got := readWord(t, state, staking.GetStorageIndexes(types.ZeroAddress, 0).StakedAmountIndex)
+	if got.Cmp(total) != 0 {
+		t.Errorf("expected total staked amount %s, got %s", total, got)
+	}
+}
+
+// AssertBounds checks the validator count bounds of the staking SC
+func AssertBounds(t testing.TB, state staking.StateReader, want staking.PredeployParams) {
+	t.Helper()
+
+	got, err := staking.NewStakingView(state).Bounds()
+	if err != nil {
+		t.Fatalf("unable to read the validator count bounds, %v", err)
+	}
+
+	if got.MinValidatorCount != want.MinValidatorCount || got.MaxValidatorCount != want.MaxValidatorCount {
+		t.Errorf(
+			"expected bounds [%d, %d], got [%d, %d]",
+			want.MinValidatorCount,
+			want.MaxValidatorCount,
+			got.MinValidatorCount,
+			got.MaxValidatorCount,
+		)
+	}
+}
+
+// AssertValidatorSetCase checks the staking SC storage against everything the validator set
+// describes: the validators in the order of the ordering policy, their stakes and the bounds
+func AssertValidatorSetCase(t testing.TB, state staking.StateReader, set ValidatorSetCase) {
+	t.Helper()
+
+	val := staking.DefaultStakedBalance
+	defaultStake, _ := types.ParseUint256orHex(&val)
+
+	stakes := make(map[types.Address]*big.Int, len(set.Validators))
+	for _, validator := range set.Validators {
+		stakes[validator] = defaultStake
+
+		if stake, ok := set.Stakes[validator]; ok && stake != nil {
+			stakes[validator] = stake
+		}
+	}
+
+	AssertValidatorSet(t, state, ExpectedOrder(t, set))
+	AssertStakes(t, state, stakes)
+	AssertBounds(t, state, set.Params)
+}
@@ -0,0 +1,251 @@
+// Package stakingtest provides fixtures, an in-memory EVM harness and assertions
+// for tests of packages built on the staking SC predeploy
+package stakingtest
+
+import (
+	"fmt"
+	"math/big"
+	"math/rand"
+	"testing"
+
+	"github.com/0xPolygon/polygon-edge/chain"
+	"github.com/0xPolygon/polygon-edge/helper/staking"
+	"github.com/0xPolygon/polygon-edge/types"
+)
+
+// ValidatorSetCase is a named input of the staking SC predeploy
+type ValidatorSetCase struct {
+	Name       string
+	Validators []types.Address
+
+	// Stakes are the staked amounts of the validators.
+	// Validators missing from the map are staked with the staking.DefaultStakedBalance
+	Stakes map[types.Address]*big.Int
+
+	Params staking.PredeployParams
+
+	// Order is the expected _validators order. It's required when the ordering policy
+	// isn't the input one, and is written out by hand rather than computed by the same sort
+	Order []types.Address
+}
+
+// SequentialAddress returns the address with the given number as its value, 0x...01 for 1
+func SequentialAddress(n uint64) types.Address {
+	return types.BytesToAddress(new(big.Int).SetUint64(n).Bytes())
+}
+
+// SequentialValidators returns the addresses 0x...01 up to the given count
+func SequentialValidators(count int) []types.Address {
+	validators := make([]types.Address, count)
+	for i := range validators {
+		validators[i] = SequentialAddress(uint64(i + 1))
+	}
+
+	return validators
+}
+
+// RandomAddress returns a random address read from the source
+func RandomAddress(r *rand.Rand) types.Address {
+	var address types.Address
+
+	_, _ = r.Read(address[:])
+
+	return address
+}
+
+// RandomValidatorSet returns a set of distinct random validators with random stakes
+// in the [1, 2^63] range, and bounds fitting the set exactly.
+// The same source seed always returns the same set
+func RandomValidatorSet(r *rand.Rand, count int) ValidatorSetCase {
+	set := ValidatorSetCase{
+		Name:       fmt.Sprintf("random %d validators", count),
+		Validators: make([]types.Address, 0, count),
+		Stakes:     make(map[types.Address]*big.Int, count),
+		Params: staking.PredeployParams{
+			MinValidatorCount: 1,
+			MaxValidatorCount: uint64(count),
+		},
+	}
+
+	for len(set.Validators) < count {
+		validator := RandomAddress(r)
+		if _, ok := set.Stakes[validator]; ok || validator == types.ZeroAddress {
+			continue
+		}
+
+		set.Validators = append(set.Validators, validator)
+		set.Stakes[validator] = new(big.Int).SetUint64(r.Uint64()%(1<<63) + 1)
+	}
+
+	return set
+}
+
+// EdgeCaseValidatorSets returns the validator sets at the limits of the predeploy:
+// a single validator, tight and maximal bounds, boundary addresses and stakes,
+// the ordering policies and the storage modes
+func EdgeCaseValidatorSets() []ValidatorSetCase {
+	maxUint256 := new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 256), big.NewInt(1))
+
+	highAddress := types.Address{}
+	for i := range highAddress {
+		highAddress[i] = 0xff
+	}
+
+	midAddress := types.Address{0x80}
+
+	return []ValidatorSetCase{
+		{
+			Name:       "single validator",
+			Validators: SequentialValidators(1),
+			Params: staking.PredeployParams{
+				MinValidatorCount: 1,
+				MaxValidatorCount: 1,
+			},
+		},
+		{
+			Name:       "default bounds",
+			Validators: SequentialValidators(4),
+			Params: staking.PredeployParams{
+				MinValidatorCount: staking.MinValidatorCount,
+				MaxValidatorCount: staking.MaxValidatorCount,
+			},
+		},
+		{
+			Name:       "minimum equals maximum",
+			Validators: SequentialValidators(3),
+			Params: staking.PredeployParams{
+				MinValidatorCount: 3,
+				MaxValidatorCount: 3,
+			},
+		},
+		{
+			Name:       "boundary addresses",
+			Validators: []types.Address{highAddress, SequentialAddress(1), midAddress},
+			Params: staking.PredeployParams{
+				MinValidatorCount: 1,
+				MaxValidatorCount: 10,
+			},
+		},
+		{
+			Name:       "maximum stake",
+			Validators: SequentialValidators(1),
+			Stakes: map[types.Address]*big.Int{
+				SequentialAddress(1): maxUint256,
+			},
+			Params: staking.PredeployParams{
+				MinValidatorCount: 1,
+				MaxValidatorCount: 1,
+			},
+		},
+		{
+			Name:       "zero stake",
+			Validators: SequentialValidators(2),
+			Stakes: map[types.Address]*big.Int{
+				SequentialAddress(2): big.NewInt(0),
+			},
+			Params: staking.PredeployParams{
+				MinValidatorCount: 1,
+				MaxValidatorCount: 2,
+			},
+		},
+		{
+			Name:       "ordered by stake",
+			Validators: SequentialValidators(3),
+			Stakes: map[types.Address]*big.Int{
+				SequentialAddress(1): big.NewInt(1),
+				SequentialAddress(2): big.NewInt(300),
+				SequentialAddress(3): big.NewInt(20),
+			},
+			Params: staking.PredeployParams{
+				MinValidatorCount: 1,
+				MaxValidatorCount: 3,
+				Ordering:          staking.OrderByStake,
+			},
+			Order: []types.Address{SequentialAddress(2), SequentialAddress(3), SequentialAddress(1)},
+		},
+		{
+			Name:       "ordered by address",
+			Validators: []types.Address{highAddress, midAddress, SequentialAddress(1)},
+			Params: staking.PredeployParams{
+				MinValidatorCount: 1,
+				MaxValidatorCount: 3,
+				Ordering:          staking.OrderByAddress,
+			},
+			Order: []types.Address{SequentialAddress(1), midAddress, highAddress},
+		},
+		{
+			Name:       "sparse storage",
+			Validators: SequentialValidators(2),
+			Stakes: map[types.Address]*big.Int{
+				SequentialAddress(1): big.NewInt(0),
+			},
+			Params: staking.PredeployParams{
+				MinValidatorCount: 1,
+				MaxValidatorCount: 2,
+				StorageMode:       staking.StorageModeSparse,
+			},
+		},
+		{
+			Name:       "large set",
+			Validators: SequentialValidators(256),
+			Params: staking.PredeployParams{
+				MinValidatorCount: 1,
+				MaxValidatorCount: 256,
+			},
+		},
+	}
+}
+
+// GenesisAccount predeploys the staking SC for the validator set,
+// and fails the test if the predeploy fails
+func GenesisAccount(t testing.TB, set ValidatorSetCase) *chain.GenesisAccount {
+	t.Helper()
+
+	account, err := staking.PredeployStakingSCWithStakes(set.Validators, set.Stakes, set.Params)
+	if err != nil {
+		t.Fatalf("unable to predeploy the staking SC for %q, %v", set.Name, err)
+	}
+
+	return account
+}
+
+// Spec returns the genesis spec of the validator set, predeploying the staking SC alone
+func (c ValidatorSetCase) Spec() staking.StakingGenesisSpec {
+	return staking.StakingGenesisSpec{
+		Params:     c.Params,
+		Validators: c.Validators,
+		Stakes:     c.Stakes,
+	}
+}
+
+// GoldenAccounts predeploys the staking SC for every edge case validator set,
+// keyed by the name of the set
+func GoldenAccounts(t testing.TB) map[string]*chain.GenesisAccount {
+	t.Helper()
+
+	sets := EdgeCaseValidatorSets()
+	accounts := make(map[string]*chain.GenesisAccount, len(sets))
+
+	for _, set := range sets {
+		accounts[set.Name] = GenesisAccount(t, set)
+	}
+
+	return accounts
+}
+
+// ExpectedOrder returns the validators of the set in the order they should be stored in _validators:
+// the Order of the set if it has one, the input order otherwise.
+// It fails the test if the set uses another ordering policy without an Order
+func ExpectedOrder(t testing.TB, set ValidatorSetCase) []types.Address {
+	t.Helper()
+
+	if set.Order != nil {
+		return set.Order
+	}
+
+	if set.Params.Ordering != "" && set.Params.Ordering != staking.OrderByInput {
+		t.Fatalf("%q uses the %s ordering without an expected order", set.Name, set.Params.Ordering)
+	}
+
+	return set.Validators
+}
@@ -0,0 +1,68 @@
+package stakingtest
+
+import (
+	"math/big"
+	"math/rand"
+	"testing"
+
+	"github.com/0xPolygon/polygon-edge/helper/staking"
+	"github.com/0xPolygon/polygon-edge/types"
+)
+
+func TestEdgeCaseValidatorSets(t *testing.T) {
+	for _, set := range EdgeCaseValidatorSets() {
+		set := set
+
+		t.Run(set.Name, func(t *testing.T) {
+			harness := NewHarness(t, set, nil)
+
+			AssertValidatorSetCase(t, harness.State(), set)
+
+			// The staking SC views read the same set back
+			order := ExpectedOrder(t, set)
+
+			validators := harness.Validators(t)
+			if len(validators) != len(order) {
+				t.Fatalf("expected %d validators from validators(), got %d", len(order), len(validators))
+			}
+
+			for i, validator := range order {
+				if validators[i] != validator {
+					t.Errorf("validators() %d: expected %s, got %s", i, validator, validators[i])
+				}
+
+				if stake := harness.AccountStake(t, validator); stake.Cmp(caseStake(t, set, validator)) != 0 {
+					t.Errorf("accountStake(%s): expected %s, got %s", validator, caseStake(t, set, validator), stake)
+				}
+			}
+		})
+	}
+}
+
+func TestRandomValidatorSet(t *testing.T) {
+	set := RandomValidatorSet(rand.New(rand.NewSource(1)), 16)
+
+	if again := RandomValidatorSet(rand.New(rand.NewSource(1)), 16); again.Validators[15] != set.Validators[15] {
+		t.Fatal("expected the same set for the same seed")
+	}
+
+	AssertValidatorSetCase(t, NewHarness(t, set, nil).State(), set)
+}
+
+// caseStake returns the stake the validator set gives the validator
+func caseStake(t *testing.T, set ValidatorSetCase, validator types.Address) *big.Int {
+	t.Helper()
+
+	if stake, ok := set.Stakes[validator]; ok && stake != nil {
+		return stake
+	}
+
+	val := staking.DefaultStakedBalance
+
+	stake, err := types.ParseUint256orHex(&val)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return stake
+}
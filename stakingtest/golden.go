@@ -0,0 +1,294 @@
+package stakingtest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/0xPolygon/polygon-edge/chain"
+	"github.com/0xPolygon/polygon-edge/helper/keccak"
+	"github.com/0xPolygon/polygon-edge/helper/staking"
+	"github.com/0xPolygon/polygon-edge/types"
+)
+
+// GoldenDir is the default directory of the golden files, relative to the package under test
+var GoldenDir = filepath.Join("testdata", "golden")
+
+// UpdateGoldenEnv is the environment variable enabling the update mode when set to 1
+const UpdateGoldenEnv = "STAKING_UPDATE_GOLDEN"
+
+// goldenCatalogueSeeds are the seeds of the random validator sets of the catalogue.
+// Changing them changes the catalogue, so new seeds are only ever appended
+var goldenCatalogueSeeds = []int64{1, 2, 3}
+
+// UpdateGolden checks if the golden files should be rewritten,
+// with the STAKING_UPDATE_GOLDEN=1 environment variable
+func UpdateGolden() bool {
+	return os.Getenv(UpdateGoldenEnv) == "1"
+}
+
+// GoldenAccount is the stable serialization of a genesis account.
+// JSON objects are encoded with sorted keys, so the storage is ordered by slot
+type GoldenAccount struct {
+	Balance  string            `json:"balance"`
+	CodeHash string            `json:"codeHash"`
+	Storage  map[string]string `json:"storage"`
+}
+
+// NewGoldenAccount serializes the genesis account. Zero words are kept,
+// as they are part of the storage in the explicit storage mode
+func NewGoldenAccount(account *chain.GenesisAccount) *GoldenAccount {
+	golden := &GoldenAccount{
+		Balance:  "0x0",
+		CodeHash: types.BytesToHash(keccak.Keccak256(nil, account.Code)).String(),
+		Storage:  make(map[string]string, len(account.Storage)),
+	}
+
+	if account.Balance != nil {
+		golden.Balance = fmt.Sprintf("0x%x", account.Balance)
+	}
+
+	for key, value := range account.Storage {
+		golden.Storage[key.String()] = value.String()
+	}
+
+	return golden
+}
+
+// Marshal returns the golden file contents, indented JSON ending with a newline
+func (g *GoldenAccount) Marshal() ([]byte, error) {
+	encoded, err := json.MarshalIndent(g, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	return append(encoded, '\n'), nil
+}
+
+// Diff returns a line per difference between the golden account and the given one,
+// ordered by slot. No lines are returned if they are equal
+func (g *GoldenAccount) Diff(got *GoldenAccount) []string {
+	var diff []string
+
+	if g.Balance != got.Balance {
+		diff = append(diff, fmt.Sprintf("balance: want %s, got %s", g.Balance, got.Balance))
+	}
+
+	if g.CodeHash != got.CodeHash {
+		diff = append(diff, fmt.Sprintf("code hash: want %s, got %s", g.CodeHash, got.CodeHash))
+	}
+
+	slots := make([]string, 0, len(g.Storage)+len(got.Storage))
+	for slot := range g.Storage {
+		slots = append(slots, slot)
+	}
+
+	for slot := range got.Storage {
+		if _, ok := g.Storage[slot]; !ok {
+			slots = append(slots, slot)
+		}
+	}
+
+	sort.Strings(slots)
+
+	for _, slot := range slots {
+		want, inWant := g.Storage[slot]
+		value, inGot := got.Storage[slot]
+
+		switch {
+		case !inGot:
+			diff = append(diff, fmt.Sprintf("slot %s: want %s, missing", slot, want))
+		case !inWant:
+			diff = append(diff, fmt.Sprintf("slot %s: unexpected %s", slot, value))
+		case want != value:
+			diff = append(diff, fmt.Sprintf("slot %s: want %s, got %s", slot, want, value))
+		}
+	}
+
+	return diff
+}
+
+// goldenFileName turns the case name into a file name
+func goldenFileName(name string) string {
+	var b strings.Builder
+
+	for _, r := range strings.ToLower(name) {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '-', r == '_':
+			b.WriteRune(r)
+		default:
+			b.WriteRune('-')
+		}
+	}
+
+	return b.String() + ".json"
+}
+
+// AssertGolden compares the genesis account with the golden file of the case in the directory.
+// The test fails with a per-slot diff if they differ. In the update mode, the golden file is rewritten instead
+func AssertGolden(t testing.TB, dir, name string, account *chain.GenesisAccount) {
+	t.Helper()
+
+	path := filepath.Join(dir, goldenFileName(name))
+	got := NewGoldenAccount(account)
+
+	if UpdateGolden() {
+		encoded, err := got.Marshal()
+		if err != nil {
+			t.Fatalf("unable to encode the golden file of %q, %v", name, err)
+		}
+
+		if err := os.MkdirAll(dir, 0750); err != nil {
+			t.Fatalf("unable to create %s, %v", dir, err)
+		}
+
+		if err := os.WriteFile(path, encoded, 0600); err != nil {
+			t.Fatalf("unable to write %s, %v", path, err)
+		}
+
+		return
+	}
+
+	encoded, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("golden file %s doesn't exist, run the test with %s=1 to create it", path, UpdateGoldenEnv)
+	} else if err != nil {
+		t.Fatalf("unable to read %s, %v", path, err)
+	}
+
+	want := &GoldenAccount{}
+	if err := json.Unmarshal(encoded, want); err != nil {
+		t.Fatalf("unable to decode %s, %v", path, err)
+	}
+
+	if diff := want.Diff(got); len(diff) > 0 {
+		t.Errorf(
+			"genesis account of %q doesn't match %s, %d differences:\n  %s\n"+
+				"run the test with %s=1 if the change is intended",
+			name,
+			path,
+			len(diff),
+			strings.Join(diff, "\n  "),
+			UpdateGoldenEnv,
+		)
+	}
+}
+
+// GoldenCase is a named input of the golden catalogue, built through staking.BuildStakingGenesis
+// at the default addresses. The staking SC is compared with the golden file of the case name,
+// and the mock ERC721 SC, if the spec deploys it, with the one of the name suffixed with erc721
+type GoldenCase struct {
+	Name string
+	Spec staking.StakingGenesisSpec
+}
+
+// GoldenCatalogue returns the representative inputs of the predeploy covered by golden files:
+// the edge case validator sets, seeded random sets and the NFT staking cases
+func GoldenCatalogue() []GoldenCase {
+	sets := EdgeCaseValidatorSets()
+
+	for _, seed := range goldenCatalogueSeeds {
+		set := RandomValidatorSet(rand.New(rand.NewSource(seed)), 8)
+		set.Name = fmt.Sprintf("random seed %d", seed)
+
+		sets = append(sets, set)
+	}
+
+	catalogue := make([]GoldenCase, 0, len(sets))
+	for _, set := range sets {
+		catalogue = append(catalogue, GoldenCase{
+			Name: set.Name,
+			Spec: set.Spec(),
+		})
+	}
+
+	return append(catalogue, nftGoldenCases()...)
+}
+
+// nftGoldenCases returns the NFT staking inputs of the catalogue: staked tokens of every weight,
+// the token count ordering, and tokens held outside of the staking SC, up to the largest token ID
+func nftGoldenCases() []GoldenCase {
+	maxUint256 := new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 256), big.NewInt(1))
+
+	return []GoldenCase{
+		{
+			Name: "nft staked tokens",
+			Spec: staking.StakingGenesisSpec{
+				Params: staking.PredeployParams{
+					MinValidatorCount: 1,
+					MaxValidatorCount: 3,
+				},
+				Validators: SequentialValidators(3),
+				StakedTokens: map[types.Address][]*big.Int{
+					SequentialAddress(1): {big.NewInt(1), big.NewInt(2)},
+					SequentialAddress(2): {big.NewInt(3)},
+					SequentialAddress(3): {big.NewInt(4), big.NewInt(5), big.NewInt(6)},
+				},
+			},
+		},
+		{
+			Name: "nft ordered by token count with holders",
+			Spec: staking.StakingGenesisSpec{
+				Params: staking.PredeployParams{
+					MinValidatorCount: 1,
+					MaxValidatorCount: 4,
+					Ordering:          staking.OrderByStake,
+				},
+				Validators: SequentialValidators(3),
+				StakedTokens: map[types.Address][]*big.Int{
+					SequentialAddress(1): {big.NewInt(10)},
+					SequentialAddress(2): {big.NewInt(11), big.NewInt(12), big.NewInt(13)},
+					SequentialAddress(3): {big.NewInt(14), big.NewInt(15)},
+				},
+				NFTHolders: map[types.Address][]*big.Int{
+					SequentialAddress(1):    {big.NewInt(20)},
+					SequentialAddress(0x10): {big.NewInt(21), maxUint256},
+				},
+			},
+		},
+	}
+}
+
+// goldenAccountName returns the golden file name of a genesis account of the case
+func goldenAccountName(t testing.TB, c GoldenCase, address types.Address) string {
+	t.Helper()
+
+	switch address {
+	case staking.DefaultStakingSCAddress:
+		return c.Name
+	case staking.DefaultNFTSCAddress:
+		return c.Name + " erc721"
+	default:
+		t.Fatalf("%q builds the account %s, which has no golden file", c.Name, address)
+
+		return ""
+	}
+}
+
+// AssertGoldenCatalogue runs a subtest per catalogue case, comparing the
+// genesis accounts of the case with the golden files in the directory
+func AssertGoldenCatalogue(t *testing.T, dir string) {
+	t.Helper()
+
+	for _, c := range GoldenCatalogue() {
+		c := c
+
+		t.Run(c.Name, func(t *testing.T) {
+			accounts, err := staking.BuildStakingGenesis(c.Spec, nil)
+			if err != nil {
+				t.Fatalf("unable to build the genesis of %q, %v", c.Name, err)
+			}
+
+			for address, account := range accounts {
+				AssertGolden(t, dir, goldenAccountName(t, c, address), account)
+			}
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package stakingtest
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/0xPolygon/polygon-edge/helper/staking"
+	"github.com/0xPolygon/polygon-edge/types"
+)
+
+// recordingTB records the errors of the assertions instead of failing the test
+type recordingTB struct {
+	testing.TB
+
+	errors []string
+}
+
+func (r *recordingTB) Helper() {}
+
+func (r *recordingTB) Errorf(format string, args ...interface{}) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func TestGoldenCatalogue(t *testing.T) {
+	AssertGoldenCatalogue(t, GoldenDir)
+}
+
+func TestAssertGoldenDiff(t *testing.T) {
+	var (
+		set     = EdgeCaseValidatorSets()[0]
+		account = GenesisAccount(t, set)
+		dir     = t.TempDir()
+	)
+
+	encoded, err := NewGoldenAccount(account).Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, goldenFileName(set.Name)), encoded, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		indexes   = staking.GetStorageIndexes(types.ZeroAddress, 0)
+		sizeSlot  = types.BytesToHash(indexes.ValidatorsArraySizeIndex)
+		totalSlot = types.BytesToHash(indexes.StakedAmountIndex)
+		extraSlot = types.BytesToHash([]byte{0xde, 0xad})
+	)
+
+	// Change a word, drop a word and add an unexpected one
+	account.Storage[sizeSlot] = types.BytesToHash([]byte{0x2})
+	delete(account.Storage, totalSlot)
+	account.Storage[extraSlot] = types.BytesToHash([]byte{0x1})
+
+	recorder := &recordingTB{TB: t}
+	AssertGolden(recorder, dir, set.Name, account)
+
+	if len(recorder.errors) != 1 {
+		t.Fatalf("expected a single error, got %d", len(recorder.errors))
+	}
+
+	for _, line := range []string{
+		fmt.Sprintf("slot %s: want %s, got %s", sizeSlot, types.BytesToHash([]byte{0x1}), types.BytesToHash([]byte{0x2})),
+		fmt.Sprintf("slot %s: want ", totalSlot),
+		fmt.Sprintf("slot %s: unexpected %s", extraSlot, types.BytesToHash([]byte{0x1})),
+		"3 differences",
+		UpdateGoldenEnv + "=1",
+	} {
+		if !strings.Contains(recorder.errors[0], line) {
+			t.Errorf("expected the diff to contain %q, got:\n%s", line, recorder.errors[0])
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package stakingtest
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/0xPolygon/polygon-edge/chain"
+	"github.com/0xPolygon/polygon-edge/helper/staking"
+	"github.com/0xPolygon/polygon-edge/types"
+)
+
+// Harness is an in-memory EVM preloaded with the staking SC predeploy
+type Harness struct {
+	Genesis        *chain.Genesis
+	StakingAddress types.Address
+	EVM            *staking.GenesisEVM
+}
+
+// NewHarness predeploys the staking SC for the validator set at the staking.DefaultStakingSCAddress,
+// and opens an in-memory EVM over the genesis. The extra accounts are added to the alloc
+func NewHarness(
+	t testing.TB,
+	set ValidatorSetCase,
+	extraAccounts map[types.Address]*chain.GenesisAccount,
+) *Harness {
+	t.Helper()
+
+	genesis := &chain.Genesis{
+		Config: &chain.Params{
+			Forks: chain.AllForksEnabled,
+		},
+		Alloc: map[types.Address]*chain.GenesisAccount{
+			staking.DefaultStakingSCAddress: GenesisAccount(t, set),
+		},
+	}
+
+	for address, account := range extraAccounts {
+		if _, ok := genesis.Alloc[address]; ok {
+			t.Fatalf("%s: %v", address, staking.ErrAllocCollision)
+		}
+
+		genesis.Alloc[address] = account
+	}
+
+	return NewHarnessFromGenesis(t, genesis, staking.DefaultStakingSCAddress)
+}
+
+// NewHarnessFromGenesis opens an in-memory EVM over a genesis
+// that already contains the staking SC at the given address
+func NewHarnessFromGenesis(t testing.TB, genesis *chain.Genesis, stakingAddress types.Address) *Harness {
+	t.Helper()
+
+	if _, ok := genesis.Alloc[stakingAddress]; !ok {
+		t.Fatalf("staking SC %s is not in the genesis alloc", stakingAddress)
+	}
+
+	evm, err := staking.NewGenesisEVM(genesis)
+	if err != nil {
+		t.Fatalf("unable to create the genesis EVM, %v", err)
+	}
+
+	return &Harness{
+		Genesis:        genesis,
+		StakingAddress: stakingAddress,
+		EVM:            evm,
+	}
+}
+
+// State returns a StateReader over the current staking SC storage,
+// including the changes of the calls executed so far
+func (h *Harness) State() staking.StateReader {
+	return h.EVM.Storage(h.StakingAddress)
+}
+
+// Call executes a call to the staking SC, and fails the test if it reverts
+func (h *Harness) Call(t testing.TB, from types.Address, input []byte) []byte {
+	t.Helper()
+
+	output, err := h.EVM.Call(from, h.StakingAddress, input)
+	if err != nil {
+		t.Fatalf("staking SC call from %s failed, %v", from, err)
+	}
+
+	return output
+}
+
+// View executes the view calls, and fails the test if any of them fails
+func (h *Harness) View(t testing.TB, calls ...*staking.ViewCall) {
+	t.Helper()
+
+	if err := h.EVM.View(types.ZeroAddress, h.StakingAddress, calls...); err != nil {
+		t.Fatalf("staking SC view failed, %v", err)
+	}
+}
+
+// Validators returns the validators() of the staking SC
+func (h *Harness) Validators(t testing.TB) []types.Address {
+	t.Helper()
+
+	var validators []types.Address
+
+	h.View(t, staking.ValidatorsCall(&validators))
+
+	return validators
+}
+
+// AccountStake returns the accountStake(address) of the staking SC
+func (h *Harness) AccountStake(t testing.TB, address types.Address) *big.Int {
+	t.Helper()
+
+	stake := new(big.Int)
+
+	h.View(t, staking.AccountStakeCall(address, stake))
+
+	return stake
+}
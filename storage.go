@@ -0,0 +1,90 @@
+package staking
+
+import (
+	"fmt"
+
+	"github.com/0xPolygon/polygon-edge/types"
+)
+
+// StorageMode is the policy for writing zero words to the genesis storage
+type StorageMode string
+
+const (
+	// StorageModeExplicit writes every slot the staking SC would touch,
+	// zero words included. The zero value of StorageMode behaves the same way
+	StorageModeExplicit StorageMode = "explicit"
+
+	// StorageModeSparse drops zero words, as the EVM reads missing slots as zero
+	StorageModeSparse StorageMode = "sparse"
+
+	// CanonicalStorageMode is the storage mode parties should agree on
+	// when independently building the same genesis
+	CanonicalStorageMode = StorageModeSparse
+)
+
+// ParseStorageMode parses the name of a storage mode
+func ParseStorageMode(name string) (StorageMode, error) {
+	switch mode := StorageMode(name); mode {
+	case "", StorageModeExplicit:
+		return StorageModeExplicit, nil
+	case StorageModeSparse:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("unknown storage mode %q", name)
+	}
+}
+
+// String returns the name of the storage mode
+func (m StorageMode) String() string {
+	if m == "" {
+		return string(StorageModeExplicit)
+	}
+
+	return string(m)
+}
+
+// applyStorageMode returns the storage in the layout of the given mode
+func applyStorageMode(
+	storage map[types.Hash]types.Hash,
+	mode StorageMode,
+) (map[types.Hash]types.Hash, error) {
+	switch mode {
+	case "", StorageModeExplicit:
+		return storage, nil
+	case StorageModeSparse:
+		return SparseStorage(storage), nil
+	default:
+		return nil, fmt.Errorf("unknown storage mode %q", string(mode))
+	}
+}
+
+// SparseStorage returns a copy of the storage without the zero words
+func SparseStorage(storage map[types.Hash]types.Hash) map[types.Hash]types.Hash {
+	sparse := make(map[types.Hash]types.Hash, len(storage))
+
+	for key, value := range storage {
+		if value != types.ZeroHash {
+			sparse[key] = value
+		}
+	}
+
+	return sparse
+}
+
+// StorageEqual checks if two storages hold the same values.
+// A missing slot and a slot holding a zero word are considered equal
+func StorageEqual(a, b map[types.Hash]types.Hash) bool {
+	for key, value := range a {
+		if b[key] != value {
+			return false
+		}
+	}
+
+	for key, value := range b {
+		if a[key] != value {
+			return false
+		}
+	}
+
+	return true
+}
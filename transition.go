@@ -0,0 +1,227 @@
+package staking
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+
+	"github.com/0xPolygon/polygon-edge/chain"
+	"github.com/0xPolygon/polygon-edge/types"
+)
+
+var (
+	errNoSigners          = errors.New("PoA snapshot has no signers")
+	errTransitionOrdering = errors.New("the PoS transition keeps the signer order, the ordering policy must be input")
+)
+
+// TransitionPolicy decides how the PoA signers are staked at the PoS transition
+type TransitionPolicy interface {
+	// Name returns the name of the policy
+	Name() string
+
+	// Spec returns the staking spec of the signers. The validators, the staking address
+	// and the params are filled in by NewPoSTransition
+	Spec(signers []types.Address) (StakingGenesisSpec, error)
+}
+
+// EqualStakePolicy stakes the same amount for every signer
+type EqualStakePolicy struct {
+	// Stake is the staked amount of every signer.
+	// DefaultStakedBalance is used if it's not set
+	Stake *big.Int
+}
+
+// Name implements the TransitionPolicy interface
+func (p *EqualStakePolicy) Name() string {
+	return "equal-stake"
+}
+
+// Spec implements the TransitionPolicy interface
+func (p *EqualStakePolicy) Spec(signers []types.Address) (StakingGenesisSpec, error) {
+	spec := StakingGenesisSpec{}
+
+	if p.Stake == nil {
+		return spec, nil
+	}
+
+	if p.Stake.Sign() <= 0 {
+		return spec, fmt.Errorf("stake must be positive, got %s", p.Stake)
+	}
+
+	spec.Stakes = make(map[types.Address]*big.Int, len(signers))
+	for _, signer := range signers {
+		spec.Stakes[signer] = new(big.Int).Set(p.Stake)
+	}
+
+	return spec, nil
+}
+
+// TokenStakePolicy stakes mock ERC721 tokens for every signer.
+// The mock ERC721 SC is deployed at the transition, with the staked tokens held by the staking SC
+type TokenStakePolicy struct {
+	// NFTAddress is the address of the mock ERC721 SC.
+	// DefaultNFTSCAddress is used if it's not set
+	NFTAddress types.Address
+
+	// TokenIDs maps every signer to the token IDs it stakes
+	TokenIDs map[types.Address][]*big.Int
+
+	// Holders maps an account to the token IDs it holds in its own wallet
+	Holders map[types.Address][]*big.Int
+}
+
+// Name implements the TransitionPolicy interface
+func (p *TokenStakePolicy) Name() string {
+	return "token-stake"
+}
+
+// Spec implements the TransitionPolicy interface.
+// The stakes are zero, so a signer's stake is its token count
+func (p *TokenStakePolicy) Spec(signers []types.Address) (StakingGenesisSpec, error) {
+	spec := StakingGenesisSpec{
+		NFTAddress:   p.NFTAddress,
+		StakedTokens: p.TokenIDs,
+		NFTHolders:   p.Holders,
+		Stakes:       make(map[types.Address]*big.Int, len(signers)),
+	}
+
+	if spec.NFTAddress == types.ZeroAddress {
+		spec.NFTAddress = DefaultNFTSCAddress
+	}
+
+	for _, signer := range signers {
+		if len(p.TokenIDs[signer]) == 0 {
+			return spec, fmt.Errorf("signer %s has no tokens to stake", signer)
+		}
+
+		spec.Stakes[signer] = big.NewInt(0)
+	}
+
+	return spec, nil
+}
+
+// TransitionWriter is the part of the state the PoS transition writes to.
+// polygon-edge's state transaction satisfies it
+type TransitionWriter interface {
+	StateWriter
+	AddBalance(addr types.Address, balance *big.Int)
+}
+
+// StakingTransition is the state override installing the staking SC at the PoS transition block
+type StakingTransition struct {
+	Block          uint64        `json:"block"`
+	Policy         string        `json:"policy"`
+	StakingAddress types.Address `json:"stakingAddress"`
+
+	// Validators is the validator set after the transition, the PoA signers in snapshot order
+	Validators []types.Address `json:"validators"`
+
+	// Accounts are the accounts installed at the transition, the staking SC
+	// and the mock ERC721 SC for the token stake policy, in the storage mode of the params
+	Accounts map[types.Address]*chain.GenesisAccount `json:"accounts"`
+
+	// MintStake credits the accounts with their balances at the transition, the staked amounts
+	// for the staking SC. The signers never paid them, so on a live chain the credit creates
+	// new coins. Without it, the staking SC holds nothing for the stakes of the transition,
+	// and unstaking them fails until the SC is funded some other way
+	MintStake bool `json:"mintStake"`
+}
+
+// NewPoSTransition builds the staking SC that replaces the PoA signer snapshot at the given block.
+// The signers keep their snapshot order in _validators, and the resulting validator set
+// is checked against the snapshot, so the validator set is unchanged across the switch.
+// The storage of the accounts is written in the storage mode of the params
+func NewPoSTransition(
+	block uint64,
+	stakingAddress types.Address,
+	signers []types.Address,
+	policy TransitionPolicy,
+	params PredeployParams,
+) (*StakingTransition, error) {
+	if len(signers) == 0 {
+		return nil, errNoSigners
+	}
+
+	if params.Ordering != "" && params.Ordering != OrderByInput {
+		return nil, fmt.Errorf("%w, got %s", errTransitionOrdering, params.Ordering)
+	}
+
+	spec, err := policy.Spec(signers)
+	if err != nil {
+		return nil, fmt.Errorf("unable to apply the %s policy, %w", policy.Name(), err)
+	}
+
+	spec.StakingAddress = stakingAddress
+	spec.Validators = signers
+	spec.Params = params
+
+	accounts, err := BuildStakingGenesis(spec, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	stakingAddress = spec.stakingAddress()
+
+	validators, err := NewStakingView(NewGenesisAccountReader(accounts[stakingAddress])).Validators()
+	if err != nil {
+		return nil, err
+	}
+
+	if report := CompareValidatorLists(signers, validators); !report.Consistent() {
+		return nil, fmt.Errorf("staking SC validators don't match the PoA snapshot:\n%s", report)
+	}
+
+	return &StakingTransition{
+		Block:          block,
+		Policy:         policy.Name(),
+		StakingAddress: stakingAddress,
+		Validators:     validators,
+		Accounts:       accounts,
+	}, nil
+}
+
+// Apply installs the accounts if the block is the transition block.
+// It's meant to be called from the state override hook at the start of every block.
+// The addresses of the accounts must be empty before the transition: the code and the
+// storage words of the accounts are written over the existing ones, but any other
+// storage word stays in place. The balances are only added to the existing ones with MintStake.
+// The explicit storage mode at least overwrites every slot the staking SC reads with its own value
+func (t *StakingTransition) Apply(writer TransitionWriter, block uint64) {
+	if block != t.Block {
+		return
+	}
+
+	addresses := make([]types.Address, 0, len(t.Accounts))
+	for address := range t.Accounts {
+		addresses = append(addresses, address)
+	}
+
+	sort.Slice(addresses, func(i, j int) bool {
+		return bytes.Compare(addresses[i].Bytes(), addresses[j].Bytes()) < 0
+	})
+
+	for _, address := range addresses {
+		account := t.Accounts[address]
+
+		writer.SetCode(address, account.Code)
+
+		keys := make([]types.Hash, 0, len(account.Storage))
+		for key := range account.Storage {
+			keys = append(keys, key)
+		}
+
+		sort.Slice(keys, func(i, j int) bool {
+			return bytes.Compare(keys[i].Bytes(), keys[j].Bytes()) < 0
+		})
+
+		for _, key := range keys {
+			writer.SetState(address, key, account.Storage[key])
+		}
+
+		if t.MintStake && account.Balance != nil && account.Balance.Sign() > 0 {
+			writer.AddBalance(address, account.Balance)
+		}
+	}
+}
@@ -0,0 +1,244 @@
+package staking
+
+import (
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/0xPolygon/polygon-edge/helper/hex"
+	"github.com/0xPolygon/polygon-edge/types"
+)
+
+// transitionTestWriter records the state written by the PoS transition
+type transitionTestWriter struct {
+	code    map[types.Address][]byte
+	storage map[types.Address]map[types.Hash]types.Hash
+	balance map[types.Address]*big.Int
+}
+
+func newTransitionTestWriter() *transitionTestWriter {
+	return &transitionTestWriter{
+		code:    make(map[types.Address][]byte),
+		storage: make(map[types.Address]map[types.Hash]types.Hash),
+		balance: make(map[types.Address]*big.Int),
+	}
+}
+
+func (w *transitionTestWriter) SetCode(addr types.Address, code []byte) {
+	w.code[addr] = code
+}
+
+func (w *transitionTestWriter) SetState(addr types.Address, key, value types.Hash) {
+	if w.storage[addr] == nil {
+		w.storage[addr] = make(map[types.Hash]types.Hash)
+	}
+
+	w.storage[addr][key] = value
+}
+
+func (w *transitionTestWriter) AddBalance(addr types.Address, balance *big.Int) {
+	if w.balance[addr] == nil {
+		w.balance[addr] = big.NewInt(0)
+	}
+
+	w.balance[addr].Add(w.balance[addr], balance)
+}
+
+func TestPoSTransitionStorageMode(t *testing.T) {
+	signers := generatorValidators(3)
+
+	for _, mode := range []StorageMode{StorageModeExplicit, StorageModeSparse} {
+		mode := mode
+
+		t.Run(mode.String(), func(t *testing.T) {
+			params := PredeployParams{
+				MinValidatorCount: 1,
+				MaxValidatorCount: 3,
+				StorageMode:       mode,
+			}
+
+			transition, err := NewPoSTransition(10, DefaultStakingSCAddress, signers, &EqualStakePolicy{}, params)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			predeploy, err := PredeployStakingSC(signers, params)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// The first signer's index is a zero word, which only the explicit mode keeps
+			assertSameAccount(t, predeploy, transition.Accounts[DefaultStakingSCAddress])
+
+			writer := newTransitionTestWriter()
+
+			transition.Apply(writer, 9)
+
+			if len(writer.code) != 0 {
+				t.Fatal("expected nothing to be written before the transition block")
+			}
+
+			transition.Apply(writer, 10)
+
+			if !StorageEqual(writer.storage[DefaultStakingSCAddress], predeploy.Storage) {
+				t.Fatal("expected the staking SC storage to be written at the transition block")
+			}
+		})
+	}
+}
+
+func TestPoSTransitionEqualStake(t *testing.T) {
+	signers := generatorValidators(3)
+	params := PredeployParams{
+		MinValidatorCount: 1,
+		MaxValidatorCount: 3,
+	}
+
+	for _, stake := range []*big.Int{big.NewInt(0), big.NewInt(-1)} {
+		if _, err := NewPoSTransition(10, DefaultStakingSCAddress, signers, &EqualStakePolicy{
+			Stake: stake,
+		}, params); err == nil || !strings.Contains(err.Error(), "stake must be positive") {
+			t.Fatalf("expected the invalid stake %s to be rejected, got %v", stake, err)
+		}
+	}
+
+	transition, err := NewPoSTransition(10, DefaultStakingSCAddress, signers, &EqualStakePolicy{
+		Stake: big.NewInt(7),
+	}, params)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	view := NewStakingView(NewGenesisAccountReader(transition.Accounts[DefaultStakingSCAddress]))
+
+	for _, signer := range signers {
+		if stake, err := view.StakeOf(signer); err != nil || stake.Cmp(big.NewInt(7)) != 0 {
+			t.Fatalf("expected %s to stake 7, got %v, %v", signer, stake, err)
+		}
+	}
+
+	if total, err := view.TotalStaked(); err != nil || total.Cmp(big.NewInt(21)) != 0 {
+		t.Fatalf("expected the total staked amount 21, got %v, %v", total, err)
+	}
+
+	// The staked amounts are only credited to the staking SC on demand
+	writer := newTransitionTestWriter()
+	transition.Apply(writer, 10)
+
+	if len(writer.balance) != 0 {
+		t.Fatalf("expected no balance to be credited, got %v", writer.balance)
+	}
+
+	transition.MintStake = true
+
+	writer = newTransitionTestWriter()
+	transition.Apply(writer, 10)
+
+	if balance := writer.balance[DefaultStakingSCAddress]; balance == nil || balance.Cmp(big.NewInt(21)) != 0 {
+		t.Fatalf("expected the staking SC to be credited with 21, got %v", balance)
+	}
+}
+
+func TestPoSTransitionTokenStake(t *testing.T) {
+	signers := generatorValidators(3)
+	holder := types.StringToAddress("0xbeef")
+	params := PredeployParams{
+		MinValidatorCount: 1,
+		MaxValidatorCount: 3,
+	}
+
+	policy := &TokenStakePolicy{
+		TokenIDs: map[types.Address][]*big.Int{
+			signers[0]: {big.NewInt(1)},
+			signers[1]: {big.NewInt(2), big.NewInt(3)},
+			signers[2]: {big.NewInt(4)},
+		},
+		Holders: map[types.Address][]*big.Int{
+			holder: {big.NewInt(5)},
+		},
+	}
+
+	transition, err := NewPoSTransition(10, DefaultStakingSCAddress, signers, policy, params)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if transition.Policy != "token-stake" || len(transition.Accounts) != 2 {
+		t.Fatalf("expected the staking and the ERC721 SCs, got %d accounts", len(transition.Accounts))
+	}
+
+	// The signers keep their snapshot order, even with different token counts
+	for i, validator := range transition.Validators {
+		if validator != signers[i] {
+			t.Fatalf("validator %d: expected %s, got %s", i, signers[i], validator)
+		}
+	}
+
+	staking := NewStakingView(NewGenesisAccountReader(transition.Accounts[DefaultStakingSCAddress]))
+
+	if stake, err := staking.StakeOf(signers[1]); err != nil || stake.Cmp(big.NewInt(2)) != 0 {
+		t.Fatalf("expected a stake of 2 tokens, got %v, %v", stake, err)
+	}
+
+	if staker, err := staking.TokenOwner(big.NewInt(3)); err != nil || staker != signers[1] {
+		t.Fatalf("expected token 3 to be staked by %s, got %s, %v", signers[1], staker, err)
+	}
+
+	if nftAddress, err := staking.NFTAddress(); err != nil || nftAddress != DefaultNFTSCAddress {
+		t.Fatalf("expected the NFT address %s, got %s, %v", DefaultNFTSCAddress, nftAddress, err)
+	}
+
+	// The ERC721 SC is deployed, with the staked tokens held by the staking SC
+	nft := transition.Accounts[DefaultNFTSCAddress]
+	if nft == nil || hex.EncodeToHex(nft.Code) != ERC721SCBytecode {
+		t.Fatal("expected the ERC721 SC to be deployed")
+	}
+
+	owners := map[int64]types.Address{
+		2: DefaultStakingSCAddress,
+		5: holder,
+	}
+
+	for tokenID, owner := range owners {
+		slot := types.BytesToHash(getUint256Mapping(big.NewInt(tokenID), erc721OwnersSlot))
+		if nft.Storage[slot] != types.BytesToHash(owner.Bytes()) {
+			t.Fatalf("expected token %d to be owned by %s, got %s", tokenID, owner, nft.Storage[slot])
+		}
+	}
+
+	writer := newTransitionTestWriter()
+	transition.Apply(writer, 10)
+
+	if !StorageEqual(writer.storage[DefaultNFTSCAddress], nft.Storage) {
+		t.Fatal("expected the ERC721 SC storage to be written at the transition block")
+	}
+
+	// Every signer has to stake a token
+	delete(policy.TokenIDs, signers[2])
+
+	_, err = NewPoSTransition(10, DefaultStakingSCAddress, signers, policy, params)
+	if err == nil || !strings.Contains(err.Error(), "signer "+signers[2].String()+" has no tokens to stake") {
+		t.Fatalf("expected the signer without tokens to be reported, got %v", err)
+	}
+}
+
+func TestPoSTransitionOrdering(t *testing.T) {
+	signers := generatorValidators(2)
+
+	for _, ordering := range []ValidatorOrdering{"", OrderByInput, OrderByAddress, OrderByStake} {
+		_, err := NewPoSTransition(10, DefaultStakingSCAddress, signers, &EqualStakePolicy{}, PredeployParams{
+			MinValidatorCount: 1,
+			MaxValidatorCount: 2,
+			Ordering:          ordering,
+		})
+
+		if rejected := ordering == OrderByAddress || ordering == OrderByStake; rejected {
+			if !errors.Is(err, errTransitionOrdering) {
+				t.Fatalf("expected the %q ordering to be rejected, got %v", ordering, err)
+			}
+		} else if err != nil {
+			t.Fatalf("expected the %q ordering to be accepted, got %v", ordering, err)
+		}
+	}
+}
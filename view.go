@@ -0,0 +1,123 @@
+package staking
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/0xPolygon/polygon-edge/types"
+)
+
+// MaxViewValidatorCount is the largest _validators length the view reads.
+// The array length is read from the storage, so it's capped before
+// the validators are allocated and read one by one
+var MaxViewValidatorCount uint64 = 1 << 20
+
+// StakingView decodes the staking SC state from any StateReader,
+// using the SC storage layout
+type StakingView struct {
+	reader StateReader
+}
+
+// NewStakingView creates a view over the staking SC storage
+func NewStakingView(reader StateReader) *StakingView {
+	return &StakingView{
+		reader: reader,
+	}
+}
+
+// word reads the storage word at the given index as an integer
+func (v *StakingView) word(index []byte) (*big.Int, error) {
+	return readWord(v.reader, index)
+}
+
+// Validators returns the validator set, in _validators order
+func (v *StakingView) Validators() ([]types.Address, error) {
+	count, err := v.word(big.NewInt(validatorsSlot).Bytes())
+	if err != nil {
+		return nil, err
+	}
+
+	if !count.IsUint64() || count.Uint64() > MaxValidatorCount {
+		return nil, fmt.Errorf("invalid validator count %s", count)
+	}
+
+	if count.Uint64() > MaxViewValidatorCount {
+		return nil, fmt.Errorf("validator count %s is above the limit of %d", count, MaxViewValidatorCount)
+	}
+
+	validators := make([]types.Address, count.Uint64())
+
+	for i := range validators {
+		validator, err := v.word(getStorageIndexes(types.ZeroAddress, int64(i)).ValidatorsIndex)
+		if err != nil {
+			return nil, err
+		}
+
+		validators[i] = types.BytesToAddress(validator.Bytes())
+	}
+
+	return validators, nil
+}
+
+// IsValidator checks if the address is in the validator set
+func (v *StakingView) IsValidator(address types.Address) (bool, error) {
+	isValidator, err := v.word(getAddressMapping(address, addressToIsValidatorSlot))
+	if err != nil {
+		return false, err
+	}
+
+	return isValidator.Sign() != 0, nil
+}
+
+// StakeOf returns the staked amount of the address
+func (v *StakingView) StakeOf(address types.Address) (*big.Int, error) {
+	return v.word(getAddressMapping(address, addressToStakedAmountSlot))
+}
+
+// WeightOf returns the weight of the NFTs staked by the address
+func (v *StakingView) WeightOf(address types.Address) (*big.Int, error) {
+	return v.word(getAddressMapping(address, addressToWeightSlot))
+}
+
+// TokenOwner returns the staker of the NFT, or the zero address if the token isn't staked
+func (v *StakingView) TokenOwner(tokenID *big.Int) (types.Address, error) {
+	owner, err := v.word(getUint256Mapping(tokenID, tokenIDToOwnerSlot))
+	if err != nil {
+		return types.ZeroAddress, err
+	}
+
+	return types.BytesToAddress(owner.Bytes()), nil
+}
+
+// NFTAddress returns the address of the NFT contract last used for staking
+func (v *StakingView) NFTAddress() (types.Address, error) {
+	address, err := v.word(big.NewInt(nftAddressSlot).Bytes())
+	if err != nil {
+		return types.ZeroAddress, err
+	}
+
+	return types.BytesToAddress(address.Bytes()), nil
+}
+
+// TotalStaked returns the total staked amount
+func (v *StakingView) TotalStaked() (*big.Int, error) {
+	return v.word(big.NewInt(stakedAmountSlot).Bytes())
+}
+
+// Bounds returns the validator count bounds
+func (v *StakingView) Bounds() (PredeployParams, error) {
+	minimum, err := v.word(big.NewInt(minNumValidatorSlot).Bytes())
+	if err != nil {
+		return PredeployParams{}, err
+	}
+
+	maximum, err := v.word(big.NewInt(maxNumValidatorSlot).Bytes())
+	if err != nil {
+		return PredeployParams{}, err
+	}
+
+	return PredeployParams{
+		MinValidatorCount: minimum.Uint64(),
+		MaxValidatorCount: maximum.Uint64(),
+	}, nil
+}
@@ -0,0 +1,377 @@
+package staking
+
+import (
+	"context"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/0xPolygon/polygon-edge/types"
+)
+
+const (
+	// DefaultWatcherPollInterval is the default interval between two polls of the endpoint
+	DefaultWatcherPollInterval = 2 * time.Second
+
+	// defaultWatcherEventBuffer is the default capacity of the event channel
+	defaultWatcherEventBuffer = 64
+)
+
+// ValidatorSetEvent is a change of the validator set, published by the ValidatorWatcher.
+// It's one of ValidatorAdded, ValidatorRemoved or StakeChanged
+type ValidatorSetEvent interface {
+	// Block returns the number of the block the change was observed at
+	Block() uint64
+}
+
+// ValidatorAdded is published when an address joins the validator set
+type ValidatorAdded struct {
+	Address     types.Address
+	Stake       *big.Int
+	BlockNumber uint64
+}
+
+// Block implements the ValidatorSetEvent interface
+func (e *ValidatorAdded) Block() uint64 {
+	return e.BlockNumber
+}
+
+// ValidatorRemoved is published when an address leaves the validator set
+type ValidatorRemoved struct {
+	Address     types.Address
+	BlockNumber uint64
+}
+
+// Block implements the ValidatorSetEvent interface
+func (e *ValidatorRemoved) Block() uint64 {
+	return e.BlockNumber
+}
+
+// StakeChanged is published when the stake of a validator that stays in the set changes
+type StakeChanged struct {
+	Address     types.Address
+	OldStake    *big.Int
+	NewStake    *big.Int
+	BlockNumber uint64
+}
+
+// Block implements the ValidatorSetEvent interface
+func (e *StakeChanged) Block() uint64 {
+	return e.BlockNumber
+}
+
+// WatcherConfig is the configuration of the ValidatorWatcher
+type WatcherConfig struct {
+	// Endpoint is the JSON-RPC endpoint of the node
+	Endpoint string
+
+	// StakingAddress is the address of the staking SC
+	StakingAddress types.Address
+
+	// PollInterval is the interval between two polls of the endpoint.
+	// DefaultWatcherPollInterval is used if it's not set
+	PollInterval time.Duration
+
+	// EventBuffer is the capacity of the event channel
+	EventBuffer int
+
+	// HTTPClient is the client used for the JSON-RPC requests.
+	// A default one with a timeout is used if it's not set
+	HTTPClient *http.Client
+
+	// MaxRetryBackoff is the upper bound of the exponentially growing delay between
+	// the retries of a failed poll. DefaultMaxRetryBackoff is used if it's not set
+	MaxRetryBackoff time.Duration
+
+	// MaxRetries is the number of consecutive failed polls after which Run returns the error.
+	// Failed polls are retried until the context is done if it's not set
+	MaxRetries int
+}
+
+// ValidatorWatcher follows the Staked and Unstaked logs of the staking SC,
+// keeps an in-memory copy of the validator set and publishes its changes.
+// The set is read back from the SC whenever a block range contains staking logs,
+// so the published events always match the on-chain state.
+// The hash of the synced block is tracked, and the set is read again at the head
+// when that block is reorganized away. It's safe to read the set while the watcher is running
+type ValidatorWatcher struct {
+	config    WatcherConfig
+	transport *jsonRPCTransport
+	client    *StakingClient
+	events    chan ValidatorSetEvent
+
+	lock       sync.RWMutex
+	validators []types.Address
+	stakes     map[types.Address]*big.Int
+	block      uint64
+	hash       types.Hash
+	synced     bool
+}
+
+// NewValidatorWatcher creates a watcher with the given configuration
+func NewValidatorWatcher(config WatcherConfig) *ValidatorWatcher {
+	if config.PollInterval <= 0 {
+		config.PollInterval = DefaultWatcherPollInterval
+	}
+
+	if config.EventBuffer <= 0 {
+		config.EventBuffer = defaultWatcherEventBuffer
+	}
+
+	transport := newJSONRPCTransport(config.Endpoint, config.HTTPClient)
+
+	return &ValidatorWatcher{
+		config:    config,
+		transport: transport,
+		client: &StakingClient{
+			transport: transport,
+			address:   config.StakingAddress,
+		},
+		events: make(chan ValidatorSetEvent, config.EventBuffer),
+		stakes: make(map[types.Address]*big.Int),
+	}
+}
+
+// Events returns the channel the changes of the validator set are published on.
+// It's closed when Run returns
+func (w *ValidatorWatcher) Events() <-chan ValidatorSetEvent {
+	return w.events
+}
+
+// Validators returns a copy of the current validator set, in _validators order
+func (w *ValidatorWatcher) Validators() []types.Address {
+	w.lock.RLock()
+	defer w.lock.RUnlock()
+
+	validators := make([]types.Address, len(w.validators))
+	copy(validators, w.validators)
+
+	return validators
+}
+
+// IsValidator checks if the address is in the current validator set
+func (w *ValidatorWatcher) IsValidator(address types.Address) bool {
+	w.lock.RLock()
+	defer w.lock.RUnlock()
+
+	_, ok := w.stakes[address]
+
+	return ok
+}
+
+// StakeOf returns the stake of the validator, or nil if the address isn't a validator
+func (w *ValidatorWatcher) StakeOf(address types.Address) *big.Int {
+	w.lock.RLock()
+	defer w.lock.RUnlock()
+
+	stake, ok := w.stakes[address]
+	if !ok {
+		return nil
+	}
+
+	return new(big.Int).Set(stake)
+}
+
+// Block returns the number of the last block the validator set was synced at
+func (w *ValidatorWatcher) Block() uint64 {
+	w.lock.RLock()
+	defer w.lock.RUnlock()
+
+	return w.block
+}
+
+// Run reads the validator set at the latest block, then polls the endpoint
+// for staking logs until the context is done. The initial set doesn't produce events.
+// Failed polls are retried with an exponential backoff, up to MaxRetries times in a row.
+// Run closes the event channel when it returns, and can only be called once
+func (w *ValidatorWatcher) Run(ctx context.Context) error {
+	defer close(w.events)
+
+	ticker := time.NewTicker(w.config.PollInterval)
+	defer ticker.Stop()
+
+	backoff := newRetryBackoff(w.config.MaxRetryBackoff)
+	failures := 0
+
+	for {
+		err := w.poll(ctx)
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		if err != nil {
+			if failures++; w.config.MaxRetries > 0 && failures > w.config.MaxRetries {
+				return err
+			}
+
+			if !backoff.wait(ctx) {
+				return nil
+			}
+
+			continue
+		}
+
+		failures = 0
+		backoff.reset()
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// poll syncs the validator set up to the latest block
+func (w *ValidatorWatcher) poll(ctx context.Context) error {
+	head, err := w.transport.getBlockNumber(ctx)
+	if err != nil {
+		return err
+	}
+
+	w.lock.RLock()
+	synced, block, hash := w.synced, w.block, w.hash
+	w.lock.RUnlock()
+
+	if !synced {
+		return w.sync(ctx, head, false)
+	}
+
+	// If the synced block isn't canonical anymore, the logs of the reorganized range
+	// are unknown, so the whole set is read again at the head
+	current, err := w.transport.getBlockHeader(ctx, block)
+	if err != nil {
+		return err
+	}
+
+	if current == nil || current.Hash != hash {
+		return w.sync(ctx, head, true)
+	}
+
+	if head <= block {
+		return nil
+	}
+
+	// The header is read before the logs, so a reorg in between leaves a stale hash
+	// that is detected by the next poll
+	header, err := w.transport.requireBlockHeader(ctx, head)
+	if err != nil {
+		return err
+	}
+
+	logs, err := w.transport.getStakingLogs(ctx, w.config.StakingAddress, block+1, head)
+	if err != nil {
+		return err
+	}
+
+	if len(logs) == 0 {
+		w.lock.Lock()
+		w.block = head
+		w.hash = header.Hash
+		w.lock.Unlock()
+
+		return nil
+	}
+
+	return w.syncAt(ctx, head, header.Hash, true)
+}
+
+// sync reads the validator set at the given block and replaces the in-memory copy.
+// The differences are published if notify is set
+func (w *ValidatorWatcher) sync(ctx context.Context, block uint64, notify bool) error {
+	header, err := w.transport.requireBlockHeader(ctx, block)
+	if err != nil {
+		return err
+	}
+
+	return w.syncAt(ctx, block, header.Hash, notify)
+}
+
+// syncAt reads the validator set at the block with the given hash, read before the set
+func (w *ValidatorWatcher) syncAt(ctx context.Context, block uint64, hash types.Hash, notify bool) error {
+	client := w.client.At(block)
+
+	validators, err := client.Validators(ctx)
+	if err != nil {
+		return err
+	}
+
+	stakes := make(map[types.Address]*big.Int, len(validators))
+	calls := make([]*ViewCall, len(validators))
+
+	for i, validator := range validators {
+		stakes[validator] = new(big.Int)
+		calls[i] = AccountStakeCall(validator, stakes[validator])
+	}
+
+	if err := client.Call(ctx, calls...); err != nil {
+		return err
+	}
+
+	w.lock.Lock()
+	events := diffValidatorSets(w.validators, w.stakes, validators, stakes, block)
+	w.validators = validators
+	w.stakes = stakes
+	w.block = block
+	w.hash = hash
+	w.synced = true
+	w.lock.Unlock()
+
+	if !notify {
+		return nil
+	}
+
+	for _, event := range events {
+		select {
+		case w.events <- event:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	return nil
+}
+
+// diffValidatorSets returns the events turning the old validator set into the new one.
+// Removals come first, then additions and stake changes in the new set order
+func diffValidatorSets(
+	oldValidators []types.Address,
+	oldStakes map[types.Address]*big.Int,
+	newValidators []types.Address,
+	newStakes map[types.Address]*big.Int,
+	block uint64,
+) []ValidatorSetEvent {
+	events := make([]ValidatorSetEvent, 0)
+
+	for _, validator := range oldValidators {
+		if _, ok := newStakes[validator]; !ok {
+			events = append(events, &ValidatorRemoved{
+				Address:     validator,
+				BlockNumber: block,
+			})
+		}
+	}
+
+	for _, validator := range newValidators {
+		oldStake, ok := oldStakes[validator]
+
+		switch {
+		case !ok:
+			events = append(events, &ValidatorAdded{
+				Address:     validator,
+				Stake:       new(big.Int).Set(newStakes[validator]),
+				BlockNumber: block,
+			})
+		case oldStake.Cmp(newStakes[validator]) != 0:
+			events = append(events, &StakeChanged{
+				Address:     validator,
+				OldStake:    new(big.Int).Set(oldStake),
+				NewStake:    new(big.Int).Set(newStakes[validator]),
+				BlockNumber: block,
+			})
+		}
+	}
+
+	return events
+}
@@ -0,0 +1,361 @@
+package staking
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/0xPolygon/polygon-edge/helper/hex"
+	"github.com/0xPolygon/polygon-edge/types"
+)
+
+// stakingTestChain is a JSON-RPC endpoint serving a chain of the given height, whose validator set
+// changes at the blocks of sets. Each change comes with a Staked log of the first validator.
+// The hashes of the blocks from forkBlock on start with the fork byte, so a reorg is simulated
+// by changing fork, forkBlock and the sets of the new branch
+type stakingTestChain struct {
+	t    *testing.T
+	lock sync.Mutex
+
+	head      uint64
+	fork      byte
+	forkBlock uint64
+	sets      map[uint64]map[types.Address]int64
+
+	// failures is the number of the next requests answered with an HTTP error
+	failures int
+
+	// staleLogs is the number of the next eth_getLogs answers with the block hashes of a stale branch
+	staleLogs int
+}
+
+func newStakingTestChain(
+	t *testing.T,
+	head uint64,
+	sets map[uint64]map[types.Address]int64,
+) (*stakingTestChain, string) {
+	t.Helper()
+
+	chain := &stakingTestChain{
+		t:    t,
+		head: head,
+		sets: sets,
+	}
+
+	server := httptest.NewServer(chain)
+	t.Cleanup(server.Close)
+
+	return chain, server.URL
+}
+
+// update changes the chain under its lock
+func (c *stakingTestChain) update(change func(c *stakingTestChain)) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	change(c)
+}
+
+func (c *stakingTestChain) hash(block uint64) types.Hash {
+	hash := types.BytesToHash(new(big.Int).SetUint64(block + 1).Bytes())
+	if block >= c.forkBlock {
+		hash[0] = c.fork
+	}
+
+	return hash
+}
+
+// validatorsAt returns the validator set at the block, ordered by address
+func (c *stakingTestChain) validatorsAt(block uint64) ([]types.Address, map[types.Address]int64) {
+	var (
+		changed bool
+		last    uint64
+	)
+
+	for number := range c.sets {
+		if number <= block && (!changed || number > last) {
+			changed, last = true, number
+		}
+	}
+
+	stakes := c.sets[last]
+	validators := make([]types.Address, 0, len(stakes))
+
+	for validator := range stakes {
+		validators = append(validators, validator)
+	}
+
+	sort.Slice(validators, func(i, j int) bool {
+		return validators[i].String() < validators[j].String()
+	})
+
+	return validators, stakes
+}
+
+func (c *stakingTestChain) parseBlock(param interface{}) uint64 {
+	value, _ := param.(string)
+	if value == "latest" {
+		return c.head
+	}
+
+	block, err := types.ParseUint64orHex(&value)
+	if err != nil {
+		c.t.Errorf("invalid block %q, %v", value, err)
+	}
+
+	return block
+}
+
+func (c *stakingTestChain) answer(request jsonRPCRequest) interface{} {
+	switch request.Method {
+	case "eth_blockNumber":
+		return hex.EncodeUint64(c.head)
+	case "eth_getBlockByNumber":
+		block := c.parseBlock(request.Params[0])
+		if block > c.head {
+			return nil
+		}
+
+		return map[string]interface{}{
+			"number": hex.EncodeUint64(block),
+			"hash":   c.hash(block),
+		}
+	case "eth_getLogs":
+		filter, _ := request.Params[0].(map[string]interface{})
+		from, to := c.parseBlock(filter["fromBlock"]), c.parseBlock(filter["toBlock"])
+		logs := make([]map[string]interface{}, 0)
+
+		stale := c.staleLogs > 0
+		if stale {
+			c.staleLogs--
+		}
+
+		for block := from; block <= to && block <= c.head; block++ {
+			if _, ok := c.sets[block]; !ok {
+				continue
+			}
+
+			validators, _ := c.validatorsAt(block)
+			staker := types.ZeroAddress
+
+			if len(validators) > 0 {
+				staker = validators[0]
+			}
+
+			data := clientTestWord(32)
+			data = append(data, clientTestWord(1)...)
+			data = append(data, clientTestWord(int64(block))...)
+
+			hash := c.hash(block)
+			if stale {
+				hash[0] = 0xee
+			}
+
+			logs = append(logs, map[string]interface{}{
+				"blockNumber": hex.EncodeUint64(block),
+				"blockHash":   hash,
+				"logIndex":    "0x0",
+				"topics":      []types.Hash{StakedEventTopic, types.BytesToHash(staker.Bytes())},
+				"data":        hex.EncodeToHex(data),
+			})
+		}
+
+		return logs
+	case "eth_call":
+		validators, stakes := c.validatorsAt(c.parseBlock(request.Params[1]))
+
+		call, _ := request.Params[0].(map[string]interface{})
+		data, _ := call["data"].(string)
+
+		input, err := hex.DecodeHex(data)
+		if err != nil || len(input) < 4 {
+			c.t.Errorf("invalid call data %q", data)
+
+			return nil
+		}
+
+		switch selector := hex.EncodeToString(input[:4]); selector {
+		case hex.EncodeToString(selectorValidators):
+			output := clientTestWord(32)
+			output = append(output, clientTestWord(int64(len(validators)))...)
+
+			for _, validator := range validators {
+				output = append(output, types.BytesToHash(validator.Bytes()).Bytes()...)
+			}
+
+			return hex.EncodeToHex(output)
+		case hex.EncodeToString(selectorAccountStake):
+			return hex.EncodeToHex(clientTestWord(stakes[types.BytesToAddress(input[4:])]))
+		case hex.EncodeToString(selectorAddressToWeight):
+			return hex.EncodeToHex(clientTestWord(1))
+		default:
+			c.t.Errorf("unexpected selector %s", selector)
+		}
+	default:
+		c.t.Errorf("unexpected method %s", request.Method)
+	}
+
+	return nil
+}
+
+func (c *stakingTestChain) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.failures > 0 {
+		c.failures--
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+
+		return
+	}
+
+	var body json.RawMessage
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		c.t.Errorf("unable to decode the request, %v", err)
+
+		return
+	}
+
+	var response interface{}
+
+	var requests []jsonRPCRequest
+	if err := json.Unmarshal(body, &requests); err == nil {
+		responses := make([]map[string]interface{}, len(requests))
+		for i, request := range requests {
+			responses[i] = map[string]interface{}{"jsonrpc": "2.0", "id": request.ID, "result": c.answer(request)}
+		}
+
+		response = responses
+	} else {
+		var request jsonRPCRequest
+		if err := json.Unmarshal(body, &request); err != nil {
+			c.t.Errorf("unable to decode the request, %v", err)
+
+			return
+		}
+
+		response = map[string]interface{}{"jsonrpc": "2.0", "id": request.ID, "result": c.answer(request)}
+	}
+
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		c.t.Errorf("unable to encode the response, %v", err)
+	}
+}
+
+// waitFor polls the condition until it holds, or fails the test after a timeout
+func waitFor(t *testing.T, condition func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out")
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestValidatorWatcherRetryAndReorg(t *testing.T) {
+	var (
+		validatorA = types.StringToAddress("0x1")
+		validatorB = types.StringToAddress("0x2")
+		validatorC = types.StringToAddress("0x3")
+	)
+
+	chain, endpoint := newStakingTestChain(t, 5, map[uint64]map[types.Address]int64{
+		0: {validatorA: 10, validatorB: 20},
+	})
+
+	// The first polls fail, they're retried instead of stopping the watcher
+	chain.failures = 3
+
+	watcher := NewValidatorWatcher(WatcherConfig{
+		Endpoint:        endpoint,
+		StakingAddress:  DefaultStakingSCAddress,
+		PollInterval:    5 * time.Millisecond,
+		MaxRetryBackoff: 5 * time.Millisecond,
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+
+	go func() {
+		done <- watcher.Run(ctx)
+	}()
+
+	waitFor(t, func() bool {
+		return watcher.Block() == 5
+	})
+
+	if validators := watcher.Validators(); len(validators) != 2 {
+		t.Fatalf("expected 2 validators, got %v", validators)
+	}
+
+	// Block 4 is replaced by a block changing the set, below the synced block,
+	// so it's only noticed through the hash of the synced block
+	chain.update(func(c *stakingTestChain) {
+		c.fork, c.forkBlock = 0xff, 4
+		c.sets[4] = map[types.Address]int64{validatorB: 25, validatorC: 3}
+	})
+
+	events := make([]ValidatorSetEvent, 0, 3)
+	for len(events) < 3 {
+		select {
+		case event := <-watcher.Events():
+			events = append(events, event)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out, got the events %v", events)
+		}
+	}
+
+	if removed, ok := events[0].(*ValidatorRemoved); !ok || removed.Address != validatorA {
+		t.Errorf("expected %s to be removed, got %#v", validatorA, events[0])
+	}
+
+	if changed, ok := events[1].(*StakeChanged); !ok || changed.Address != validatorB || changed.NewStake.Int64() != 25 {
+		t.Errorf("expected the stake of %s to change to 25, got %#v", validatorB, events[1])
+	}
+
+	if added, ok := events[2].(*ValidatorAdded); !ok || added.Address != validatorC || added.Block() != 5 {
+		t.Errorf("expected %s to be added at block 5, got %#v", validatorC, events[2])
+	}
+
+	cancel()
+
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestValidatorWatcherMaxRetries(t *testing.T) {
+	chain, endpoint := newStakingTestChain(t, 1, map[uint64]map[types.Address]int64{
+		0: {types.StringToAddress("0x1"): 1},
+	})
+	chain.failures = 10
+
+	watcher := NewValidatorWatcher(WatcherConfig{
+		Endpoint:        endpoint,
+		StakingAddress:  DefaultStakingSCAddress,
+		PollInterval:    time.Millisecond,
+		MaxRetryBackoff: time.Millisecond,
+		MaxRetries:      2,
+	})
+
+	if err := watcher.Run(context.Background()); err == nil {
+		t.Fatal("expected the error of the last retry")
+	}
+
+	chain.update(func(c *stakingTestChain) {
+		if c.failures != 7 {
+			t.Errorf("expected 3 polls, got %d", 10-c.failures)
+		}
+	})
+}